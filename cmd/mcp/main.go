@@ -1,65 +1,579 @@
 package main
 
 import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
 	"log"
+	"log/slog"
+	"net/http"
 	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
 
 	"github.com/mark3labs/mcp-go/server"
 	"github.com/ready4god2513/deskmcp/pkg/companies"
+	"github.com/ready4god2513/deskmcp/pkg/config"
 	"github.com/ready4god2513/deskmcp/pkg/customers"
 	"github.com/ready4god2513/deskmcp/pkg/desk"
+	"github.com/ready4god2513/deskmcp/pkg/diagnostics"
+	"github.com/ready4god2513/deskmcp/pkg/health"
+	"github.com/ready4god2513/deskmcp/pkg/logging"
+	"github.com/ready4god2513/deskmcp/pkg/metrics"
+	"github.com/ready4god2513/deskmcp/pkg/prompts"
+	"github.com/ready4god2513/deskmcp/pkg/sites"
 	"github.com/ready4god2513/deskmcp/pkg/tags"
 	"github.com/ready4god2513/deskmcp/pkg/tickets"
 	"github.com/ready4god2513/deskmcp/pkg/ticketstatuses"
 	"github.com/ready4god2513/deskmcp/pkg/tickettypes"
+	"github.com/ready4god2513/deskmcp/pkg/toolfilter"
+	"github.com/ready4god2513/deskmcp/pkg/tracing"
+	"github.com/ready4god2513/deskmcp/pkg/transport"
 	"github.com/ready4god2513/deskmcp/pkg/users"
+	"github.com/ready4god2513/deskmcp/pkg/utils"
+	"github.com/ready4god2513/deskmcp/pkg/watch"
+	"github.com/ready4god2513/deskmcp/pkg/webhooks"
 )
 
+// replayURL is the site URL used when replaying without a configured site
+const replayURL = "https://replay.invalid"
+
+// version is the version of the server reported to clients and in traces
+const version = "1.0.0"
+
 func main() {
-	// Get environment variables
-	deskURL := os.Getenv("DESK_API_URL")
-	if deskURL == "" {
-		log.Fatal("DESK_API_URL environment variable is required")
+	// A server that stops with an error exits with status 1 once the deferred
+	// cleanup has run
+	failed := false
+	defer func() {
+		if failed {
+			os.Exit(1)
+		}
+	}()
+
+	configPath := flag.String("config", os.Getenv("DESKMCP_CONFIG"),
+		"Config file of Desk site profiles (env DESKMCP_CONFIG, default "+config.DefaultPath()+")")
+	profileName := flag.String("profile", os.Getenv("DESKMCP_PROFILE"),
+		"Profile of the config file to use (env DESKMCP_PROFILE)")
+	siteNames := flag.String("sites", os.Getenv("DESKMCP_SITES"),
+		"Comma separated profiles of the config file tool calls may be routed to with their site argument, besides the selected one (env DESKMCP_SITES)")
+	transportName := flag.String("transport", envOrDefault("DESKMCP_TRANSPORT", "stdio"),
+		"Transport to serve the MCP server over: stdio, sse or http (env DESKMCP_TRANSPORT)")
+	addr := flag.String("addr", envOrDefault("DESKMCP_ADDR", ":8080"),
+		"Address to listen on for the sse and http transports (env DESKMCP_ADDR)")
+	metricsAddr := flag.String("metrics-addr", os.Getenv("DESKMCP_METRICS_ADDR"),
+		"Address to serve the metrics and health checks on, e.g. \"127.0.0.1:9091\". They are not served when it is empty (env DESKMCP_METRICS_ADDR)")
+	sessionTTL := flag.Duration("session-client-ttl", 30*time.Minute,
+		"How long the Desk client of an idle HTTP session is cached")
+	allowedHosts := flag.String("allowed-hosts", envOrDefault("DESKMCP_ALLOWED_HOSTS", strings.Join(desk.DefaultAllowedHosts, ",")),
+		"Comma separated glob patterns of the Desk hosts HTTP sessions may send their own credentials for (env DESKMCP_ALLOWED_HOSTS)")
+	readOnly := flag.Bool("read-only", envBool("DESKMCP_READ_ONLY"),
+		"Only expose tools that never modify data: list_*, get_*, count_*, watch_*, unwatch_* and cache_* (env DESKMCP_READ_ONLY)")
+	enableTools := flag.String("enable-tools", os.Getenv("DESKMCP_ENABLE_TOOLS"),
+		"Comma separated glob patterns of the tools to expose, e.g. \"list_*,get_ticket\" (env DESKMCP_ENABLE_TOOLS)")
+	disableTools := flag.String("disable-tools", os.Getenv("DESKMCP_DISABLE_TOOLS"),
+		"Comma separated glob patterns of the tools to hide, e.g. \"delete_*\" (env DESKMCP_DISABLE_TOOLS)")
+	pageSize := flag.Int("page-size", utils.DefaultPageSize,
+		"Page size of list tools called without pageSize (env DESKMCP_PAGE_SIZE)")
+	logFile := flag.String("log-file", os.Getenv("DESKMCP_LOG_FILE"),
+		"File to append the log to instead of stderr (env DESKMCP_LOG_FILE)")
+	logFormat := flag.String("log-format", envOrDefault("DESKMCP_LOG_FORMAT", "text"),
+		"Format of the log: text or json (env DESKMCP_LOG_FORMAT)")
+	logLevel := flag.String("log-level", envOrDefault("DESKMCP_LOG_LEVEL", "info"),
+		"Least severe level logged: debug, info, warn or error (env DESKMCP_LOG_LEVEL)")
+	auditLog := flag.String("audit-log", os.Getenv("DESKMCP_AUDIT_LOG"),
+		"File to append the calls of tools that modify data to (env DESKMCP_AUDIT_LOG)")
+	pollInterval := flag.Duration("poll-interval", watch.DefaultInterval,
+		"How often subscribed resources are polled for changes, 0 to rely on webhooks only")
+	pollMaxBackoff := flag.Duration("poll-max-backoff", watch.DefaultMaxBackoff,
+		"Longest delay between polls while the Desk API fails")
+	cacheTTL := flag.Duration("cache-ttl", 5*time.Minute,
+		"How long reference data (statuses, types, priorities, sources, tags, users and inboxes) is cached, 0 to disable caching")
+	cacheTTLs := flag.String("cache-ttls", os.Getenv("DESKMCP_CACHE_TTLS"),
+		"Comma separated TTLs of individual reference resources, e.g. \"tags=1h,users=1m\" (env DESKMCP_CACHE_TTLS)")
+	cacheSize := flag.Int("cache-size", 1000,
+		"Maximum number of cached reference data responses")
+	maxRetries := flag.Int("max-retries", desk.DefaultRetryConfig.MaxRetries,
+		"How often a request failing with a rate limit or transient error is retried")
+	maxRetryDelay := flag.Duration("max-retry-delay", desk.DefaultRetryConfig.MaxDelay,
+		"Longest delay before a retry, including delays asked for by Retry-After")
+	rateLimit := flag.Float64("rate-limit", 0,
+		"Maximum Desk API requests per second of each client, 0 for no client side limit")
+	rateBurst := flag.Int("rate-burst", 10,
+		"Number of requests a client may send at once before -rate-limit applies")
+	recordPath := flag.String("record", os.Getenv("DESKMCP_RECORD"),
+		"Cassette file to record the Desk API traffic to, with tokens and personal data redacted (env DESKMCP_RECORD)")
+	replayPath := flag.String("replay", os.Getenv("DESKMCP_REPLAY"),
+		"Cassette file to answer Desk API requests from instead of Desk (env DESKMCP_REPLAY)")
+	traceExporter := flag.String("trace-exporter", envOrDefault("DESKMCP_TRACE_EXPORTER", "none"),
+		"Where to export traces of tool calls and Desk API requests: none, console (the log output) or otlp (env DESKMCP_TRACE_EXPORTER)")
+	webhookAddr := flag.String("webhook-addr", os.Getenv("DESKMCP_WEBHOOK_ADDR"),
+		"Address to receive Desk webhooks on, e.g. \":8081\". Requires DESKMCP_WEBHOOK_SECRET (env DESKMCP_WEBHOOK_ADDR)")
+	flag.Parse()
+
+	// Settings not given as flags are taken from the environment and then
+	// the profile
+	configFile, err := config.Open(*configPath)
+	if err != nil {
+		log.Fatal(err)
+	}
+	profile, err := configFile.Select(*profileName)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := applyProfile(profile); err != nil {
+		log.Fatal(err)
+	}
+	// The log goes to stderr or a file, never to stdout where the stdio
+	// transport speaks
+	var logOutput io.Writer = os.Stderr
+	if *logFile != "" {
+		f, err := os.OpenFile(*logFile, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer f.Close()
+		logOutput = f
+	}
+	logger, err := logging.New(logOutput, *logFormat, *logLevel)
+	if err != nil {
+		log.Fatal(err)
+	}
+	slog.SetDefault(logger)
+
+	// Tracing is set up before the Desk clients so that their requests are
+	// traced
+	shutdownTracing, err := tracing.Setup(context.Background(), *traceExporter, version, logOutput)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := shutdownTracing(ctx); err != nil {
+			slog.Warn("failed to flush traces", "error", err)
+		}
+	}()
+
+	var audit *logging.AuditLog
+	if *auditLog != "" {
+		if audit, err = logging.OpenAuditLog(*auditLog); err != nil {
+			log.Fatal(err)
+		}
+		defer audit.Close()
+	}
+	if *pageSize < 1 || *pageSize > utils.MaxPageSize {
+		log.Fatalf("invalid page size %d, expected 1 to %d", *pageSize, utils.MaxPageSize)
+	}
+	utils.DefaultPageSize = *pageSize
+
+	toolFilter := toolfilter.Filter{ReadOnly: *readOnly}
+	if toolFilter.Enable, err = toolfilter.ParsePatterns(*enableTools); err != nil {
+		log.Fatal(err)
+	}
+	if toolFilter.Disable, err = toolfilter.ParsePatterns(*disableTools); err != nil {
+		log.Fatal(err)
+	}
+
+	serverMetrics := metrics.New()
+	clientOpts := []desk.Option{
+		desk.WithRetry(desk.RetryConfig{
+			MaxRetries: *maxRetries,
+			BaseDelay:  desk.DefaultRetryConfig.BaseDelay,
+			MaxDelay:   *maxRetryDelay,
+		}),
+		desk.WithCallObserver(serverMetrics.ObserveDeskCall),
+	}
+	if *rateLimit > 0 {
+		clientOpts = append(clientOpts, desk.WithRateLimit(*rateLimit, *rateBurst))
+	}
+	if *cacheTTL > 0 {
+		resourceTTLs, err := desk.ParseResourceTTLs(*cacheTTLs)
+		if err != nil {
+			log.Fatal(err)
+		}
+		clientOpts = append(clientOpts, desk.WithCache(desk.NewCache(desk.CacheConfig{
+			TTL:          *cacheTTL,
+			ResourceTTLs: resourceTTLs,
+			MaxEntries:   *cacheSize,
+		})))
+	}
+
+	// A recorded session is replayed without network, so the credentials
+	// only have to be real when recording
+	if *recordPath != "" && *replayPath != "" {
+		log.Fatal("-record and -replay cannot be used together")
+	}
+	if *recordPath != "" {
+		recorder, err := desk.NewRecorder(*recordPath)
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer recorder.Close()
+		clientOpts = append(clientOpts, desk.WithRecorder(recorder))
+		slog.Info("recording the Desk API traffic", "cassette", *recordPath)
+	}
+	if *replayPath != "" {
+		cassette, err := desk.LoadCassette(*replayPath)
+		if err != nil {
+			log.Fatal(err)
+		}
+		clientOpts = append(clientOpts, desk.WithReplay(cassette))
+		slog.Info("replaying the Desk API traffic", "cassette", *replayPath)
 	}
 
-	deskToken := os.Getenv("DESK_API_TOKEN")
-	if deskToken == "" {
+	// Over HTTP the credentials are optional since each session can supply
+	// its own
+	deskURL := profile.URL
+	deskToken, err := profile.ResolveToken(context.Background())
+	if err != nil {
+		log.Fatal(err)
+	}
+	if *replayPath != "" {
+		if deskURL == "" {
+			deskURL = replayURL
+		}
+		if deskToken == "" {
+			deskToken = "replay"
+		}
+	}
+	hasDefaultCredentials := deskURL != "" && deskToken != ""
+	if !hasDefaultCredentials && (*transportName == "stdio" || deskURL != "" || deskToken != "") {
+		if deskURL == "" {
+			log.Fatal("DESK_API_URL environment variable is required")
+		}
 		log.Fatal("DESK_API_TOKEN environment variable is required")
 	}
 
 	// Initialize Desk client
-	deskClient := desk.NewClient(deskURL, deskToken)
+	var deskClient *desk.Client
+	if hasDefaultCredentials {
+		deskClient = desk.NewClient(deskURL, deskToken, clientOpts...)
+	} else {
+		slog.Info("no default Desk credentials, every request must send them in headers",
+			"url_header", desk.URLHeader, "token_header", desk.TokenHeader)
+	}
+
+	// The selected profile is the default site. Other profiles are only sites
+	// that tool calls can be routed to with their site argument when they are
+	// listed with --sites.
+	defaultSite := profile.Name
+	if defaultSite == "" {
+		defaultSite = "default"
+	}
+	deskSites := desk.NewSites(defaultSite)
+	if deskClient != nil {
+		deskSites.Add(defaultSite, deskClient)
+	}
+	for _, name := range splitList(*siteNames) {
+		if name == profile.Name {
+			continue
+		}
+		site, err := configFile.Profile(name)
+		if err != nil {
+			log.Fatalf("invalid site: %v", err)
+		}
+		if err := checkSiteSettings(site, *readOnly, *enableTools, *disableTools); err != nil {
+			log.Fatal(err)
+		}
+		token, err := site.ResolveToken(context.Background())
+		if err != nil {
+			log.Fatalf("invalid site %q: %v", name, err)
+		}
+		if site.URL == "" || token == "" {
+			log.Fatalf("invalid site %q: its profile has no URL or token", name)
+		}
+		deskSites.Add(name, desk.NewClient(site.URL, token, clientOpts...))
+	}
+	serverMetrics.WatchSites(deskSites)
+
+	hostPatterns, err := desk.ParseHostPatterns(*allowedHosts)
+	if err != nil {
+		log.Fatal(err)
+	}
+	sessionClients := desk.NewSessionClients(*sessionTTL, hostPatterns, clientOpts...)
+	defer sessionClients.Close()
+
+	// Resource subscriptions and ticket watches are served by polling Desk
+	watcher := watch.NewWatcher(deskClient,
+		watch.WithInterval(*pollInterval),
+		watch.WithMaxBackoff(*pollMaxBackoff),
+	)
+	defer watcher.Close()
+
+	hooks := sessionClients.Hooks()
+	watcher.AddHooks(hooks)
+
+	// Tool calls are routed to the site named by their site argument. Without
+	// default credentials every HTTP session brings its own, which the
+	// profiles' tokens must not stand in for, so calls are not routed.
+	routedSites := deskSites
+	if !hasDefaultCredentials {
+		routedSites = nil
+	}
+	siteRouter := utils.NewSiteRouter(routedSites, sites.ListSitesTool)
+	siteRouter.AddHooks(hooks)
 
 	// Create MCP server
 	s := server.NewMCPServer(
 		"Teamwork Desk",
-		"1.0.0",
+		version,
+		server.WithHooks(hooks),
+		server.WithResourceCapabilities(true, false),
+		server.WithLogging(),
+		server.WithRecovery(),
+		server.WithToolHandlerMiddleware(tracing.ToolCalls()),
+		server.WithToolHandlerMiddleware(logging.ToolCalls(logger, audit)),
+		server.WithToolHandlerMiddleware(serverMetrics.ToolCalls()),
+		server.WithToolHandlerMiddleware(siteRouter.Middleware()),
 	)
 
-	// Register tools from each package
+	// Register tools and resources from each package
 	ticketHandler := tickets.NewTicketHandler(deskClient)
 	ticketHandler.RegisterTools(s)
+	ticketHandler.RegisterResources(s)
 
 	customerHandler := customers.NewCustomerHandler(deskClient)
 	customerHandler.RegisterTools(s)
+	customerHandler.RegisterResources(s)
 
 	companyHandler := companies.NewCompanyHandler(deskClient)
 	companyHandler.RegisterTools(s)
+	companyHandler.RegisterResources(s)
 
 	userHandler := users.NewUserHandler(deskClient)
 	userHandler.RegisterTools(s)
+	userHandler.RegisterResources(s)
 
 	ticketStatusHandler := ticketstatuses.NewTicketStatusHandler(deskClient)
 	ticketStatusHandler.RegisterTools(s)
+	ticketStatusHandler.RegisterResources(s)
 
 	tagsHandler := tags.NewTagHandler(deskClient)
 	tagsHandler.RegisterTools(s)
+	tagsHandler.RegisterResources(s)
 
 	ticketTypeHandler := tickettypes.NewTicketTypeHandler(deskClient)
 	ticketTypeHandler.RegisterTools(s)
+	ticketTypeHandler.RegisterResources(s)
 
-	// Start the server
-	if err := server.ServeStdio(s); err != nil {
+	watcher.RegisterTools(s)
+	if routedSites != nil {
+		sites.NewSiteHandler(routedSites).RegisterTools(s)
+	}
+	diagnostics.NewDiagnosticsHandler(deskClient).RegisterTools(s)
+
+	// Webhook events are sent to the sessions as they arrive
+	var receiver *webhooks.Receiver
+	if *webhookAddr != "" {
+		secret := os.Getenv("DESKMCP_WEBHOOK_SECRET")
+		if secret == "" {
+			log.Fatal("DESKMCP_WEBHOOK_SECRET environment variable is required to receive webhooks")
+		}
+		if deskURL == "" {
+			log.Fatal("DESK_API_URL is required to receive webhooks: it names the site they come from")
+		}
+		receiver = webhooks.NewReceiver(deskURL, secret)
+		receiver.OnEvent(webhooks.CacheInvalidator(deskClient))
+		receiver.OnEvent(webhooks.LogNotifier(watcher.NotifyInterested))
+		receiver.OnEvent(func(event webhooks.Event) {
+			if site, uri := event.Resource(); uri != "" {
+				watcher.NotifyUpdated(site, uri)
+			}
+		})
+		receiver.RegisterResources(s)
+	}
+
+	// Register the prompts for common support workflows
+	prompts.NewPromptHandler(deskClient).RegisterPrompts(s)
+
+	// Remove the tools that are not allowed
+	if _, err := toolFilter.Apply(s); err != nil {
 		log.Fatal(err)
 	}
+
+	// Start the server
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	// The webhooks, metrics and health checks have listeners of their own. A
+	// listener that fails stops the server.
+	listenErrs := make(chan error, 2)
+	listen := func(name, addr string, handler http.Handler) {
+		go func() {
+			if err := transport.ListenAndServe(ctx, addr, handler); err != nil {
+				listenErrs <- fmt.Errorf("%s listener: %w", name, err)
+				stop()
+			}
+		}()
+	}
+	if receiver != nil {
+		mux := http.NewServeMux()
+		mux.Handle(webhooks.Path, receiver)
+		slog.Info("receiving Desk webhooks", "addr", *webhookAddr, "path", webhooks.Path)
+		listen("webhook", *webhookAddr, mux)
+	}
+	if *metricsAddr != "" {
+		// The metrics and probes need no Desk credentials, so they are kept
+		// off the address the MCP clients connect to
+		mux := http.NewServeMux()
+		mux.Handle("/metrics", serverMetrics.Handler())
+		mux.Handle("/healthz", health.Liveness())
+		mux.Handle("/readyz", health.NewChecker(deskSites))
+		slog.Info("serving metrics and health checks", "addr", *metricsAddr)
+		listen("metrics", *metricsAddr, mux)
+	}
+
+	cfg := serveConfig{
+		transport:          *transportName,
+		addr:               *addr,
+		sessionClients:     sessionClients,
+		filter:             watcher.FilterMessage,
+		requireCredentials: !hasDefaultCredentials,
+	}
+	err = serve(ctx, s, cfg)
+	if err == nil {
+		select {
+		case err = <-listenErrs:
+		default:
+		}
+	}
+	if err != nil {
+		slog.Error("server stopped", "error", err)
+		failed = true
+	}
+}
+
+// serveConfig describes how the MCP server is served
+type serveConfig struct {
+	transport          string
+	addr               string
+	sessionClients     *desk.SessionClients
+	filter             transport.MessageFilter
+	requireCredentials bool
+}
+
+// serve serves the MCP server over the configured transport until ctx is cancelled
+func serve(ctx context.Context, s *server.MCPServer, cfg serveConfig) error {
+	// Tool calls over HTTP continue the trace of the client
+	contextFunc := func(ctx context.Context, r *http.Request) context.Context {
+		return cfg.sessionClients.ContextFunc(tracing.ContextFunc(ctx, r), r)
+	}
+
+	var handler http.Handler
+	switch cfg.transport {
+	case "stdio":
+		err := transport.ServeStdio(ctx, s, os.Stdin, os.Stdout, cfg.filter)
+		if ctx.Err() != nil {
+			return nil
+		}
+		return err
+
+	case "sse":
+		sse := server.NewSSEServer(s, server.WithSSEContextFunc(contextFunc))
+		handler = transport.FilterSSEMessages(s, sse, cfg.filter, contextFunc)
+		slog.Info("serving SSE transport", "addr", cfg.addr)
+
+	case "http":
+		h := transport.NewStreamableHTTPServer(s,
+			transport.WithHTTPContextFunc(contextFunc),
+			transport.WithMessageFilter(cfg.filter),
+		)
+		defer h.Close()
+		mux := http.NewServeMux()
+		mux.Handle("/mcp", h)
+		handler = mux
+		slog.Info("serving streamable HTTP transport", "addr", cfg.addr, "path", "/mcp")
+
+	default:
+		return fmt.Errorf("unknown transport %q, expected stdio, sse or http", cfg.transport)
+	}
+
+	if cfg.requireCredentials {
+		handler = desk.RequireCredentials(handler)
+	}
+	handler = cfg.sessionClients.CheckCredentials(handler)
+	return transport.ListenAndServe(ctx, cfg.addr, handler)
+}
+
+// applyProfile sets the flags that were not given on the command line to
+// the values of the profile
+func applyProfile(p config.Profile) error {
+	given := make(map[string]bool)
+	flag.Visit(func(f *flag.Flag) { given[f.Name] = true })
+
+	values := map[string]string{
+		"transport":     p.Transport,
+		"addr":          p.Addr,
+		"metrics-addr":  p.MetricsAddr,
+		"allowed-hosts": strings.Join(p.AllowedHosts, ","),
+		"sites":         strings.Join(p.Sites, ","),
+		"enable-tools":  strings.Join(p.EnableTools, ","),
+		"disable-tools": strings.Join(p.DisableTools, ","),
+		"log-file":      p.Log.File,
+		"log-format":    p.Log.Format,
+		"log-level":     p.Log.Level,
+		"audit-log":     p.Log.Audit,
+	}
+	if p.ReadOnly != nil {
+		values["read-only"] = strconv.FormatBool(*p.ReadOnly)
+	}
+	if p.PageSize != 0 {
+		values["page-size"] = strconv.Itoa(p.PageSize)
+	}
+	for name, value := range values {
+		if value == "" || given[name] {
+			continue
+		}
+		if err := flag.Set(name, value); err != nil {
+			return fmt.Errorf("invalid %s %q in profile %q: %w", name, value, p.Name, err)
+		}
+	}
+	return nil
+}
+
+// checkSiteSettings returns an error if the profile of a site restricts the
+// tools differently from the server. The read-only mode and the tool filter
+// of the selected profile apply to every site, so a site whose own profile
+// is stricter must be served by a server of its own.
+func checkSiteSettings(site config.Profile, readOnly bool, enableTools, disableTools string) error {
+	if site.ReadOnly != nil && *site.ReadOnly != readOnly {
+		return fmt.Errorf("invalid site %q: its profile sets read_only to %t, but the server's is %t", site.Name, *site.ReadOnly, readOnly)
+	}
+	if len(site.EnableTools) > 0 && strings.Join(site.EnableTools, ",") != strings.Join(splitList(enableTools), ",") {
+		return fmt.Errorf("invalid site %q: its profile enables other tools than the server", site.Name)
+	}
+	if len(site.DisableTools) > 0 && strings.Join(site.DisableTools, ",") != strings.Join(splitList(disableTools), ",") {
+		return fmt.Errorf("invalid site %q: its profile disables other tools than the server", site.Name)
+	}
+	return nil
+}
+
+// splitList splits a comma separated list, dropping empty elements
+func splitList(list string) []string {
+	var items []string
+	for _, item := range strings.Split(list, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// envBool reports whether the environment variable is set to a true value
+func envBool(key string) bool {
+	v, _ := strconv.ParseBool(os.Getenv(key))
+	return v
+}
+
+// envOrDefault returns the value of the environment variable or def if it is unset
+func envOrDefault(key, def string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return def
 }
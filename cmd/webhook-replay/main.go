@@ -0,0 +1,88 @@
+// Command webhook-replay posts recorded Desk webhook payloads to a running
+// server, signed like Desk signs them. It is used to try out the webhook
+// receiver without configuring webhooks in Desk.
+//
+//	DESKMCP_WEBHOOK_SECRET=secret go run ./cmd/webhook-replay cmd/webhook-replay/payloads/*.json
+package main
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/ready4god2513/deskmcp/pkg/webhooks"
+)
+
+func main() {
+	target := flag.String("url", "http://localhost:8081"+webhooks.Path, "URL of the webhook receiver")
+	secret := flag.String("secret", os.Getenv("DESKMCP_WEBHOOK_SECRET"), "Webhook secret (env DESKMCP_WEBHOOK_SECRET)")
+	delay := flag.Duration("delay", 0, "Delay between payloads")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s [flags] payload.json...\n", filepath.Base(os.Args[0]))
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+	if *secret == "" {
+		log.Fatal("a webhook secret is required: set DESKMCP_WEBHOOK_SECRET or pass -secret")
+	}
+
+	client := &http.Client{Timeout: 10 * time.Second}
+	for i, path := range flag.Args() {
+		if i > 0 && *delay > 0 {
+			time.Sleep(*delay)
+		}
+		if err := replay(client, *target, []byte(*secret), path); err != nil {
+			log.Fatalf("%s: %v", path, err)
+		}
+	}
+}
+
+// replay posts a payload file with the headers of a Desk delivery
+func replay(client *http.Client, target string, secret []byte, path string) error {
+	body, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	var payload struct {
+		Event string `json:"event"`
+	}
+	if err := json.Unmarshal(body, &payload); err != nil {
+		return fmt.Errorf("invalid payload: %w", err)
+	}
+
+	req, err := http.NewRequest(http.MethodPost, target, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(webhooks.SignatureHeader, hex.EncodeToString(webhooks.Sign(secret, body)))
+	req.Header.Set(webhooks.DeliveryHeader, fmt.Sprintf("replay-%d", time.Now().UnixNano()))
+	if payload.Event != "" {
+		req.Header.Set(webhooks.EventHeader, payload.Event)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	msg, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("%s: %s", resp.Status, bytes.TrimSpace(msg))
+	}
+	log.Printf("%s: %s %s", path, payload.Event, resp.Status)
+	return nil
+}
@@ -10,10 +10,18 @@ import (
 	"github.com/mark3labs/mcp-go/mcp"
 	"github.com/mark3labs/mcp-go/server"
 	"github.com/ready4god2513/deskmcp/pkg/desk"
+	"github.com/ready4god2513/deskmcp/pkg/filter"
 	"github.com/ready4god2513/deskmcp/pkg/utils"
 	"github.com/ready4god2513/desksdkgo/models"
 )
 
+// companyFilter lists the fields companies can be filtered by
+var companyFilter = filter.NewSchema("companies",
+	filter.Text("name"),
+	filter.Time("createdAt", "created_at"),
+	filter.Time("updatedAt", "updated_at"),
+)
+
 type CompanyHandler struct {
 	deskClient *desk.Client
 }
@@ -24,15 +32,23 @@ func NewCompanyHandler(deskClient *desk.Client) *CompanyHandler {
 	}
 }
 
+// client returns the Desk client to use for the request. Over HTTP each
+// session may supply its own credentials, otherwise the handler's client is used.
+func (h *CompanyHandler) client(ctx context.Context) *desk.Client {
+	return desk.ClientFromContext(ctx, h.deskClient)
+}
+
 func (h *CompanyHandler) RegisterTools(s *server.MCPServer) {
 	// List companies
-	s.AddTool(mcp.NewTool("list_companies",
+	utils.AddTool(s, mcp.NewTool("list_companies",
 		mcp.WithDescription("List all companies"),
 		mcp.WithObject("filter",
 			mcp.Description(`Optional filter for companies. Available fields:
 - name: Filter by company name
 - created_at: Filter by creation date
-- updated_at: Filter by last update date`),
+- updated_at: Filter by last update date
+
+Supports the $eq, $ne, $lt, $lte, $gt, $gte, $in, $nin, $and and $or operators described on list_tickets.`),
 		),
 		mcp.WithString("orderBy",
 			mcp.Description("Order by field"),
@@ -51,10 +67,24 @@ func (h *CompanyHandler) RegisterTools(s *server.MCPServer) {
 			mcp.Min(1),
 			mcp.Max(100),
 		),
+		utils.WithListOptions(),
 	), h.listCompanies)
 
+	// Count companies
+	utils.AddTool(s, mcp.NewTool("count_companies",
+		mcp.WithDescription("Count all filtered companies"),
+		mcp.WithObject("filter",
+			mcp.Description(`Optional filter for companies. Available fields:
+- name: Filter by company name
+- created_at: Filter by creation date
+- updated_at: Filter by last update date
+
+Supports the $eq, $ne, $lt, $lte, $gt, $gte, $in, $nin, $and and $or operators described on list_tickets.`),
+		),
+	), h.countCompanies)
+
 	// Get company
-	s.AddTool(mcp.NewTool("get_company",
+	utils.AddTool(s, mcp.NewTool("get_company",
 		mcp.WithDescription("Get a specific company by ID"),
 		mcp.WithString("id",
 			mcp.Required(),
@@ -63,44 +93,106 @@ func (h *CompanyHandler) RegisterTools(s *server.MCPServer) {
 	), h.getCompany)
 
 	// Create company
-	s.AddTool(mcp.NewTool("create_company",
+	utils.AddTool(s, mcp.NewTool("create_company",
 		mcp.WithDescription("Create a new company"),
 		mcp.WithString("name",
 			mcp.Required(),
 			mcp.Description("Company name"),
 		),
 	), h.createCompany)
+
+	// Update company
+	utils.AddTool(s, mcp.NewTool("update_company",
+		mcp.WithDescription("Update an existing company. Only the provided fields are changed."),
+		mcp.WithString("id",
+			mcp.Required(),
+			mcp.Description("Company ID"),
+		),
+		mcp.WithString("name",
+			mcp.Description("Company name"),
+		),
+		mcp.WithString("description",
+			mcp.Description("Company description"),
+		),
+		mcp.WithString("note",
+			mcp.Description("Note about the company"),
+		),
+	), h.updateCompany)
+
+	// Delete company
+	utils.AddTool(s, mcp.NewTool("delete_company",
+		mcp.WithDescription("Delete a company. This cannot be undone."),
+		mcp.WithString("id",
+			mcp.Required(),
+			mcp.Description("Company ID"),
+		),
+		mcp.WithBoolean("confirm",
+			mcp.Required(),
+			mcp.Description("Must be true to confirm the deletion"),
+		),
+	), h.deleteCompany)
+}
+
+// RegisterResources registers the desk://companies/{id} resource template
+func (h *CompanyHandler) RegisterResources(s *server.MCPServer) {
+	s.AddResourceTemplate(mcp.NewResourceTemplate("desk://companies/{id}", "Company",
+		mcp.WithTemplateDescription("A company by ID"),
+		mcp.WithTemplateMIMEType("application/json"),
+	), h.readCompany)
 }
 
 func (h *CompanyHandler) listCompanies(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
 	params := url.Values{}
-	if filterParams, ok := request.Params.Arguments["filter"].(map[string]interface{}); ok {
-		for field, value := range filterParams {
-			params.Add(field, fmt.Sprintf("%v", value))
-		}
+	if err := companyFilter.AddToParams(params, request); err != nil {
+		return utils.InvalidArgumentResult(ctx, "Failed to list companies", err), nil
 	}
 
-	utils.AddPaginationToParams(params, request)
+	if err := utils.AddPaginationToParams(params, request); err != nil {
+		return utils.ArgumentErrorResult(err), nil
+	}
 
-	resp, err := h.deskClient.Client.Companies.List(ctx, params)
+	result, err := utils.ListPages(ctx, request, "companies", params, func(ctx context.Context, params url.Values) ([]models.Company, models.Pagination, error) {
+		resp, err := h.client(ctx).Client.Companies.List(ctx, params)
+		if err != nil {
+			return nil, models.Pagination{}, err
+		}
+		return resp.Companies, resp.Pagination, nil
+	})
 	if err != nil {
-		return mcp.NewToolResultError(fmt.Sprintf("Failed to list companies: %v", err)), nil
+		return utils.ErrorResult(ctx, "Failed to list companies", err), nil
 	}
-	data, err := json.Marshal(resp.Companies)
+	data, err := json.Marshal(result)
 	if err != nil {
 		return mcp.NewToolResultError(fmt.Sprintf("Failed to marshal companies: %v", err)), nil
 	}
 	return mcp.NewToolResultText(string(data)), nil
 }
 
-func (h *CompanyHandler) getCompany(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
-	id, err := strconv.Atoi(request.Params.Arguments["id"].(string))
+// Count companies
+func (h *CompanyHandler) countCompanies(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	params := url.Values{}
+	if err := companyFilter.AddToParams(params, request); err != nil {
+		return utils.InvalidArgumentResult(ctx, "Failed to count companies", err), nil
+	}
+
+	utils.AddCountParams(params)
+
+	resp, err := h.client(ctx).Client.Companies.List(ctx, params)
 	if err != nil {
-		return mcp.NewToolResultError(fmt.Sprintf("Invalid company ID: %v", err)), nil
+		return utils.ErrorResult(ctx, "Failed to count companies", err), nil
+	}
+
+	return mcp.NewToolResultText(strconv.Itoa(resp.Pagination.Records)), nil
+}
+
+func (h *CompanyHandler) getCompany(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	var args utils.IDArguments
+	if err := utils.BindArguments(request, &args); err != nil {
+		return utils.ArgumentErrorResult(err), nil
 	}
-	resp, err := h.deskClient.Client.Companies.Get(ctx, id)
+	resp, err := h.client(ctx).Client.Companies.Get(ctx, args.ID)
 	if err != nil {
-		return mcp.NewToolResultError(fmt.Sprintf("Failed to get company: %v", err)), nil
+		return utils.ErrorResult(ctx, "Failed to get company", err), nil
 	}
 	data, err := json.Marshal(resp.Company)
 	if err != nil {
@@ -110,12 +202,44 @@ func (h *CompanyHandler) getCompany(ctx context.Context, request mcp.CallToolReq
 }
 
 func (h *CompanyHandler) createCompany(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	var args struct {
+		Name string `arg:"name"`
+	}
+	if err := utils.BindArguments(request, &args); err != nil {
+		return utils.ArgumentErrorResult(err), nil
+	}
 	company := &models.Company{
-		Name: request.Params.Arguments["name"].(string),
+		Name: args.Name,
+	}
+	resp, err := h.client(ctx).Client.Companies.Create(ctx, company)
+	if err != nil {
+		return utils.ErrorResult(ctx, "Failed to create company", err), nil
 	}
-	resp, err := h.deskClient.Client.Companies.Create(ctx, company)
+	data, err := json.Marshal(resp.Company)
 	if err != nil {
-		return mcp.NewToolResultError(fmt.Sprintf("Failed to create company: %v", err)), nil
+		return mcp.NewToolResultError(fmt.Sprintf("Failed to marshal company: %v", err)), nil
+	}
+	return mcp.NewToolResultText(string(data)), nil
+}
+
+func (h *CompanyHandler) updateCompany(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	var args struct {
+		ID          int     `arg:"id"`
+		Name        *string `arg:"name" field:"name"`
+		Description *string `arg:"description" field:"description"`
+		Note        *string `arg:"note" field:"note"`
+	}
+	if err := utils.BindArguments(request, &args); err != nil {
+		return utils.ArgumentErrorResult(err), nil
+	}
+	fields := utils.ChangedFields(&args)
+	if len(fields) == 0 {
+		return mcp.NewToolResultError("No fields to update were provided"), nil
+	}
+
+	var resp models.CompanyResponse
+	if err := h.client(ctx).Update(ctx, "companies", "company", args.ID, fields, &resp); err != nil {
+		return utils.ErrorResult(ctx, "Failed to update company", err), nil
 	}
 	data, err := json.Marshal(resp.Company)
 	if err != nil {
@@ -123,3 +247,29 @@ func (h *CompanyHandler) createCompany(ctx context.Context, request mcp.CallTool
 	}
 	return mcp.NewToolResultText(string(data)), nil
 }
+
+func (h *CompanyHandler) deleteCompany(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	var args utils.DeleteArguments
+	if err := utils.BindArguments(request, &args); err != nil {
+		return utils.ArgumentErrorResult(err), nil
+	}
+	if !args.Confirm {
+		return utils.InvalidArgumentResult(ctx, fmt.Sprintf("Not deleting company %d", args.ID), &utils.ArgumentError{Argument: "confirm", Message: "must be true to delete it"}), nil
+	}
+	if err := h.client(ctx).Delete(ctx, "companies", args.ID); err != nil {
+		return utils.ErrorResult(ctx, "Failed to delete company", err), nil
+	}
+	return mcp.NewToolResultText(fmt.Sprintf("Company %d deleted", args.ID)), nil
+}
+
+func (h *CompanyHandler) readCompany(ctx context.Context, request mcp.ReadResourceRequest) ([]mcp.ResourceContents, error) {
+	id, err := utils.ResourceID(request)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := h.client(ctx).Client.Companies.Get(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get company %d: %w", id, err)
+	}
+	return utils.JSONResource(request.Params.URI, resp.Company)
+}
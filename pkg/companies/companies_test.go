@@ -0,0 +1,214 @@
+package companies
+
+import (
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/mark3labs/mcp-go/client"
+	"github.com/mark3labs/mcp-go/server"
+	"github.com/ready4god2513/deskmcp/pkg/desk"
+	"github.com/ready4god2513/deskmcp/pkg/desktest"
+	"github.com/ready4god2513/deskmcp/pkg/utils"
+	"github.com/ready4god2513/desksdkgo/models"
+)
+
+// setup starts a fake Desk site and returns it with an MCP client of a
+// server with the company tools
+func setup(t *testing.T) (*desktest.Server, *client.Client) {
+	t.Helper()
+	return desktest.Setup(t, func(s *server.MCPServer, deskClient *desk.Client) {
+		NewCompanyHandler(deskClient).RegisterTools(s)
+	})
+}
+
+// addCompanies stores three companies, the last one created a year later
+func addCompanies(api *desktest.Server) {
+	api.Add("companies", map[string]interface{}{"name": "Acme", "createdAt": "2024-01-10T00:00:00Z"})
+	api.Add("companies", map[string]interface{}{"name": "Globex", "createdAt": "2024-03-01T00:00:00Z"})
+	api.Add("companies", map[string]interface{}{"name": "Initech", "createdAt": "2025-02-01T00:00:00Z"})
+}
+
+// names returns the names of companies in alphabetical order
+func names(companies []models.Company) string {
+	s := make([]string, len(companies))
+	for i, company := range companies {
+		s[i] = company.Name
+	}
+	sort.Strings(s)
+	return strings.Join(s, ", ")
+}
+
+func TestListCompanies(t *testing.T) {
+	api, c := setup(t)
+	addCompanies(api)
+
+	var result utils.ListResult[models.Company]
+	if err := desktest.DecodeResult(desktest.Call(t, c, "list_companies", nil), &result); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := names(result.Items), "Acme, Globex, Initech"; got != want {
+		t.Errorf("companies = %s, want %s", got, want)
+	}
+	if result.Pagination.Records != 3 || result.Pagination.HasMore {
+		t.Errorf("pagination = %+v, want 3 records and no more", result.Pagination)
+	}
+}
+
+func TestListCompaniesFilter(t *testing.T) {
+	api, c := setup(t)
+	addCompanies(api)
+
+	tests := []struct {
+		name   string
+		filter map[string]interface{}
+		want   string
+	}{
+		{"name", map[string]interface{}{"name": "globex"}, "Globex"},
+		{"created after", map[string]interface{}{"created_at": map[string]interface{}{"$gte": "2024-02-01"}}, "Globex, Initech"},
+		{"or", map[string]interface{}{"$or": []interface{}{
+			map[string]interface{}{"name": "Acme"},
+			map[string]interface{}{"created_at": map[string]interface{}{"$gt": "2025-01-01"}},
+		}}, "Acme, Initech"},
+		{"no match", map[string]interface{}{"name": "Umbrella"}, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var result utils.ListResult[models.Company]
+			if err := desktest.DecodeResult(desktest.Call(t, c, "list_companies", map[string]interface{}{"filter": tt.filter}), &result); err != nil {
+				t.Fatal(err)
+			}
+			if got := names(result.Items); got != tt.want {
+				t.Errorf("companies = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCountCompanies(t *testing.T) {
+	api, c := setup(t)
+	addCompanies(api)
+
+	result := desktest.Call(t, c, "count_companies", map[string]interface{}{
+		"filter": map[string]interface{}{"created_at": map[string]interface{}{"$lt": "2025-01-01"}},
+	})
+	if got := desktest.ResultText(result); result.IsError || got != "2" {
+		t.Errorf("count = %s, want 2", got)
+	}
+}
+
+func TestGetCompany(t *testing.T) {
+	api, c := setup(t)
+	addCompanies(api)
+	id := api.Find("companies", "name", "Globex")
+
+	var company models.Company
+	if err := desktest.DecodeResult(desktest.Call(t, c, "get_company", map[string]interface{}{"id": strconv.Itoa(id)}), &company); err != nil {
+		t.Fatal(err)
+	}
+	if company.ID != id || company.Name != "Globex" {
+		t.Errorf("company = %d %q, want %d Globex", company.ID, company.Name, id)
+	}
+}
+
+func TestCreateCompany(t *testing.T) {
+	api, c := setup(t)
+
+	var company models.Company
+	if err := desktest.DecodeResult(desktest.Call(t, c, "create_company", map[string]interface{}{"name": "Acme"}), &company); err != nil {
+		t.Fatal(err)
+	}
+	if company.ID == 0 || company.Name != "Acme" {
+		t.Errorf("company = %+v, want the created company", company)
+	}
+
+	// The name is taken now
+	result := desktest.Call(t, c, "create_company", map[string]interface{}{"name": "Acme"})
+	if code, message := utils.ResultError(result, nil); code != desk.ErrValidation {
+		t.Errorf("code of duplicate = %q, want %q: %s", code, desk.ErrValidation, message)
+	}
+	if n := len(api.Records("companies")); n != 1 {
+		t.Errorf("%d companies stored, want 1", n)
+	}
+}
+
+func TestUpdateCompany(t *testing.T) {
+	api, c := setup(t)
+	addCompanies(api)
+	id := api.Find("companies", "name", "Acme")
+
+	var company models.Company
+	args := map[string]interface{}{"id": strconv.Itoa(id), "description": "Makes everything", "note": "Key account"}
+	if err := desktest.DecodeResult(desktest.Call(t, c, "update_company", args), &company); err != nil {
+		t.Fatal(err)
+	}
+	if company.Description != "Makes everything" || company.Note != "Key account" {
+		t.Errorf("company = %+v, want the new description and note", company)
+	}
+	stored, _ := api.Get("companies", id)
+	if stored["name"] != "Acme" {
+		t.Errorf("stored company = %v, want the name unchanged", stored)
+	}
+
+	result := desktest.Call(t, c, "update_company", map[string]interface{}{"id": strconv.Itoa(id)})
+	if !result.IsError {
+		t.Errorf("update without fields = %s, want an error", desktest.ResultText(result))
+	}
+}
+
+func TestDeleteCompany(t *testing.T) {
+	api, c := setup(t)
+	addCompanies(api)
+	id := api.Find("companies", "name", "Initech")
+
+	result := desktest.Call(t, c, "delete_company", map[string]interface{}{"id": strconv.Itoa(id), "confirm": false})
+	if code, _ := utils.ResultError(result, nil); code != utils.ErrInvalidArguments {
+		t.Errorf("code without confirm = %q, want %q", code, utils.ErrInvalidArguments)
+	}
+	if _, ok := api.Get("companies", id); !ok {
+		t.Fatal("company was deleted without confirm")
+	}
+
+	result = desktest.Call(t, c, "delete_company", map[string]interface{}{"id": strconv.Itoa(id), "confirm": true})
+	if result.IsError {
+		t.Fatal(desktest.ResultText(result))
+	}
+	if _, ok := api.Get("companies", id); ok {
+		t.Error("company was not deleted")
+	}
+}
+
+func TestCompanyErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		tool   string
+		args   map[string]interface{}
+		method string
+		path   string
+		status int
+		want   string
+	}{
+		{"list server error", "list_companies", nil, http.MethodGet, "companies.json", http.StatusInternalServerError, desk.ErrServer},
+		{"invalid filter", "list_companies", map[string]interface{}{"filter": map[string]interface{}{"domain": "acme.com"}}, "", "", 0, utils.ErrInvalidArguments},
+		{"get missing", "get_company", map[string]interface{}{"id": "999"}, "", "", 0, desk.ErrNotFound},
+		{"create forbidden", "create_company", map[string]interface{}{"name": "Umbrella"}, http.MethodPost, "companies.json", http.StatusForbidden, desk.ErrForbidden},
+		{"delete missing", "delete_company", map[string]interface{}{"id": "999", "confirm": true}, "", "", 0, desk.ErrNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			api, c := setup(t)
+			addCompanies(api)
+			if tt.status != 0 {
+				api.Fail(tt.method, tt.path, tt.status, 1)
+			}
+
+			result := desktest.Call(t, c, tt.tool, tt.args)
+			code, message := utils.ResultError(result, nil)
+			if code != tt.want {
+				t.Errorf("code = %q, want %q: %s", code, tt.want, message)
+			}
+		})
+	}
+}
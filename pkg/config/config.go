@@ -0,0 +1,275 @@
+// Package config loads the server configuration from a YAML file of named
+// profiles, one per Desk site. Environment variables take precedence over
+// the file.
+package config
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// tokenCommandTimeout bounds how long a token command may run
+const tokenCommandTimeout = 10 * time.Second
+
+// File is the configuration file
+type File struct {
+	// DefaultProfile is the profile used when none is selected
+	DefaultProfile string             `yaml:"default_profile"`
+	Profiles       map[string]Profile `yaml:"profiles"`
+}
+
+// Profile configures the server for a Desk site
+type Profile struct {
+	// Name is the name of the profile in the file
+	Name string `yaml:"-"`
+
+	URL string `yaml:"url"`
+	// The API token is taken from Token, the file TokenFile or the output of
+	// TokenCommand, in that order
+	Token        string `yaml:"token"`
+	TokenFile    string `yaml:"token_file"`
+	TokenCommand string `yaml:"token_command"`
+
+	// PageSize is the page size of list tools called without pageSize
+	PageSize     int      `yaml:"page_size"`
+	ReadOnly     *bool    `yaml:"read_only"`
+	EnableTools  []string `yaml:"enable_tools"`
+	DisableTools []string `yaml:"disable_tools"`
+
+	Transport string `yaml:"transport"`
+	Addr      string `yaml:"addr"`
+	// MetricsAddr is the address the metrics and health checks are served
+	// on, apart from the MCP endpoint
+	MetricsAddr string `yaml:"metrics_addr"`
+	// AllowedHosts are glob patterns of the Desk hosts HTTP sessions may
+	// send their own credentials for
+	AllowedHosts []string `yaml:"allowed_hosts"`
+	// Sites are the other profiles tool calls may be routed to with their
+	// site argument
+	Sites []string `yaml:"sites"`
+
+	Log LogConfig `yaml:"log"`
+}
+
+// LogConfig configures the server log
+type LogConfig struct {
+	// File is the file the log is appended to instead of stderr
+	File string `yaml:"file"`
+	// Format is text or json
+	Format string `yaml:"format"`
+	// Level is debug, info, warn or error
+	Level string `yaml:"level"`
+	// Audit is the file the calls of tools that modify data are appended to
+	Audit string `yaml:"audit"`
+}
+
+// DefaultPath returns the default location of the configuration file,
+// $XDG_CONFIG_HOME/deskmcp/config.yaml or ~/.config/deskmcp/config.yaml
+func DefaultPath() string {
+	dir := os.Getenv("XDG_CONFIG_HOME")
+	if dir == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return ""
+		}
+		dir = filepath.Join(home, ".config")
+	}
+	return filepath.Join(dir, "deskmcp", "config.yaml")
+}
+
+// Read reads a configuration file. Unknown keys are rejected so that typos
+// do not go unnoticed.
+func Read(path string) (*File, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var f File
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(&f); err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("invalid config file %s: %w", path, err)
+	}
+	for name, p := range f.Profiles {
+		p.Name = name
+		f.Profiles[name] = p
+	}
+	if f.DefaultProfile != "" {
+		if _, ok := f.Profiles[f.DefaultProfile]; !ok {
+			return nil, fmt.Errorf("invalid config file %s: default_profile %q is not a profile", path, f.DefaultProfile)
+		}
+	}
+	return &f, nil
+}
+
+// Open reads the configuration file at path, or at DefaultPath if path is
+// empty. A missing file at the default path yields an empty configuration.
+func Open(path string) (*File, error) {
+	explicit := path != ""
+	if !explicit {
+		path = DefaultPath()
+	}
+	if path == "" {
+		return &File{}, nil
+	}
+	f, err := Read(path)
+	if errors.Is(err, fs.ErrNotExist) && !explicit {
+		return &File{}, nil
+	}
+	return f, err
+}
+
+// Select returns the named profile with the environment variables applied.
+// The name defaults to the default_profile of the file, or its only profile.
+func (f *File) Select(name string) (Profile, error) {
+	p, err := f.Profile(name)
+	if err != nil {
+		return Profile{}, err
+	}
+	if err := p.ApplyEnv(); err != nil {
+		return Profile{}, err
+	}
+	return p, nil
+}
+
+// Profile returns the named profile, or the default profile when name is
+// empty. Without profiles, the default profile is empty.
+func (f *File) Profile(name string) (Profile, error) {
+	if name == "" {
+		name = f.DefaultProfile
+	}
+	if name == "" && len(f.Profiles) == 1 {
+		for only := range f.Profiles {
+			name = only
+		}
+	}
+	if name == "" {
+		if len(f.Profiles) > 1 {
+			return Profile{}, fmt.Errorf("the config file has several profiles (%s): select one with --profile or DESKMCP_PROFILE, or set default_profile",
+				strings.Join(f.Names(), ", "))
+		}
+		return Profile{}, nil
+	}
+	p, ok := f.Profiles[name]
+	if !ok {
+		return Profile{}, fmt.Errorf("no profile %q in the config file, expected one of %s", name, strings.Join(f.Names(), ", "))
+	}
+	return p, nil
+}
+
+// Names returns the names of the profiles in order
+func (f *File) Names() []string {
+	names := make([]string, 0, len(f.Profiles))
+	for name := range f.Profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// ApplyEnv overrides the profile with the environment variables that are set
+func (p *Profile) ApplyEnv() error {
+	if v := os.Getenv("DESK_API_URL"); v != "" {
+		p.URL = v
+	}
+	if v := os.Getenv("DESK_API_TOKEN"); v != "" {
+		p.Token, p.TokenFile, p.TokenCommand = v, "", ""
+	}
+	if v := os.Getenv("DESKMCP_TRANSPORT"); v != "" {
+		p.Transport = v
+	}
+	if v := os.Getenv("DESKMCP_ADDR"); v != "" {
+		p.Addr = v
+	}
+	if v := os.Getenv("DESKMCP_METRICS_ADDR"); v != "" {
+		p.MetricsAddr = v
+	}
+	if v := os.Getenv("DESKMCP_ALLOWED_HOSTS"); v != "" {
+		p.AllowedHosts = strings.Split(v, ",")
+	}
+	if v := os.Getenv("DESKMCP_SITES"); v != "" {
+		p.Sites = strings.Split(v, ",")
+	}
+	if v := os.Getenv("DESKMCP_READ_ONLY"); v != "" {
+		readOnly, err := strconv.ParseBool(v)
+		if err != nil {
+			return fmt.Errorf("invalid DESKMCP_READ_ONLY %q: %w", v, err)
+		}
+		p.ReadOnly = &readOnly
+	}
+	if v := os.Getenv("DESKMCP_ENABLE_TOOLS"); v != "" {
+		p.EnableTools = strings.Split(v, ",")
+	}
+	if v := os.Getenv("DESKMCP_DISABLE_TOOLS"); v != "" {
+		p.DisableTools = strings.Split(v, ",")
+	}
+	if v := os.Getenv("DESKMCP_PAGE_SIZE"); v != "" {
+		size, err := strconv.Atoi(v)
+		if err != nil {
+			return fmt.Errorf("invalid DESKMCP_PAGE_SIZE %q: %w", v, err)
+		}
+		p.PageSize = size
+	}
+	if v := os.Getenv("DESKMCP_LOG_FILE"); v != "" {
+		p.Log.File = v
+	}
+	if v := os.Getenv("DESKMCP_LOG_FORMAT"); v != "" {
+		p.Log.Format = v
+	}
+	if v := os.Getenv("DESKMCP_LOG_LEVEL"); v != "" {
+		p.Log.Level = v
+	}
+	if v := os.Getenv("DESKMCP_AUDIT_LOG"); v != "" {
+		p.Log.Audit = v
+	}
+	return nil
+}
+
+// ResolveToken returns the API token of the profile, reading the token file
+// or running the token command if the token is not set
+func (p Profile) ResolveToken(ctx context.Context) (string, error) {
+	switch {
+	case p.Token != "":
+		return p.Token, nil
+	case p.TokenFile != "":
+		data, err := os.ReadFile(expandHome(p.TokenFile))
+		if err != nil {
+			return "", fmt.Errorf("failed to read the token file of profile %q: %w", p.Name, err)
+		}
+		return strings.TrimSpace(string(data)), nil
+	case p.TokenCommand != "":
+		ctx, cancel := context.WithTimeout(ctx, tokenCommandTimeout)
+		defer cancel()
+		var stderr bytes.Buffer
+		cmd := exec.CommandContext(ctx, "sh", "-c", p.TokenCommand)
+		cmd.Stderr = &stderr
+		out, err := cmd.Output()
+		if err != nil {
+			return "", fmt.Errorf("token command of profile %q failed: %w: %s", p.Name, err, strings.TrimSpace(stderr.String()))
+		}
+		return strings.TrimSpace(string(out)), nil
+	}
+	return "", nil
+}
+
+func expandHome(path string) string {
+	if rest, ok := strings.CutPrefix(path, "~/"); ok {
+		if home, err := os.UserHomeDir(); err == nil {
+			return filepath.Join(home, rest)
+		}
+	}
+	return path
+}
@@ -0,0 +1,270 @@
+package config
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// envVars are the environment variables ApplyEnv reads
+var envVars = []string{
+	"DESK_API_URL", "DESK_API_TOKEN", "DESKMCP_TRANSPORT", "DESKMCP_ADDR", "DESKMCP_METRICS_ADDR",
+	"DESKMCP_ALLOWED_HOSTS", "DESKMCP_SITES", "DESKMCP_READ_ONLY", "DESKMCP_ENABLE_TOOLS",
+	"DESKMCP_DISABLE_TOOLS", "DESKMCP_PAGE_SIZE", "DESKMCP_LOG_FILE", "DESKMCP_LOG_FORMAT",
+	"DESKMCP_LOG_LEVEL", "DESKMCP_AUDIT_LOG",
+}
+
+// clearEnv unsets the environment variables of the configuration for the
+// duration of a test
+func clearEnv(t *testing.T) {
+	for _, name := range envVars {
+		t.Setenv(name, "")
+	}
+}
+
+// writeConfig writes a configuration file and returns its path
+func writeConfig(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+const twoProfiles = `
+default_profile: production
+profiles:
+  production:
+    url: https://acme.teamwork.com/desk/api/v2
+    token: prod-token
+    read_only: true
+  sandbox:
+    url: https://acme-sandbox.teamwork.com/desk/api/v2
+    token_file: /run/secrets/sandbox
+    page_size: 25
+    enable_tools: ["list_*", "get_*"]
+    log:
+      format: json
+`
+
+func TestRead(t *testing.T) {
+	f, err := Read(writeConfig(t, twoProfiles))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.Join(f.Names(), ","); got != "production,sandbox" {
+		t.Errorf("names = %s, want production,sandbox", got)
+	}
+	sandbox := f.Profiles["sandbox"]
+	if sandbox.Name != "sandbox" || sandbox.PageSize != 25 || sandbox.Log.Format != "json" || len(sandbox.EnableTools) != 2 {
+		t.Errorf("sandbox = %+v, want the profile of the file", sandbox)
+	}
+	if p := f.Profiles["production"]; p.ReadOnly == nil || !*p.ReadOnly {
+		t.Errorf("read_only of production = %v, want true", p.ReadOnly)
+	}
+
+	if f, err := Read(writeConfig(t, "")); err != nil || len(f.Profiles) != 0 {
+		t.Errorf("empty file = %+v, %v, want no profiles", f, err)
+	}
+}
+
+func TestReadErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    string
+	}{
+		{"unknown key", "profiles:\n  production:\n    ulr: https://acme.teamwork.com\n", "field ulr not found"},
+		{"unknown default", "default_profile: staging\nprofiles:\n  production: {}\n", `default_profile "staging" is not a profile`},
+		{"not yaml", "profiles: [", "invalid config file"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Read(writeConfig(t, tt.content))
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("error = %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestOpen(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", dir)
+	if got, want := DefaultPath(), filepath.Join(dir, "deskmcp", "config.yaml"); got != want {
+		t.Errorf("DefaultPath() = %s, want %s", got, want)
+	}
+
+	// A missing file is only an error when it was asked for
+	if f, err := Open(""); err != nil || len(f.Profiles) != 0 {
+		t.Errorf("Open without a file = %+v, %v, want an empty configuration", f, err)
+	}
+	if _, err := Open(filepath.Join(dir, "missing.yaml")); err == nil {
+		t.Error("Open of a missing file succeeded, want an error")
+	}
+
+	if err := os.MkdirAll(filepath.Join(dir, "deskmcp"), 0o700); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(DefaultPath(), []byte(twoProfiles), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if f, err := Open(""); err != nil || len(f.Profiles) != 2 {
+		t.Errorf("Open of the default file = %+v, %v, want its 2 profiles", f, err)
+	}
+}
+
+func TestProfile(t *testing.T) {
+	withDefault, err := Read(writeConfig(t, twoProfiles))
+	if err != nil {
+		t.Fatal(err)
+	}
+	withoutDefault := &File{Profiles: map[string]Profile{"a": {Name: "a"}, "b": {Name: "b"}}}
+	single := &File{Profiles: map[string]Profile{"only": {Name: "only"}}}
+
+	tests := []struct {
+		name    string
+		file    *File
+		profile string
+		want    string
+		err     string
+	}{
+		{"default profile", withDefault, "", "production", ""},
+		{"selected", withDefault, "sandbox", "sandbox", ""},
+		{"unknown", withDefault, "staging", "", `no profile "staging" in the config file, expected one of production, sandbox`},
+		{"only profile", single, "", "only", ""},
+		{"several without default", withoutDefault, "", "", "the config file has several profiles (a, b)"},
+		{"no profiles", &File{}, "", "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := tt.file.Profile(tt.profile)
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Errorf("error = %v, want %q", err, tt.err)
+				}
+				return
+			}
+			if err != nil || p.Name != tt.want {
+				t.Errorf("profile = %q, %v, want %q", p.Name, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestSelectAppliesEnv(t *testing.T) {
+	clearEnv(t)
+	f, err := Read(writeConfig(t, twoProfiles))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Without environment variables the profile is as in the file
+	p, err := f.Select("sandbox")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(p, f.Profiles["sandbox"]) {
+		t.Errorf("profile = %+v, want %+v", p, f.Profiles["sandbox"])
+	}
+
+	env := map[string]string{
+		"DESK_API_URL":          "https://other.teamwork.com/desk/api/v2",
+		"DESK_API_TOKEN":        "env-token",
+		"DESKMCP_TRANSPORT":     "http",
+		"DESKMCP_ADDR":          ":9090",
+		"DESKMCP_METRICS_ADDR":  "127.0.0.1:9091",
+		"DESKMCP_ALLOWED_HOSTS": "*.teamwork.com,desk.example.com",
+		"DESKMCP_SITES":         "production",
+		"DESKMCP_READ_ONLY":     "false",
+		"DESKMCP_ENABLE_TOOLS":  "list_*",
+		"DESKMCP_DISABLE_TOOLS": "delete_*,create_user",
+		"DESKMCP_PAGE_SIZE":     "50",
+		"DESKMCP_LOG_FILE":      "/tmp/deskmcp.log",
+		"DESKMCP_LOG_FORMAT":    "text",
+		"DESKMCP_LOG_LEVEL":     "debug",
+		"DESKMCP_AUDIT_LOG":     "/tmp/audit.jsonl",
+	}
+	for name, value := range env {
+		t.Setenv(name, value)
+	}
+	p, err = f.Select("sandbox")
+	if err != nil {
+		t.Fatal(err)
+	}
+	readOnly := false
+	want := Profile{
+		Name:         "sandbox",
+		URL:          "https://other.teamwork.com/desk/api/v2",
+		Token:        "env-token", // the token file of the profile no longer applies
+		PageSize:     50,
+		ReadOnly:     &readOnly,
+		EnableTools:  []string{"list_*"},
+		DisableTools: []string{"delete_*", "create_user"},
+		Transport:    "http",
+		Addr:         ":9090",
+		MetricsAddr:  "127.0.0.1:9091",
+		AllowedHosts: []string{"*.teamwork.com", "desk.example.com"},
+		Sites:        []string{"production"},
+		Log:          LogConfig{File: "/tmp/deskmcp.log", Format: "text", Level: "debug", Audit: "/tmp/audit.jsonl"},
+	}
+	if !reflect.DeepEqual(p, want) {
+		t.Errorf("profile = %+v, want %+v", p, want)
+	}
+	// The profiles of the file are left as they are
+	if f.Profiles["sandbox"].Token != "" || f.Profiles["sandbox"].PageSize != 25 {
+		t.Errorf("file profile = %+v, want it unchanged", f.Profiles["sandbox"])
+	}
+}
+
+func TestSelectInvalidEnv(t *testing.T) {
+	for name, value := range map[string]string{"DESKMCP_READ_ONLY": "maybe", "DESKMCP_PAGE_SIZE": "ten"} {
+		t.Run(name, func(t *testing.T) {
+			clearEnv(t)
+			t.Setenv(name, value)
+			if _, err := (&File{}).Select(""); err == nil || !strings.Contains(err.Error(), name) {
+				t.Errorf("error = %v, want an invalid %s", err, name)
+			}
+		})
+	}
+}
+
+func TestResolveToken(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	if err := os.WriteFile(filepath.Join(home, "token"), []byte("file-token\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		profile Profile
+		want    string
+		err     string
+	}{
+		{"token", Profile{Token: "t", TokenFile: "~/token"}, "t", ""},
+		{"token file", Profile{TokenFile: "~/token", TokenCommand: "echo command-token"}, "file-token", ""},
+		{"token command", Profile{TokenCommand: "echo ' command-token '"}, "command-token", ""},
+		{"none", Profile{}, "", ""},
+		{"missing file", Profile{Name: "p", TokenFile: "~/missing"}, "", `failed to read the token file of profile "p"`},
+		{"failing command", Profile{Name: "p", TokenCommand: "echo denied >&2; exit 1"}, "", `token command of profile "p" failed: exit status 1: denied`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			token, err := tt.profile.ResolveToken(context.Background())
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Errorf("error = %v, want %q", err, tt.err)
+				}
+				return
+			}
+			if err != nil || token != tt.want {
+				t.Errorf("token = %q, %v, want %q", token, err, tt.want)
+			}
+		})
+	}
+}
@@ -6,14 +6,27 @@ import (
 	"fmt"
 	"net/url"
 	"strconv"
+	"strings"
 
 	"github.com/mark3labs/mcp-go/mcp"
 	"github.com/mark3labs/mcp-go/server"
 	"github.com/ready4god2513/deskmcp/pkg/desk"
+	"github.com/ready4god2513/deskmcp/pkg/filter"
 	"github.com/ready4god2513/deskmcp/pkg/utils"
 	"github.com/ready4god2513/desksdkgo/models"
 )
 
+// customerFilter lists the fields customers can be filtered by
+var customerFilter = filter.NewSchema("customers",
+	filter.ID("id"),
+	filter.Text("email"),
+	filter.Text("firstName", "first_name"),
+	filter.Text("lastName", "last_name"),
+	filter.ID("company", "company_id"),
+	filter.Time("createdAt", "created_at"),
+	filter.Time("updatedAt", "updated_at"),
+)
+
 type CustomerHandler struct {
 	deskClient *desk.Client
 }
@@ -24,18 +37,27 @@ func NewCustomerHandler(deskClient *desk.Client) *CustomerHandler {
 	}
 }
 
+// client returns the Desk client to use for the request. Over HTTP each
+// session may supply its own credentials, otherwise the handler's client is used.
+func (h *CustomerHandler) client(ctx context.Context) *desk.Client {
+	return desk.ClientFromContext(ctx, h.deskClient)
+}
+
 func (h *CustomerHandler) RegisterTools(s *server.MCPServer) {
 	// List customers
-	s.AddTool(mcp.NewTool("list_customers",
+	utils.AddTool(s, mcp.NewTool("list_customers",
 		mcp.WithDescription("List all customers"),
 		mcp.WithObject("filter",
 			mcp.Description(`Optional filter for customers. Available fields:
+- id: Filter by customer ID
 - email: Filter by email address
 - first_name: Filter by first name
 - last_name: Filter by last name
 - company_id: Filter by company ID
 - created_at: Filter by creation date
-- updated_at: Filter by last update date`),
+- updated_at: Filter by last update date
+
+Supports the $eq, $ne, $lt, $lte, $gt, $gte, $in, $nin, $and and $or operators described on list_tickets.`),
 		),
 		mcp.WithString("orderBy",
 			mcp.Description("Order by field"),
@@ -54,10 +76,28 @@ func (h *CustomerHandler) RegisterTools(s *server.MCPServer) {
 			mcp.Min(1),
 			mcp.Max(100),
 		),
+		utils.WithListOptions(),
 	), h.listCustomers)
 
+	// Count customers
+	utils.AddTool(s, mcp.NewTool("count_customers",
+		mcp.WithDescription("Count all filtered customers"),
+		mcp.WithObject("filter",
+			mcp.Description(`Optional filter for customers. Available fields:
+- id: Filter by customer ID
+- email: Filter by email address
+- first_name: Filter by first name
+- last_name: Filter by last name
+- company_id: Filter by company ID
+- created_at: Filter by creation date
+- updated_at: Filter by last update date
+
+Supports the $eq, $ne, $lt, $lte, $gt, $gte, $in, $nin, $and and $or operators described on list_tickets.`),
+		),
+	), h.countCustomers)
+
 	// Get customer
-	s.AddTool(mcp.NewTool("get_customer",
+	utils.AddTool(s, mcp.NewTool("get_customer",
 		mcp.WithDescription("Get a specific customer by ID"),
 		mcp.WithString("id",
 			mcp.Required(),
@@ -66,7 +106,7 @@ func (h *CustomerHandler) RegisterTools(s *server.MCPServer) {
 	), h.getCustomer)
 
 	// Create customer
-	s.AddTool(mcp.NewTool("create_customer",
+	utils.AddTool(s, mcp.NewTool("create_customer",
 		mcp.WithDescription("Create a new customer"),
 		mcp.WithString("first_name",
 			mcp.Required(),
@@ -81,37 +121,108 @@ func (h *CustomerHandler) RegisterTools(s *server.MCPServer) {
 			mcp.Description("Customer's email address"),
 		),
 	), h.createCustomer)
+
+	// Update customer
+	utils.AddTool(s, mcp.NewTool("update_customer",
+		mcp.WithDescription("Update an existing customer. Only the provided fields are changed."),
+		mcp.WithString("id",
+			mcp.Required(),
+			mcp.Description("Customer ID"),
+		),
+		mcp.WithString("first_name",
+			mcp.Description("Customer's first name"),
+		),
+		mcp.WithString("last_name",
+			mcp.Description("Customer's last name"),
+		),
+		mcp.WithString("email",
+			mcp.Description("Customer's email address"),
+		),
+		mcp.WithString("organization",
+			mcp.Description("Customer's organization"),
+		),
+		mcp.WithString("phone",
+			mcp.Description("Customer's phone number"),
+		),
+		mcp.WithString("mobile",
+			mcp.Description("Customer's mobile number"),
+		),
+		mcp.WithString("address",
+			mcp.Description("Customer's address"),
+		),
+		mcp.WithString("notes",
+			mcp.Description("Notes about the customer"),
+		),
+	), h.updateCustomer)
+
+	// Delete customer
+	utils.AddTool(s, mcp.NewTool("delete_customer",
+		mcp.WithDescription("Delete a customer. This cannot be undone."),
+		mcp.WithString("id",
+			mcp.Required(),
+			mcp.Description("Customer ID"),
+		),
+		mcp.WithBoolean("confirm",
+			mcp.Required(),
+			mcp.Description("Must be true to confirm the deletion"),
+		),
+	), h.deleteCustomer)
+}
+
+// RegisterResources registers the desk://customers/{id} resource template
+func (h *CustomerHandler) RegisterResources(s *server.MCPServer) {
+	s.AddResourceTemplate(mcp.NewResourceTemplate("desk://customers/{id}", "Customer",
+		mcp.WithTemplateDescription("A customer by ID"),
+		mcp.WithTemplateMIMEType("application/json"),
+	), h.readCustomer)
 }
 
 func (h *CustomerHandler) listCustomers(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
 	params := url.Values{}
-	if filterParams, ok := request.Params.Arguments["filter"].(map[string]interface{}); ok {
-		for field, value := range filterParams {
-			params.Add(field, fmt.Sprintf("%v", value))
-		}
+	if err := customerFilter.AddToParams(params, request); err != nil {
+		return utils.InvalidArgumentResult(ctx, "Failed to list customers", err), nil
 	}
 
-	utils.AddPaginationToParams(params, request)
+	if err := utils.AddPaginationToParams(params, request); err != nil {
+		return utils.ArgumentErrorResult(err), nil
+	}
 
-	resp, err := h.deskClient.Client.Customers.List(ctx, params)
+	result, err := utils.ListPages(ctx, request, "customers", params, h.listPage)
 	if err != nil {
-		return mcp.NewToolResultError(fmt.Sprintf("Failed to list customers: %v", err)), nil
+		return utils.ErrorResult(ctx, "Failed to list customers", err), nil
 	}
-	data, err := json.Marshal(resp.Customers)
+	data, err := json.Marshal(result)
 	if err != nil {
 		return mcp.NewToolResultError(fmt.Sprintf("Failed to marshal customers: %v", err)), nil
 	}
 	return mcp.NewToolResultText(string(data)), nil
 }
 
-func (h *CustomerHandler) getCustomer(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
-	id, err := strconv.Atoi(request.Params.Arguments["id"].(string))
+// Count customers
+func (h *CustomerHandler) countCustomers(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	params := url.Values{}
+	if err := customerFilter.AddToParams(params, request); err != nil {
+		return utils.InvalidArgumentResult(ctx, "Failed to count customers", err), nil
+	}
+
+	utils.AddCountParams(params)
+
+	resp, err := h.client(ctx).Client.Customers.List(ctx, params)
 	if err != nil {
-		return mcp.NewToolResultError(fmt.Sprintf("Invalid customer ID: %v", err)), nil
+		return utils.ErrorResult(ctx, "Failed to count customers", err), nil
+	}
+
+	return mcp.NewToolResultText(strconv.Itoa(resp.Pagination.Records)), nil
+}
+
+func (h *CustomerHandler) getCustomer(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	var args utils.IDArguments
+	if err := utils.BindArguments(request, &args); err != nil {
+		return utils.ArgumentErrorResult(err), nil
 	}
-	resp, err := h.deskClient.Client.Customers.Get(ctx, id)
+	resp, err := h.client(ctx).Client.Customers.Get(ctx, args.ID)
 	if err != nil {
-		return mcp.NewToolResultError(fmt.Sprintf("Failed to get customer: %v", err)), nil
+		return utils.ErrorResult(ctx, "Failed to get customer", err), nil
 	}
 	data, err := json.Marshal(resp.Customer)
 	if err != nil {
@@ -121,14 +232,22 @@ func (h *CustomerHandler) getCustomer(ctx context.Context, request mcp.CallToolR
 }
 
 func (h *CustomerHandler) createCustomer(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	var args struct {
+		FirstName string `arg:"first_name"`
+		LastName  string `arg:"last_name"`
+		Email     string `arg:"email"`
+	}
+	if err := utils.BindArguments(request, &args); err != nil {
+		return utils.ArgumentErrorResult(err), nil
+	}
 	customer := &models.Customer{
-		FirstName: request.Params.Arguments["first_name"].(string),
-		LastName:  request.Params.Arguments["last_name"].(string),
-		Email:     request.Params.Arguments["email"].(string),
+		FirstName: args.FirstName,
+		LastName:  args.LastName,
+		Email:     args.Email,
 	}
-	resp, err := h.deskClient.Client.Customers.Create(ctx, customer)
+	resp, err := h.client(ctx).Client.Customers.Create(ctx, customer)
 	if err != nil {
-		return mcp.NewToolResultError(fmt.Sprintf("Failed to create customer: %v", err)), nil
+		return utils.ErrorResult(ctx, "Failed to create customer", err), nil
 	}
 	data, err := json.Marshal(resp.Customer)
 	if err != nil {
@@ -136,3 +255,150 @@ func (h *CustomerHandler) createCustomer(ctx context.Context, request mcp.CallTo
 	}
 	return mcp.NewToolResultText(string(data)), nil
 }
+
+func (h *CustomerHandler) updateCustomer(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	var args struct {
+		ID           int     `arg:"id"`
+		FirstName    *string `arg:"first_name" field:"firstName"`
+		LastName     *string `arg:"last_name" field:"lastName"`
+		Email        *string `arg:"email" field:"email"`
+		Organization *string `arg:"organization" field:"organization"`
+		Phone        *string `arg:"phone" field:"phone"`
+		Mobile       *string `arg:"mobile" field:"mobile"`
+		Address      *string `arg:"address" field:"address"`
+		Notes        *string `arg:"notes" field:"notes"`
+	}
+	if err := utils.BindArguments(request, &args); err != nil {
+		return utils.ArgumentErrorResult(err), nil
+	}
+	fields := utils.ChangedFields(&args)
+	if len(fields) == 0 {
+		return mcp.NewToolResultError("No fields to update were provided"), nil
+	}
+
+	var resp models.CustomerResponse
+	if err := h.client(ctx).Update(ctx, "customers", "customer", args.ID, fields, &resp); err != nil {
+		return utils.ErrorResult(ctx, "Failed to update customer", err), nil
+	}
+	data, err := json.Marshal(resp.Customer)
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Failed to marshal customer: %v", err)), nil
+	}
+	return mcp.NewToolResultText(string(data)), nil
+}
+
+func (h *CustomerHandler) deleteCustomer(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	var args utils.DeleteArguments
+	if err := utils.BindArguments(request, &args); err != nil {
+		return utils.ArgumentErrorResult(err), nil
+	}
+	if !args.Confirm {
+		return utils.InvalidArgumentResult(ctx, fmt.Sprintf("Not deleting customer %d", args.ID), &utils.ArgumentError{Argument: "confirm", Message: "must be true to delete it"}), nil
+	}
+	if err := h.client(ctx).Delete(ctx, "customers", args.ID); err != nil {
+		return utils.ErrorResult(ctx, "Failed to delete customer", err), nil
+	}
+	return mcp.NewToolResultText(fmt.Sprintf("Customer %d deleted", args.ID)), nil
+}
+
+// Get returns the customer with the given ID
+func (h *CustomerHandler) Get(ctx context.Context, id int) (models.Customer, error) {
+	resp, err := h.client(ctx).Client.Customers.Get(ctx, id)
+	if err != nil {
+		return models.Customer{}, err
+	}
+	return resp.Customer, nil
+}
+
+// FindCustomers returns up to maxRecords customers matching the filter, most
+// recently updated first. The filter takes the same fields and operators as
+// the filter argument of list_customers, with values as decoded from JSON.
+func (h *CustomerHandler) FindCustomers(ctx context.Context, where map[string]interface{}, maxRecords int) ([]models.Customer, utils.PageInfo, error) {
+	return h.findCustomers(ctx, where, "desc", maxRecords)
+}
+
+// FindUpdatedCustomers is like FindCustomers, but returns the least recently updated
+// customers first, so that the customers changed since a time can be fetched in
+// batches of maxRecords
+func (h *CustomerHandler) FindUpdatedCustomers(ctx context.Context, where map[string]interface{}, maxRecords int) ([]models.Customer, utils.PageInfo, error) {
+	return h.findCustomers(ctx, where, "asc", maxRecords)
+}
+
+func (h *CustomerHandler) findCustomers(ctx context.Context, where map[string]interface{}, orderMode string, maxRecords int) ([]models.Customer, utils.PageInfo, error) {
+	params := url.Values{}
+	if len(where) > 0 {
+		encoded, err := customerFilter.Compile(where)
+		if err != nil {
+			return nil, utils.PageInfo{}, err
+		}
+		params.Set("filter", encoded)
+	}
+
+	pageSize := utils.MaxPageSize
+	if maxRecords > 0 && maxRecords < pageSize {
+		pageSize = maxRecords
+	}
+	params.Set("orderBy", "updatedAt")
+	params.Set("orderMode", orderMode)
+	params.Set("page", "1")
+	params.Set("pageSize", strconv.Itoa(pageSize))
+
+	opts := utils.ListOptions{FetchAll: true, MaxRecords: maxRecords}
+	result, err := utils.FetchPages(ctx, "customers", params, opts, h.listPage)
+	if err != nil {
+		return nil, utils.PageInfo{}, err
+	}
+	return result.Items, result.Pagination, nil
+}
+
+// FindOrCreate returns the ID of the customer with the given email address,
+// creating the customer if none exists yet
+func (h *CustomerHandler) FindOrCreate(ctx context.Context, email, firstName, lastName string) (int, error) {
+	encoded, err := customerFilter.Compile(map[string]interface{}{"email": email})
+	if err != nil {
+		return 0, err
+	}
+
+	params := url.Values{}
+	params.Set("filter", encoded)
+	params.Set("pageSize", "1")
+	resp, err := h.client(ctx).Client.Customers.List(ctx, params)
+	if err != nil {
+		return 0, fmt.Errorf("failed to look up customer: %w", err)
+	}
+	for _, c := range resp.Customers {
+		if strings.EqualFold(c.Email, email) {
+			return c.ID, nil
+		}
+	}
+
+	created, err := h.client(ctx).Client.Customers.Create(ctx, &models.Customer{
+		FirstName: firstName,
+		LastName:  lastName,
+		Email:     email,
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to create customer: %w", err)
+	}
+	return created.Customer.ID, nil
+}
+
+func (h *CustomerHandler) readCustomer(ctx context.Context, request mcp.ReadResourceRequest) ([]mcp.ResourceContents, error) {
+	id, err := utils.ResourceID(request)
+	if err != nil {
+		return nil, err
+	}
+	customer, err := h.Get(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get customer %d: %w", id, err)
+	}
+	return utils.JSONResource(request.Params.URI, customer)
+}
+
+func (h *CustomerHandler) listPage(ctx context.Context, params url.Values) ([]models.Customer, models.Pagination, error) {
+	resp, err := h.client(ctx).Client.Customers.List(ctx, params)
+	if err != nil {
+		return nil, models.Pagination{}, err
+	}
+	return resp.Customers, resp.Pagination, nil
+}
@@ -0,0 +1,311 @@
+package customers
+
+import (
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/mark3labs/mcp-go/client"
+	"github.com/mark3labs/mcp-go/server"
+	"github.com/ready4god2513/deskmcp/pkg/desk"
+	"github.com/ready4god2513/deskmcp/pkg/desktest"
+	"github.com/ready4god2513/deskmcp/pkg/utils"
+	"github.com/ready4god2513/desksdkgo/models"
+)
+
+// setup starts a fake Desk site and returns it with an MCP client of a
+// server with the customer tools
+func setup(t *testing.T) (*desktest.Server, *client.Client) {
+	t.Helper()
+	api, c := desktest.Setup(t, func(s *server.MCPServer, deskClient *desk.Client) {
+		NewCustomerHandler(deskClient).RegisterTools(s)
+	})
+	return api, c
+}
+
+// addCustomers stores three customers, the first two of one company and
+// the last one created a year later
+func addCustomers(api *desktest.Server) (acme int) {
+	acme = api.Add("companies", map[string]interface{}{"name": "Acme"})
+	company := map[string]interface{}{"id": acme, "type": "companies"}
+	api.Add("customers", map[string]interface{}{"email": "ada@example.com", "firstName": "Ada", "lastName": "Lovelace", "company": company, "createdAt": "2024-01-10T00:00:00Z"})
+	api.Add("customers", map[string]interface{}{"email": "charles@example.com", "firstName": "Charles", "lastName": "Babbage", "company": company, "createdAt": "2024-03-01T00:00:00Z"})
+	api.Add("customers", map[string]interface{}{"email": "grace@example.com", "firstName": "Grace", "lastName": "Hopper", "createdAt": "2025-02-01T00:00:00Z"})
+	return acme
+}
+
+// emails returns the email addresses of customers in alphabetical order
+func emails(customers []models.Customer) string {
+	s := make([]string, len(customers))
+	for i, customer := range customers {
+		s[i] = customer.Email
+	}
+	sort.Strings(s)
+	return strings.Join(s, ", ")
+}
+
+func TestListCustomers(t *testing.T) {
+	api, c := setup(t)
+	addCustomers(api)
+
+	var result utils.ListResult[models.Customer]
+	if err := desktest.DecodeResult(desktest.Call(t, c, "list_customers", nil), &result); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := emails(result.Items), "ada@example.com, charles@example.com, grace@example.com"; got != want {
+		t.Errorf("customers = %s, want %s", got, want)
+	}
+	if result.Pagination.Records != 3 || result.Pagination.HasMore {
+		t.Errorf("pagination = %+v, want 3 records and no more", result.Pagination)
+	}
+}
+
+func TestListCustomersFetchAll(t *testing.T) {
+	api, c := setup(t)
+	for i := 0; i < 7; i++ {
+		api.Add("customers", map[string]interface{}{"email": "customer" + strconv.Itoa(i) + "@example.com"})
+	}
+
+	var result utils.ListResult[models.Customer]
+	args := map[string]interface{}{"pageSize": 2, "fetch_all": true, "max_records": 5}
+	if err := desktest.DecodeResult(desktest.Call(t, c, "list_customers", args), &result); err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Items) != 5 || !result.Pagination.HasMore || result.Pagination.NextCursor == "" {
+		t.Fatalf("got %d customers, %+v, want 5 and a cursor", len(result.Items), result.Pagination)
+	}
+
+	var rest utils.ListResult[models.Customer]
+	args = map[string]interface{}{"cursor": result.Pagination.NextCursor, "fetch_all": true}
+	if err := desktest.DecodeResult(desktest.Call(t, c, "list_customers", args), &rest); err != nil {
+		t.Fatal(err)
+	}
+	seen := make(map[int]bool)
+	for _, customer := range append(result.Items, rest.Items...) {
+		if seen[customer.ID] {
+			t.Errorf("customer %d returned twice", customer.ID)
+		}
+		seen[customer.ID] = true
+	}
+	if len(seen) != 7 || rest.Pagination.HasMore {
+		t.Errorf("got %d customers in total, %+v, want 7 and no more", len(seen), rest.Pagination)
+	}
+}
+
+func TestListCustomersFilter(t *testing.T) {
+	api, c := setup(t)
+	acme := addCustomers(api)
+
+	tests := []struct {
+		name   string
+		filter map[string]interface{}
+		want   string
+	}{
+		{"email", map[string]interface{}{"email": "Grace@Example.com"}, "grace@example.com"},
+		{"company", map[string]interface{}{"company_id": acme}, "ada@example.com, charles@example.com"},
+		{"created after", map[string]interface{}{"created_at": map[string]interface{}{"$gte": "2024-02-01"}}, "charles@example.com, grace@example.com"},
+		{"created before", map[string]interface{}{"created_at": map[string]interface{}{"$lt": "2024-02-01"}}, "ada@example.com"},
+		{"in", map[string]interface{}{"first_name": map[string]interface{}{"$in": []interface{}{"Ada", "Grace"}}}, "ada@example.com, grace@example.com"},
+		{"nin", map[string]interface{}{"last_name": map[string]interface{}{"$nin": []interface{}{"Lovelace"}}}, "charles@example.com, grace@example.com"},
+		{"or", map[string]interface{}{"$or": []interface{}{
+			map[string]interface{}{"email": "ada@example.com"},
+			map[string]interface{}{"last_name": "Hopper"},
+		}}, "ada@example.com, grace@example.com"},
+		{"no match", map[string]interface{}{"company_id": acme, "first_name": "Grace"}, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var result utils.ListResult[models.Customer]
+			if err := desktest.DecodeResult(desktest.Call(t, c, "list_customers", map[string]interface{}{"filter": tt.filter}), &result); err != nil {
+				t.Fatal(err)
+			}
+			if got := emails(result.Items); got != tt.want {
+				t.Errorf("customers = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestListCustomersInvalidFilter(t *testing.T) {
+	api, c := setup(t)
+	addCustomers(api)
+
+	tests := map[string]map[string]interface{}{
+		"unknown field": {"nickname": "Ada"},
+		"invalid date":  {"created_at": map[string]interface{}{"$gte": "last week"}},
+		"unknown op":    {"email": map[string]interface{}{"$like": "ada"}},
+	}
+	for name, filter := range tests {
+		t.Run(name, func(t *testing.T) {
+			result := desktest.Call(t, c, "list_customers", map[string]interface{}{"filter": filter})
+			if code, message := utils.ResultError(result, nil); code != utils.ErrInvalidArguments {
+				t.Errorf("code = %q, want %q: %s", code, utils.ErrInvalidArguments, message)
+			}
+		})
+	}
+	if n := len(api.Requests()); n != 0 {
+		t.Errorf("%d requests sent to Desk, want none", n)
+	}
+}
+
+func TestCountCustomers(t *testing.T) {
+	api, c := setup(t)
+	acme := addCustomers(api)
+
+	tests := []struct {
+		filter map[string]interface{}
+		want   string
+	}{
+		{nil, "3"},
+		{map[string]interface{}{"company_id": acme}, "2"},
+		{map[string]interface{}{"email": "nobody@example.com"}, "0"},
+	}
+	for _, tt := range tests {
+		args := map[string]interface{}{}
+		if tt.filter != nil {
+			args["filter"] = tt.filter
+		}
+		result := desktest.Call(t, c, "count_customers", args)
+		if got := desktest.ResultText(result); result.IsError || got != tt.want {
+			t.Errorf("count with filter %v = %s, want %s", tt.filter, got, tt.want)
+		}
+	}
+}
+
+func TestGetCustomer(t *testing.T) {
+	api, c := setup(t)
+	addCustomers(api)
+	id := api.Find("customers", "email", "charles@example.com")
+
+	var customer models.Customer
+	if err := desktest.DecodeResult(desktest.Call(t, c, "get_customer", map[string]interface{}{"id": strconv.Itoa(id)}), &customer); err != nil {
+		t.Fatal(err)
+	}
+	if customer.ID != id || customer.FirstName != "Charles" || customer.LastName != "Babbage" {
+		t.Errorf("customer = %d %s %s, want %d Charles Babbage", customer.ID, customer.FirstName, customer.LastName, id)
+	}
+}
+
+func TestCreateCustomer(t *testing.T) {
+	api, c := setup(t)
+
+	var customer models.Customer
+	args := map[string]interface{}{"first_name": "Grace", "last_name": "Hopper", "email": "grace@example.com"}
+	if err := desktest.DecodeResult(desktest.Call(t, c, "create_customer", args), &customer); err != nil {
+		t.Fatal(err)
+	}
+	if customer.ID == 0 || customer.Email != "grace@example.com" {
+		t.Errorf("customer = %+v, want the created customer", customer)
+	}
+	stored, ok := api.Get("customers", customer.ID)
+	if !ok || stored["firstName"] != "Grace" || stored["lastName"] != "Hopper" {
+		t.Errorf("stored customer = %v", stored)
+	}
+
+	// The email address is taken now
+	result := desktest.Call(t, c, "create_customer", args)
+	if code, message := utils.ResultError(result, nil); code != desk.ErrValidation {
+		t.Errorf("code of duplicate = %q, want %q: %s", code, desk.ErrValidation, message)
+	}
+	if n := len(api.Records("customers")); n != 1 {
+		t.Errorf("%d customers stored, want 1", n)
+	}
+}
+
+func TestCreateCustomerMissingArguments(t *testing.T) {
+	api, c := setup(t)
+
+	result := desktest.Call(t, c, "create_customer", map[string]interface{}{"first_name": "Grace"})
+	if !result.IsError {
+		t.Fatalf("result = %s, want an error", desktest.ResultText(result))
+	}
+	text := desktest.ResultText(result)
+	for _, arg := range []string{"last_name", "email"} {
+		if !strings.Contains(text, arg) {
+			t.Errorf("error %q does not name %s", text, arg)
+		}
+	}
+	if n := len(api.Requests()); n != 0 {
+		t.Errorf("%d requests sent to Desk, want none", n)
+	}
+}
+
+func TestUpdateCustomer(t *testing.T) {
+	api, c := setup(t)
+	addCustomers(api)
+	id := api.Find("customers", "email", "ada@example.com")
+
+	var customer models.Customer
+	args := map[string]interface{}{"id": strconv.Itoa(id), "last_name": "King", "notes": "Prefers email"}
+	if err := desktest.DecodeResult(desktest.Call(t, c, "update_customer", args), &customer); err != nil {
+		t.Fatal(err)
+	}
+	if customer.LastName != "King" || customer.Notes != "Prefers email" {
+		t.Errorf("customer = %s %s, %q, want the new last name and notes", customer.FirstName, customer.LastName, customer.Notes)
+	}
+
+	// Fields that were not passed are left alone
+	stored, _ := api.Get("customers", id)
+	if stored["firstName"] != "Ada" || stored["email"] != "ada@example.com" {
+		t.Errorf("stored customer = %v, want the other fields unchanged", stored)
+	}
+}
+
+func TestDeleteCustomer(t *testing.T) {
+	api, c := setup(t)
+	addCustomers(api)
+	id := api.Find("customers", "email", "grace@example.com")
+
+	result := desktest.Call(t, c, "delete_customer", map[string]interface{}{"id": strconv.Itoa(id), "confirm": false})
+	if code, _ := utils.ResultError(result, nil); code != utils.ErrInvalidArguments {
+		t.Errorf("code without confirm = %q, want %q", code, utils.ErrInvalidArguments)
+	}
+	if _, ok := api.Get("customers", id); !ok {
+		t.Fatal("customer was deleted without confirm")
+	}
+
+	result = desktest.Call(t, c, "delete_customer", map[string]interface{}{"id": strconv.Itoa(id), "confirm": true})
+	if result.IsError {
+		t.Fatal(desktest.ResultText(result))
+	}
+	if _, ok := api.Get("customers", id); ok {
+		t.Error("customer was not deleted")
+	}
+}
+
+func TestCustomerErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		tool   string
+		args   map[string]interface{}
+		method string
+		path   string
+		status int
+		want   string
+	}{
+		{"list server error", "list_customers", nil, http.MethodGet, "customers.json", http.StatusBadGateway, desk.ErrServer},
+		{"list with fetch_all", "list_customers", map[string]interface{}{"pageSize": 1, "fetch_all": true}, http.MethodGet, "customers.json", http.StatusServiceUnavailable, desk.ErrServer},
+		{"count unauthorized", "count_customers", nil, http.MethodGet, "customers.json", http.StatusUnauthorized, desk.ErrUnauthorized},
+		{"get missing", "get_customer", map[string]interface{}{"id": "999"}, "", "", 0, desk.ErrNotFound},
+		{"create rate limited", "create_customer", map[string]interface{}{"first_name": "A", "last_name": "B", "email": "ab@example.com"}, http.MethodPost, "customers.json", http.StatusTooManyRequests, desk.ErrRateLimited},
+		{"update missing", "update_customer", map[string]interface{}{"id": "999", "notes": "x"}, "", "", 0, desk.ErrNotFound},
+		{"delete forbidden", "delete_customer", map[string]interface{}{"id": "1", "confirm": true}, http.MethodDelete, "customers", http.StatusForbidden, desk.ErrForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			api, c := setup(t)
+			addCustomers(api)
+			if tt.status != 0 {
+				api.Fail(tt.method, tt.path, tt.status, 1)
+			}
+
+			result := desktest.Call(t, c, tt.tool, tt.args)
+			code, message := utils.ResultError(result, nil)
+			if code != tt.want {
+				t.Errorf("code = %q, want %q: %s", code, tt.want, message)
+			}
+		})
+	}
+}
@@ -0,0 +1,321 @@
+package desk
+
+import (
+	"bytes"
+	"container/list"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// CachedResources are the resources whose responses are cached. They hold
+// reference data that rarely changes.
+var CachedResources = []string{"ticketstatuses", "tickettypes", "ticketpriorities", "ticketsources", "tags", "users", "inboxes"}
+
+// maxCachedBody bounds the size of a cached response
+const maxCachedBody = 2 << 20
+
+// CacheConfig configures a Cache
+type CacheConfig struct {
+	// TTL is how long responses are cached
+	TTL time.Duration
+	// ResourceTTLs overrides the TTL of individual resources. A TTL of zero
+	// turns caching off for the resource.
+	ResourceTTLs map[string]time.Duration
+	// MaxEntries bounds the number of cached responses. The least recently
+	// used responses are evicted first.
+	MaxEntries int
+}
+
+// ParseResourceTTLs parses a comma separated list of resource=duration
+// pairs, e.g. "users=1m,tags=1h"
+func ParseResourceTTLs(list string) (map[string]time.Duration, error) {
+	ttls := make(map[string]time.Duration)
+	for _, pair := range strings.Split(list, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		resource, value, ok := strings.Cut(pair, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid cache TTL %q, expected resource=duration", pair)
+		}
+		resource = strings.TrimSpace(resource)
+		if !isCachedResource(resource) {
+			return nil, fmt.Errorf("invalid cache TTL %q: %s is not cached, expected one of %s", pair, resource, strings.Join(CachedResources, ", "))
+		}
+		ttl, err := time.ParseDuration(strings.TrimSpace(value))
+		if err != nil || ttl < 0 {
+			return nil, fmt.Errorf("invalid cache TTL %q: %q is not a duration", pair, value)
+		}
+		ttls[resource] = ttl
+	}
+	return ttls, nil
+}
+
+func isCachedResource(resource string) bool {
+	for _, r := range CachedResources {
+		if r == resource {
+			return true
+		}
+	}
+	return false
+}
+
+// Cache caches the responses of GET requests for reference data. It is shared
+// by the clients of all sessions: responses are cached per credentials, and a
+// write to a resource through any client of a site drops the cached responses
+// of that resource for every client of the site.
+type Cache struct {
+	config CacheConfig
+
+	mu      sync.Mutex
+	entries map[string]*list.Element
+	lru     *list.List
+	// generations counts the writes to each resource of a site, so that a
+	// response fetched before a write is not cached after it
+	generations map[string]uint64
+
+	hits, misses, evictions, invalidations int64
+}
+
+type cacheEntry struct {
+	key      string
+	scope    string
+	resource string
+	status   int
+	header   http.Header
+	body     []byte
+	expires  time.Time
+}
+
+// NewCache returns an empty cache
+func NewCache(config CacheConfig) *Cache {
+	return &Cache{
+		config:      config,
+		entries:     make(map[string]*list.Element),
+		lru:         list.New(),
+		generations: make(map[string]uint64),
+	}
+}
+
+func (c *Cache) ttl(resource string) time.Duration {
+	if ttl, ok := c.config.ResourceTTLs[resource]; ok {
+		return ttl
+	}
+	return c.config.TTL
+}
+
+// get returns the cached response for key, if it has not expired
+func (c *Cache) get(key string) *cacheEntry {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	el, ok := c.entries[key]
+	if !ok {
+		c.misses++
+		return nil
+	}
+	entry := el.Value.(*cacheEntry)
+	if time.Now().After(entry.expires) {
+		c.remove(el)
+		c.misses++
+		return nil
+	}
+	c.lru.MoveToFront(el)
+	c.hits++
+	return entry
+}
+
+// put caches a response unless the resource was written to since generation
+func (c *Cache) put(entry *cacheEntry, generation uint64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.generations[entry.scope+entry.resource] != generation {
+		return
+	}
+	if el, ok := c.entries[entry.key]; ok {
+		c.remove(el)
+	}
+	c.entries[entry.key] = c.lru.PushFront(entry)
+	for c.config.MaxEntries > 0 && c.lru.Len() > c.config.MaxEntries {
+		c.remove(c.lru.Back())
+		c.evictions++
+	}
+}
+
+func (c *Cache) generation(scope, resource string) uint64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.generations[scope+resource]
+}
+
+// invalidate drops the cached responses of a resource of a site
+func (c *Cache) invalidate(scope, resource string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.generations[scope+resource]++
+	for _, el := range c.entries {
+		if entry := el.Value.(*cacheEntry); entry.scope == scope && entry.resource == resource {
+			c.remove(el)
+		}
+	}
+	c.invalidations++
+}
+
+func (c *Cache) remove(el *list.Element) {
+	c.lru.Remove(el)
+	delete(c.entries, el.Value.(*cacheEntry).key)
+}
+
+// CacheStats describes the state of a cache
+type CacheStats struct {
+	Entries       int                           `json:"entries"`
+	MaxEntries    int                           `json:"max_entries"`
+	Hits          int64                         `json:"hits"`
+	Misses        int64                         `json:"misses"`
+	HitRate       float64                       `json:"hit_rate"`
+	Evictions     int64                         `json:"evictions"`
+	Invalidations int64                         `json:"invalidations"`
+	Resources     map[string]ResourceCacheStats `json:"resources"`
+}
+
+// ResourceCacheStats describes the cached responses of a resource
+type ResourceCacheStats struct {
+	Entries int    `json:"entries"`
+	TTL     string `json:"ttl"`
+}
+
+// Stats returns the current statistics of the cache
+func (c *Cache) Stats() CacheStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	stats := CacheStats{
+		Entries:       c.lru.Len(),
+		MaxEntries:    c.config.MaxEntries,
+		Hits:          c.hits,
+		Misses:        c.misses,
+		Evictions:     c.evictions,
+		Invalidations: c.invalidations,
+		Resources:     make(map[string]ResourceCacheStats, len(CachedResources)),
+	}
+	if lookups := c.hits + c.misses; lookups > 0 {
+		stats.HitRate = float64(c.hits) / float64(lookups)
+	}
+	for _, resource := range CachedResources {
+		stats.Resources[resource] = ResourceCacheStats{TTL: c.ttl(resource).String()}
+	}
+	for _, el := range c.entries {
+		entry := el.Value.(*cacheEntry)
+		r := stats.Resources[entry.resource]
+		r.Entries++
+		stats.Resources[entry.resource] = r
+	}
+	return stats
+}
+
+// cacheTransport serves the reference data requests of a client from the
+// cache
+type cacheTransport struct {
+	cache    *Cache
+	next     http.RoundTripper
+	basePath string
+	// scope identifies the site of the client
+	scope string
+	// credentials identifies the credentials of the client
+	credentials string
+}
+
+func newCacheTransport(cache *Cache, next http.RoundTripper, baseURL, basePath, apiKey string) *cacheTransport {
+	sum := sha256.Sum256([]byte(apiKey))
+	return &cacheTransport{
+		cache:       cache,
+		next:        next,
+		basePath:    basePath,
+		scope:       cacheScope(baseURL),
+		credentials: string(sum[:]),
+	}
+}
+
+// cacheScope identifies a site in the cache
+func cacheScope(baseURL string) string {
+	return strings.TrimSuffix(baseURL, "/") + "|"
+}
+
+// resource returns the resource a request path refers to, e.g. "tags" for
+// /desk/api/v2/tags/12.json
+func (t *cacheTransport) resource(path string) string {
+	path = strings.TrimPrefix(strings.TrimPrefix(path, t.basePath), "/")
+	first, _, _ := strings.Cut(path, "/")
+	return strings.TrimSuffix(first, ".json")
+}
+
+// RoundTrip implements http.RoundTripper
+func (t *cacheTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resource := t.resource(req.URL.Path)
+	if !isCachedResource(resource) {
+		return t.next.RoundTrip(req)
+	}
+
+	switch req.Method {
+	case http.MethodGet:
+	case http.MethodHead, http.MethodOptions:
+		return t.next.RoundTrip(req)
+	default:
+		resp, err := t.next.RoundTrip(req)
+		t.cache.invalidate(t.scope, resource)
+		return resp, err
+	}
+
+	ttl := t.cache.ttl(resource)
+	if ttl <= 0 {
+		return t.next.RoundTrip(req)
+	}
+	key := t.scope + t.credentials + req.URL.String()
+	if entry := t.cache.get(key); entry != nil {
+		return entry.response(req), nil
+	}
+
+	generation := t.cache.generation(t.scope, resource)
+	resp, err := t.next.RoundTrip(req)
+	if err != nil || resp.StatusCode != http.StatusOK {
+		return resp, err
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxCachedBody+1))
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+	if len(body) <= maxCachedBody {
+		t.cache.put(&cacheEntry{
+			key:      key,
+			scope:    t.scope,
+			resource: resource,
+			status:   resp.StatusCode,
+			header:   resp.Header.Clone(),
+			body:     body,
+			expires:  time.Now().Add(ttl),
+		}, generation)
+	}
+	return resp, nil
+}
+
+// response rebuilds the cached response for a request
+func (e *cacheEntry) response(req *http.Request) *http.Response {
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", e.status, http.StatusText(e.status)),
+		StatusCode:    e.status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        e.header.Clone(),
+		Body:          io.NopCloser(bytes.NewReader(e.body)),
+		ContentLength: int64(len(e.body)),
+		Request:       req,
+	}
+}
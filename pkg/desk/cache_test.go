@@ -0,0 +1,239 @@
+package desk
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+)
+
+// countingAPI is a Desk API that answers every request with an empty object,
+// or with status if it is set, and counts the requests per method and path
+type countingAPI struct {
+	*httptest.Server
+
+	mu       sync.Mutex
+	requests map[string]int
+	status   int
+}
+
+func newCountingAPI(t *testing.T) *countingAPI {
+	api := &countingAPI{requests: make(map[string]int)}
+	api.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		api.mu.Lock()
+		api.requests[r.Method+" "+r.URL.Path]++
+		status := api.status
+		api.mu.Unlock()
+		if status != 0 {
+			w.WriteHeader(status)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{}`))
+	}))
+	t.Cleanup(api.Close)
+	return api
+}
+
+func (api *countingAPI) count(method, path string) int {
+	api.mu.Lock()
+	defer api.mu.Unlock()
+	return api.requests[method+" /desk/api/v2/"+path]
+}
+
+// client returns a client of the API that caches in cache and does not retry
+func (api *countingAPI) client(cache *Cache, token string) *Client {
+	return NewClient(api.URL+"/desk/api/v2", token, WithCache(cache), WithRetry(RetryConfig{}))
+}
+
+func get(t *testing.T, c *Client, path string, params url.Values) {
+	t.Helper()
+	if err := c.Do(context.Background(), http.MethodGet, path, params, nil, nil); err != nil {
+		t.Fatalf("GET %s: %v", path, err)
+	}
+}
+
+func TestCacheReferenceData(t *testing.T) {
+	api := newCountingAPI(t)
+	cache := NewCache(CacheConfig{TTL: time.Minute})
+	c := api.client(cache, "token")
+
+	for i := 0; i < 3; i++ {
+		get(t, c, "tags.json", nil)
+		get(t, c, "tickets.json", nil)
+	}
+	if n := api.count("GET", "tags.json"); n != 1 {
+		t.Errorf("tags fetched %d times, want once", n)
+	}
+	// Tickets are not reference data
+	if n := api.count("GET", "tickets.json"); n != 3 {
+		t.Errorf("tickets fetched %d times, want 3", n)
+	}
+	// Other queries are other responses
+	get(t, c, "tags.json", url.Values{"page": {"2"}})
+	if n := api.count("GET", "tags.json"); n != 2 {
+		t.Errorf("tags fetched %d times, want twice", n)
+	}
+
+	stats := cache.Stats()
+	if stats.Hits != 2 || stats.Misses != 2 || stats.Entries != 2 || stats.Resources["tags"].Entries != 2 {
+		t.Errorf("stats = %+v, want 2 hits, 2 misses and 2 tag responses", stats)
+	}
+}
+
+func TestCacheCredentials(t *testing.T) {
+	api := newCountingAPI(t)
+	cache := NewCache(CacheConfig{TTL: time.Minute})
+
+	get(t, api.client(cache, "alice"), "users.json", nil)
+	get(t, api.client(cache, "bob"), "users.json", nil)
+	get(t, api.client(cache, "alice"), "users.json", nil)
+	// Desk may answer users differently, so they do not share responses
+	if n := api.count("GET", "users.json"); n != 2 {
+		t.Errorf("users fetched %d times, want once per token", n)
+	}
+}
+
+func TestCacheTTL(t *testing.T) {
+	api := newCountingAPI(t)
+	cache := NewCache(CacheConfig{
+		TTL:          20 * time.Millisecond,
+		ResourceTTLs: map[string]time.Duration{"users": 0, "inboxes": time.Minute},
+	})
+	c := api.client(cache, "token")
+
+	for _, path := range []string{"tags.json", "users.json", "inboxes.json"} {
+		get(t, c, path, nil)
+		get(t, c, path, nil)
+	}
+	time.Sleep(30 * time.Millisecond)
+	for _, path := range []string{"tags.json", "users.json", "inboxes.json"} {
+		get(t, c, path, nil)
+	}
+
+	for path, want := range map[string]int{
+		// expired after the default TTL
+		"tags.json": 2,
+		// a TTL of zero turns caching off
+		"users.json": 3,
+		// the resource's own TTL has not passed
+		"inboxes.json": 1,
+	} {
+		if n := api.count("GET", path); n != want {
+			t.Errorf("%s fetched %d times, want %d", path, n, want)
+		}
+	}
+}
+
+func TestCacheEviction(t *testing.T) {
+	api := newCountingAPI(t)
+	cache := NewCache(CacheConfig{TTL: time.Minute, MaxEntries: 2})
+	c := api.client(cache, "token")
+	page := func(n string) url.Values { return url.Values{"page": {n}} }
+
+	get(t, c, "tags.json", page("1"))
+	get(t, c, "tags.json", page("2"))
+	// Page 1 becomes the most recently used, so page 3 evicts page 2
+	get(t, c, "tags.json", page("1"))
+	get(t, c, "tags.json", page("3"))
+	get(t, c, "tags.json", page("1"))
+	if n := api.count("GET", "tags.json"); n != 3 {
+		t.Errorf("tags fetched %d times, want 3", n)
+	}
+	get(t, c, "tags.json", page("2"))
+	if n := api.count("GET", "tags.json"); n != 4 {
+		t.Errorf("tags fetched %d times, want page 2 fetched again", n)
+	}
+
+	if stats := cache.Stats(); stats.Entries != 2 || stats.Evictions != 2 {
+		t.Errorf("stats = %+v, want 2 entries and 2 evictions", stats)
+	}
+}
+
+func TestCacheErrorsNotCached(t *testing.T) {
+	api := newCountingAPI(t)
+	cache := NewCache(CacheConfig{TTL: time.Minute})
+	c := api.client(cache, "token")
+
+	api.status = http.StatusNotFound
+	for i := 0; i < 2; i++ {
+		if err := c.Do(context.Background(), http.MethodGet, "tags/1.json", nil, nil, nil); err == nil {
+			t.Fatal("GET of a missing tag succeeded")
+		}
+	}
+	if n := api.count("GET", "tags/1.json"); n != 2 {
+		t.Errorf("missing tag fetched %d times, want twice", n)
+	}
+}
+
+func TestCacheInvalidation(t *testing.T) {
+	api := newCountingAPI(t)
+	other := newCountingAPI(t)
+	cache := NewCache(CacheConfig{TTL: time.Minute})
+	alice, bob := api.client(cache, "alice"), api.client(cache, "bob")
+	elsewhere := other.client(cache, "alice")
+
+	get(t, alice, "tags.json", nil)
+	get(t, bob, "tags.json", nil)
+	get(t, alice, "tickettypes.json", nil)
+	get(t, elsewhere, "tags.json", nil)
+
+	// A write through any client of the site drops the responses of the
+	// resource for every client of the site
+	if err := bob.Do(context.Background(), http.MethodPost, "tags.json", nil, map[string]interface{}{"tag": map[string]interface{}{"name": "vip"}}, nil); err != nil {
+		t.Fatal(err)
+	}
+	get(t, alice, "tags.json", nil)
+	get(t, alice, "tickettypes.json", nil)
+	get(t, elsewhere, "tags.json", nil)
+	if n := api.count("GET", "tags.json"); n != 3 {
+		t.Errorf("tags fetched %d times, want alice's fetched again after the write", n)
+	}
+	if n := api.count("GET", "tickettypes.json"); n != 1 {
+		t.Errorf("ticket types fetched %d times, want them still cached", n)
+	}
+	if n := other.count("GET", "tags.json"); n != 1 {
+		t.Errorf("tags of the other site fetched %d times, want them still cached", n)
+	}
+
+	alice.InvalidateCache("tickettypes")
+	get(t, alice, "tickettypes.json", nil)
+	if n := api.count("GET", "tickettypes.json"); n != 2 {
+		t.Errorf("ticket types fetched %d times, want them fetched again after InvalidateCache", n)
+	}
+	if stats := cache.Stats(); stats.Invalidations != 2 {
+		t.Errorf("invalidations = %d, want 2", stats.Invalidations)
+	}
+}
+
+func TestCachePutAfterInvalidation(t *testing.T) {
+	cache := NewCache(CacheConfig{TTL: time.Minute})
+	scope := cacheScope("https://acme.teamwork.com/desk/api/v2")
+
+	// A response fetched before a write is not cached after it
+	generation := cache.generation(scope, "tags")
+	cache.invalidate(scope, "tags")
+	cache.put(&cacheEntry{key: "k", scope: scope, resource: "tags", expires: time.Now().Add(time.Minute)}, generation)
+	if entry := cache.get("k"); entry != nil {
+		t.Error("stale response was cached")
+	}
+}
+
+func TestParseResourceTTLs(t *testing.T) {
+	ttls, err := ParseResourceTTLs(" users=1m, tags=0s ,")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ttls) != 2 || ttls["users"] != time.Minute || ttls["tags"] != 0 {
+		t.Errorf("ttls = %v, want users 1m and tags 0s", ttls)
+	}
+
+	for _, list := range []string{"users", "tickets=1m", "users=soon", "users=-1m"} {
+		if _, err := ParseResourceTTLs(list); err == nil {
+			t.Errorf("ParseResourceTTLs(%q) succeeded, want an error", list)
+		}
+	}
+}
@@ -0,0 +1,96 @@
+package desk
+
+import (
+	"context"
+	"net/http"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Call is a request sent to Desk
+type Call struct {
+	Method string
+	// Endpoint is the path relative to the base URL of the site with IDs
+	// replaced by {id}, e.g. tickets/{id}.json
+	Endpoint string
+	// Status is the status code of the response, 0 if there was none
+	Status   int
+	Duration time.Duration
+}
+
+// callRecorder keeps the requests made with a context
+type callRecorder struct {
+	mu    sync.Mutex
+	calls []Call
+}
+
+type callRecorderKey struct{}
+
+// RecordCalls returns a context that records the requests sent to Desk with
+// it. Responses served from the cache are not recorded.
+func RecordCalls(ctx context.Context) context.Context {
+	return context.WithValue(ctx, callRecorderKey{}, &callRecorder{})
+}
+
+// Calls returns the requests recorded for a context returned by RecordCalls
+func Calls(ctx context.Context) []Call {
+	r, ok := ctx.Value(callRecorderKey{}).(*callRecorder)
+	if !ok {
+		return nil
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]Call(nil), r.calls...)
+}
+
+var idSegment = regexp.MustCompile(`^\d+(\.json)?$`)
+
+// Endpoint returns the endpoint of a request path, e.g. tickets/{id}.json
+// for /desk/api/v2/tickets/42.json
+func Endpoint(path, basePath string) string {
+	segments := strings.Split(strings.TrimPrefix(strings.TrimPrefix(path, basePath), "/"), "/")
+	for i, s := range segments {
+		if idSegment.MatchString(s) {
+			segments[i] = "{id}" + strings.TrimPrefix(s, strings.TrimSuffix(s, ".json"))
+		}
+	}
+	return strings.Join(segments, "/")
+}
+
+// callTransport records the requests made with a context returned by
+// RecordCalls and reports every request to the observer of the client
+type callTransport struct {
+	next     http.RoundTripper
+	basePath string
+	observe  func(Call)
+}
+
+// RoundTrip implements http.RoundTripper
+func (t *callTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	r, ok := req.Context().Value(callRecorderKey{}).(*callRecorder)
+	if !ok && t.observe == nil {
+		return t.next.RoundTrip(req)
+	}
+
+	start := time.Now()
+	resp, err := t.next.RoundTrip(req)
+	call := Call{
+		Method:   req.Method,
+		Endpoint: Endpoint(req.URL.Path, t.basePath),
+		Duration: time.Since(start),
+	}
+	if err == nil {
+		call.Status = resp.StatusCode
+	}
+	if ok {
+		r.mu.Lock()
+		r.calls = append(r.calls, call)
+		r.mu.Unlock()
+	}
+	if t.observe != nil {
+		t.observe(call)
+	}
+	return resp, err
+}
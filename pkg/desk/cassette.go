@@ -0,0 +1,453 @@
+package desk
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// ErrNotRecorded is returned when replaying a request the cassette has no
+// response for
+var ErrNotRecorded = errors.New("no recorded response")
+
+// recordedHeaders are the response headers kept in a cassette
+var recordedHeaders = []string{"Content-Type", "Retry-After", RateLimitLimitHeader, RateLimitRemainingHeader, RateLimitResetHeader, RequestIDHeader}
+
+// Interaction is a Desk request and its response, as kept in a cassette
+type Interaction struct {
+	// Site is the host of the Desk site
+	Site   string `json:"site"`
+	Method string `json:"method"`
+	// Path is relative to the base URL of the site and includes the query
+	Path        string          `json:"path"`
+	RequestBody json.RawMessage `json:"request_body,omitempty"`
+
+	Status int               `json:"status,omitempty"`
+	Header map[string]string `json:"header,omitempty"`
+	Body   json.RawMessage   `json:"body,omitempty"`
+	// BodyText holds a response body that is not JSON
+	BodyText string `json:"body_text,omitempty"`
+	// Error is the error of a request that got no response
+	Error string `json:"error,omitempty"`
+}
+
+// Recorder writes the Desk traffic of clients to a cassette file, one JSON
+// interaction per line. API tokens are never written, and personal data in
+// queries and bodies is replaced by stable pseudonyms.
+type Recorder struct {
+	mu     sync.Mutex
+	f      *os.File
+	w      *bufio.Writer
+	err    error
+	redact *redactor
+}
+
+// NewRecorder creates the cassette file at path, replacing an existing one
+func NewRecorder(path string) (*Recorder, error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0o600)
+	if err != nil {
+		return nil, err
+	}
+	return &Recorder{f: f, w: bufio.NewWriter(f), redact: newRedactor()}, nil
+}
+
+// Close flushes and closes the cassette file
+func (r *Recorder) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if err := r.w.Flush(); err != nil {
+		r.f.Close()
+		return err
+	}
+	if r.err != nil {
+		r.f.Close()
+		return r.err
+	}
+	return r.f.Close()
+}
+
+func (r *Recorder) record(i Interaction) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	enc := json.NewEncoder(r.w)
+	enc.SetEscapeHTML(false)
+	err := enc.Encode(i)
+	if err == nil {
+		// Flush every interaction so that the cassette survives a crash,
+		// which is often what is being reproduced
+		err = r.w.Flush()
+	}
+	if err != nil && r.err == nil {
+		r.err = err
+	}
+}
+
+// Cassette serves the interactions of a recorded cassette in place of Desk
+type Cassette struct {
+	mu sync.Mutex
+	// byRequest and byPath index the interactions by their request with and
+	// without the request body
+	byRequest map[string][]*replayed
+	byPath    map[string][]*replayed
+	// redact puts requests in the form of the recorded ones. Values of a
+	// replayed session are pseudonyms already, which are left alone.
+	redact *redactor
+}
+
+type replayed struct {
+	Interaction
+	used bool
+}
+
+// LoadCassette reads a cassette recorded by a Recorder
+func LoadCassette(path string) (*Cassette, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	c := &Cassette{
+		byRequest: make(map[string][]*replayed),
+		byPath:    make(map[string][]*replayed),
+		redact:    newRedactor(),
+	}
+	dec := json.NewDecoder(f)
+	for line := 1; ; line++ {
+		var i replayed
+		if err := dec.Decode(&i.Interaction); errors.Is(err, io.EOF) {
+			break
+		} else if err != nil {
+			return nil, fmt.Errorf("invalid cassette %s: interaction %d: %w", path, line, err)
+		}
+		c.add(&i)
+	}
+	return c, nil
+}
+
+func (c *Cassette) add(i *replayed) {
+	pathKey := i.Method + " " + i.Path
+	c.byPath[pathKey] = append(c.byPath[pathKey], i)
+	// The body is put in the form the redactor gives the bodies of requests
+	requestKey := pathKey + " " + string(c.redact.body(i.RequestBody))
+	c.byRequest[requestKey] = append(c.byRequest[requestKey], i)
+}
+
+// next returns the interaction to replay for a request. Identical requests
+// get the recorded responses in order, and the last one once they run out.
+// A request whose body was not recorded gets a response recorded for the
+// same path.
+func (c *Cassette) next(site, method, path string, body json.RawMessage) (*replayed, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	pathKey := method + " " + path
+	if i, ok := pick(c.byRequest[pathKey+" "+string(body)], site); ok {
+		return i, true
+	}
+	return pick(c.byPath[pathKey], site)
+}
+
+// pick takes the first unused interaction, preferring those of site
+func pick(candidates []*replayed, site string) (*replayed, bool) {
+	if len(candidates) == 0 {
+		return nil, false
+	}
+	for _, sameSite := range []bool{true, false} {
+		for _, i := range candidates {
+			if !i.used && (!sameSite || i.Site == site) {
+				i.used = true
+				return i, true
+			}
+		}
+	}
+	for n := len(candidates) - 1; n >= 0; n-- {
+		if candidates[n].Site == site {
+			return candidates[n], true
+		}
+	}
+	return candidates[len(candidates)-1], true
+}
+
+// recordTransport writes the requests it sends and their responses to a
+// Recorder
+type recordTransport struct {
+	next     http.RoundTripper
+	recorder *Recorder
+	basePath string
+}
+
+// RoundTrip implements http.RoundTripper
+func (t *recordTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	var reqBody []byte
+	if req.Body != nil && req.Body != http.NoBody {
+		var err error
+		if reqBody, err = io.ReadAll(req.Body); err != nil {
+			return nil, err
+		}
+		req.Body.Close()
+		req.Body = io.NopCloser(bytes.NewReader(reqBody))
+	}
+
+	redact := t.recorder.redact
+	i := Interaction{
+		Site:        req.URL.Host,
+		Method:      req.Method,
+		Path:        redact.path(req.URL, t.basePath),
+		RequestBody: redact.body(reqBody),
+	}
+
+	resp, err := t.next.RoundTrip(req)
+	if err != nil {
+		i.Error = err.Error()
+		t.recorder.record(i)
+		return nil, err
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+	if err != nil {
+		return resp, err
+	}
+
+	i.Status = resp.StatusCode
+	for _, name := range recordedHeaders {
+		if v := resp.Header.Get(name); v != "" {
+			if i.Header == nil {
+				i.Header = make(map[string]string)
+			}
+			i.Header[name] = v
+		}
+	}
+	if redacted := redact.body(body); redacted != nil {
+		i.Body = redacted
+	} else {
+		i.BodyText = redact.text(string(body))
+	}
+	t.recorder.record(i)
+	return resp, nil
+}
+
+// replayTransport answers requests from a Cassette without any network
+// traffic
+type replayTransport struct {
+	cassette *Cassette
+	basePath string
+}
+
+// RoundTrip implements http.RoundTripper
+func (t *replayTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	var reqBody []byte
+	if req.Body != nil && req.Body != http.NoBody {
+		var err error
+		if reqBody, err = io.ReadAll(req.Body); err != nil {
+			return nil, err
+		}
+		req.Body.Close()
+	}
+
+	redact := t.cassette.redact
+	path := redact.path(req.URL, t.basePath)
+	i, ok := t.cassette.next(req.URL.Host, req.Method, path, redact.body(reqBody))
+	if !ok {
+		return nil, fmt.Errorf("replay: %w for %s %s", ErrNotRecorded, req.Method, path)
+	}
+	if i.Error != "" {
+		return nil, fmt.Errorf("replay: %s", i.Error)
+	}
+
+	body := []byte(i.Body)
+	if i.Body == nil {
+		body = []byte(i.BodyText)
+	}
+	header := make(http.Header)
+	for name, v := range i.Header {
+		header.Set(name, v)
+	}
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", i.Status, http.StatusText(i.Status)),
+		StatusCode:    i.Status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          io.NopCloser(bytes.NewReader(body)),
+		ContentLength: int64(len(body)),
+		Request:       req,
+	}, nil
+}
+
+// path returns the redacted path of a request relative to the base path of
+// the site, with the query parameters in order
+func (r *redactor) path(u *url.URL, basePath string) string {
+	path := strings.TrimPrefix(strings.TrimPrefix(u.Path, basePath), "/")
+	query := u.Query()
+	if len(query) == 0 {
+		return path
+	}
+	keys := make([]string, 0, len(query))
+	for key := range query {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	var b strings.Builder
+	for _, key := range keys {
+		for _, v := range query[key] {
+			if b.Len() > 0 {
+				b.WriteByte('&')
+			}
+			if key == "filter" {
+				if redacted := r.body([]byte(v)); redacted != nil {
+					v = string(redacted)
+				}
+			}
+			b.WriteString(url.QueryEscape(key) + "=" + url.QueryEscape(r.text(v)))
+		}
+	}
+	return path + "?" + b.String()
+}
+
+// Redaction
+
+// redactedPrefix marks pseudonyms, which are left alone when a redacted
+// value is redacted again during a replay
+const redactedPrefix = "redacted-"
+
+// personalFields are the fields whose values are replaced by pseudonyms
+var personalFields = map[string]bool{
+	"firstname": true, "lastname": true, "email": true, "emails": true,
+	"phone": true, "mobile": true, "address": true, "linkedinurl": true,
+	"facebookurl": true, "twitterhandle": true, "avatarurl": true,
+	"originalrecipient": true, "ipaddress": true,
+}
+
+// contentFields are the fields of free text and names that may hold personal
+// data, whose values are replaced by pseudonyms too
+var contentFields = map[string]bool{
+	"subject": true, "body": true, "htmlbody": true, "textbody": true,
+	"preview": true, "previewtext": true, "note": true, "notes": true,
+	"name": true, "fullname": true, "displayname": true, "description": true,
+	"message": true, "signature": true, "content": true,
+}
+
+// secretFields are the fields whose values are dropped
+var secretFields = map[string]bool{
+	"token": true, "apikey": true, "api_key": true, "password": true,
+	"secret": true, "accesstoken": true, "access_token": true,
+}
+
+var emailPattern = regexp.MustCompile(`[A-Za-z0-9._%+\-]+@[A-Za-z0-9.\-]+\.[A-Za-z]{2,}`)
+
+// redactor replaces personal data by pseudonyms. The pseudonyms are keyed
+// HMACs, so that they cannot be reversed by hashing likely values. The key
+// is random and only lives as long as the recording or replay, so the same
+// value gets the same pseudonym within a cassette only.
+type redactor struct {
+	key []byte
+}
+
+func newRedactor() *redactor {
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		panic(fmt.Sprintf("desk: failed to generate a redaction key: %v", err))
+	}
+	return &redactor{key: key}
+}
+
+// body redacts a JSON body and returns it in canonical form, or nil if the
+// body is empty or not JSON
+func (r *redactor) body(body []byte) json.RawMessage {
+	if len(bytes.TrimSpace(body)) == 0 {
+		return nil
+	}
+	var v interface{}
+	dec := json.NewDecoder(bytes.NewReader(body))
+	dec.UseNumber()
+	if err := dec.Decode(&v); err != nil {
+		return nil
+	}
+	data, err := json.Marshal(r.value("", v))
+	if err != nil {
+		return nil
+	}
+	return data
+}
+
+// value redacts the value of field. Every value within a sensitive field is
+// redacted, whatever its type, e.g. a numeric phone number or the parts of an
+// address.
+func (r *redactor) value(field string, v interface{}) interface{} {
+	key := strings.ToLower(field)
+	sensitive := secretFields[key] || personalFields[key] || contentFields[key]
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for k, child := range v {
+			// The operands of a filter operator belong to the field filtered
+			// by, and the parts of a sensitive field are sensitive too
+			if strings.HasPrefix(k, "$") || sensitive {
+				v[k] = r.value(field, child)
+				continue
+			}
+			v[k] = r.value(k, child)
+		}
+		return v
+	case []interface{}:
+		for n, child := range v {
+			v[n] = r.value(field, child)
+		}
+		return v
+	case string:
+		switch {
+		case v == "":
+			return v
+		case secretFields[key]:
+			return redactedPrefix + "secret"
+		case sensitive:
+			return r.pseudonym(v)
+		}
+		return r.text(v)
+	case json.Number, bool:
+		switch {
+		case secretFields[key]:
+			return redactedPrefix + "secret"
+		case sensitive:
+			return r.pseudonym(fmt.Sprint(v))
+		}
+	}
+	return v
+}
+
+// text replaces the email addresses in free text
+func (r *redactor) text(s string) string {
+	return emailPattern.ReplaceAllStringFunc(s, r.pseudonym)
+}
+
+// pseudonym replaces a value by a stand-in that is stable within the
+// cassette, so that a value redacted in a request still matches the same
+// value redacted in a response. Email addresses stay email addresses.
+func (r *redactor) pseudonym(v string) string {
+	if strings.HasPrefix(v, redactedPrefix) {
+		return v
+	}
+	mac := hmac.New(sha256.New, r.key)
+	mac.Write([]byte(strings.ToLower(v)))
+	name := redactedPrefix + hex.EncodeToString(mac.Sum(nil)[:6])
+	if strings.Contains(v, "@") {
+		return name + "@example.invalid"
+	}
+	return name
+}
@@ -0,0 +1,194 @@
+package desk
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRedactBody(t *testing.T) {
+	r := newRedactor()
+	body := []byte(`{
+		"customer": {"id": 7, "firstName": "Jane", "email": "jane@example.com", "phone": 5551234,
+			"address": {"street": "1 Main St", "zip": 12345}, "notes": "VIP", "verifiedEmail": true},
+		"ticket": {"subject": "Refund", "status": "active", "messages": [{"textBody": "Mail me at jane@example.com"}]},
+		"apiKey": "t0ken",
+		"comment": "contact jane@example.com"
+	}`)
+
+	var got map[string]interface{}
+	if err := json.Unmarshal(r.body(body), &got); err != nil {
+		t.Fatal(err)
+	}
+	customer := got["customer"].(map[string]interface{})
+	ticket := got["ticket"].(map[string]interface{})
+	address := customer["address"].(map[string]interface{})
+	message := ticket["messages"].([]interface{})[0].(map[string]interface{})
+
+	for name, v := range map[string]interface{}{
+		"firstName": customer["firstName"],
+		"phone":     customer["phone"],
+		"street":    address["street"],
+		"zip":       address["zip"],
+		"notes":     customer["notes"],
+		"subject":   ticket["subject"],
+		"textBody":  message["textBody"],
+	} {
+		if s, ok := v.(string); !ok || !strings.HasPrefix(s, redactedPrefix) {
+			t.Errorf("%s = %v, want a pseudonym", name, v)
+		}
+	}
+	if email := customer["email"].(string); !strings.HasSuffix(email, "@example.invalid") {
+		t.Errorf("email = %s, want a pseudonymous email address", email)
+	}
+	if got["apiKey"] != redactedPrefix+"secret" {
+		t.Errorf("apiKey = %v, want it dropped", got["apiKey"])
+	}
+	if comment := got["comment"].(string); strings.Contains(comment, "jane@example.com") || !strings.HasPrefix(comment, "contact ") {
+		t.Errorf("comment = %q, want the email address replaced in the text", comment)
+	}
+	// Fields that are not personal are kept
+	if customer["id"] != float64(7) || ticket["status"] != "active" {
+		t.Errorf("id = %v, status = %v, want them unchanged", customer["id"], ticket["status"])
+	}
+	if customer["verifiedEmail"] != true {
+		t.Errorf("verifiedEmail = %v, want it unchanged", customer["verifiedEmail"])
+	}
+}
+
+func TestRedactFilterOperands(t *testing.T) {
+	r := newRedactor()
+	filter := r.body([]byte(`{"$and":[{"firstName":{"$eq":"Jane"}},{"subject":{"$in":["Refund"]}}]}`))
+	if strings.Contains(string(filter), "Jane") || strings.Contains(string(filter), "Refund") {
+		t.Errorf("filter = %s, want the operands redacted", filter)
+	}
+}
+
+func TestPseudonyms(t *testing.T) {
+	r := newRedactor()
+	a := r.pseudonym("Jane@Example.com")
+	if b := r.pseudonym("jane@example.com"); a != b {
+		t.Errorf("pseudonyms of the same value differ: %s, %s", a, b)
+	}
+	if again := r.pseudonym(a); again != a {
+		t.Errorf("pseudonym of a pseudonym = %s, want it unchanged", again)
+	}
+
+	// A pseudonym is not the plain hash of the value, and another recording
+	// gets other pseudonyms
+	sum := sha256.Sum256([]byte("jane@example.com"))
+	if strings.Contains(a, hex.EncodeToString(sum[:6])) {
+		t.Errorf("pseudonym %s is the unkeyed hash of the value", a)
+	}
+	if other := newRedactor().pseudonym("jane@example.com"); other == a {
+		t.Error("two redactors gave the same pseudonym")
+	}
+}
+
+func TestRecordReplay(t *testing.T) {
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch {
+		case r.Method == http.MethodGet && r.URL.Path == "/desk/api/v2/customers.json":
+			io.WriteString(w, `{"customers":[{"id":1,"firstName":"Jane","email":"jane@example.com"}]}`)
+		case r.Method == http.MethodPost && r.URL.Path == "/desk/api/v2/customers.json":
+			body, _ := io.ReadAll(r.Body)
+			w.WriteHeader(http.StatusCreated)
+			w.Write(body)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			io.WriteString(w, `{"message":"Not found"}`)
+		}
+	}))
+	defer api.Close()
+
+	path := filepath.Join(t.TempDir(), "cassette.jsonl")
+	recorder, err := NewRecorder(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	noRetry := WithRetry(RetryConfig{})
+	filter := url.Values{"filter": {`{"email":"jane@example.com"}`}}
+
+	// Record a listing, a create and a failed get
+	recording := NewClient(api.URL+"/desk/api/v2", "secret-token", WithRecorder(recorder), noRetry)
+	var listed, created map[string]interface{}
+	if err := recording.Do(ctx, http.MethodGet, "customers.json", filter, nil, &listed); err != nil {
+		t.Fatal(err)
+	}
+	payload := map[string]interface{}{"customer": map[string]interface{}{"firstName": "Ada"}}
+	if err := recording.Do(ctx, http.MethodPost, "customers.json", nil, payload, &created); err != nil {
+		t.Fatal(err)
+	}
+	if err := recording.Do(ctx, http.MethodGet, "customers/9.json", nil, nil, nil); err == nil {
+		t.Fatal("get of a missing customer succeeded")
+	}
+	if err := recorder.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, leaked := range []string{"secret-token", "Jane", "jane@example.com", "Ada"} {
+		if strings.Contains(string(data), leaked) {
+			t.Errorf("cassette contains %q:\n%s", leaked, data)
+		}
+	}
+
+	// Replay the calls with the pseudonyms of the cassette
+	cassette, err := LoadCassette(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	replaying := NewClient("https://replay.invalid/desk/api/v2", "replay", WithReplay(cassette), noRetry)
+	customer := listed["customers"].([]interface{})[0].(map[string]interface{})
+	if customer["firstName"] != "Jane" {
+		t.Fatalf("recorded listing = %v, want the real response", listed)
+	}
+
+	var replayed map[string]interface{}
+	var recordedList struct {
+		Customers []map[string]interface{} `json:"customers"`
+	}
+	for _, line := range strings.Split(strings.TrimSpace(string(data)), "\n") {
+		var i Interaction
+		if err := json.Unmarshal([]byte(line), &i); err != nil {
+			t.Fatal(err)
+		}
+		if i.Method == http.MethodGet && strings.HasPrefix(i.Path, "customers.json") {
+			json.Unmarshal(i.Body, &recordedList)
+		}
+	}
+	email := recordedList.Customers[0]["email"].(string)
+	pseudonymFilter := url.Values{"filter": {`{"email":"` + email + `"}`}}
+	if err := replaying.Do(ctx, http.MethodGet, "customers.json", pseudonymFilter, nil, &replayed); err != nil {
+		t.Fatal(err)
+	}
+	if got := replayed["customers"].([]interface{})[0].(map[string]interface{})["email"]; got != email {
+		t.Errorf("replayed email = %v, want %s", got, email)
+	}
+
+	err = replaying.Do(ctx, http.MethodGet, "customers/9.json", nil, nil, nil)
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusNotFound {
+		t.Errorf("replayed get = %v, want the recorded 404", err)
+	}
+
+	err = replaying.Do(ctx, http.MethodDelete, "customers/1.json", nil, nil, nil)
+	if !errors.Is(err, ErrNotRecorded) {
+		t.Errorf("unrecorded request = %v, want %v", err, ErrNotRecorded)
+	}
+}
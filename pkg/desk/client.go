@@ -1,16 +1,169 @@
 package desk
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/url"
+	"strings"
+
 	"github.com/ready4god2513/desksdkgo/client"
 )
 
 // Client wraps the SDK client
 type Client struct {
 	*client.Client
+
+	baseURL    string
+	basePath   string
+	apiKey     string
+	httpClient *http.Client
+	cache      *Cache
+	retry      RetryConfig
+	rateLimit  float64
+	burst      int
+	recorder   *Recorder
+	cassette   *Cassette
+	observe    func(Call)
+	transport  *retryTransport
+	// reject, if set, answers every request instead of Desk
+	reject *rejectTransport
+}
+
+// Option configures a Client
+type Option func(*Client)
+
+// WithCache caches the reference data the client fetches in cache. The cache
+// may be shared by several clients.
+func WithCache(cache *Cache) Option {
+	return func(c *Client) {
+		c.cache = cache
+	}
+}
+
+// WithRetry sets how failed requests are retried
+func WithRetry(config RetryConfig) Option {
+	return func(c *Client) {
+		c.retry = config
+	}
+}
+
+// WithRateLimit limits the client to requestsPerSecond requests, allowing
+// bursts of up to burst requests. Cached responses do not count.
+func WithRateLimit(requestsPerSecond float64, burst int) Option {
+	return func(c *Client) {
+		c.rateLimit = requestsPerSecond
+		c.burst = burst
+	}
+}
+
+// WithRecorder records the Desk traffic of the client with recorder
+func WithRecorder(recorder *Recorder) Option {
+	return func(c *Client) {
+		c.recorder = recorder
+	}
+}
+
+// WithReplay answers the requests of the client from cassette instead of
+// sending them to Desk
+func WithReplay(cassette *Cassette) Option {
+	return func(c *Client) {
+		c.cassette = cassette
+	}
+}
+
+// WithCallObserver calls observe with every request the client sends to
+// Desk, e.g. to export metrics. Responses served from the cache are not
+// observed.
+func WithCallObserver(observe func(Call)) Option {
+	return func(c *Client) {
+		c.observe = observe
+	}
 }
 
 // NewClient returns a new Teamwork Desk API client
-func NewClient(baseURL, apiKey string) *Client {
-	c := client.NewClient(baseURL, client.WithAPIKey(apiKey))
-	return &Client{Client: c}
+func NewClient(baseURL, apiKey string, opts ...Option) *Client {
+	dc := &Client{
+		baseURL: strings.TrimSuffix(baseURL, "/"),
+		apiKey:  apiKey,
+		retry:   DefaultRetryConfig,
+	}
+	if u, err := url.Parse(dc.baseURL); err == nil {
+		dc.basePath = u.Path
+	}
+	for _, opt := range opts {
+		opt(dc)
+	}
+
+	var limiter *tokenBucket
+	if dc.rateLimit > 0 {
+		limiter = newTokenBucket(dc.rateLimit, dc.burst)
+	}
+	base := http.DefaultTransport
+	if dc.cassette != nil {
+		base = &replayTransport{cassette: dc.cassette, basePath: dc.basePath}
+	}
+	if dc.reject != nil {
+		base = dc.reject
+	}
+	if dc.recorder != nil {
+		base = &recordTransport{next: base, recorder: dc.recorder, basePath: dc.basePath}
+	}
+	dc.transport = newRetryTransport(base, dc.retry, limiter)
+	var transport http.RoundTripper = &callTransport{next: dc.transport, basePath: dc.basePath, observe: dc.observe}
+	transport = &traceTransport{next: transport, basePath: dc.basePath}
+	if dc.cache != nil {
+		transport = newCacheTransport(dc.cache, transport, dc.baseURL, dc.basePath, apiKey)
+	}
+	transport = &errorTransport{next: transport, basePath: dc.basePath}
+	// Every attempt of a request is bounded by requestTimeout instead of an
+	// overall timeout, so that retries get time of their own
+	dc.httpClient = &http.Client{Transport: transport}
+	dc.Client = client.NewClient(baseURL,
+		client.WithAPIKey(apiKey),
+		client.WithHTTPClient(dc.httpClient),
+	)
+	return dc
+}
+
+// RateLimit returns the request quota last reported by Desk and the client
+// side rate limit
+func (c *Client) RateLimit() RateLimitStatus {
+	return c.transport.rateLimitStatus()
+}
+
+// Cache returns the cache of the client, or nil if it does not cache
+func (c *Client) Cache() *Cache {
+	return c.cache
+}
+
+// InvalidateCache drops the cached responses of the given resources, e.g.
+// "tags", for the site of the client. Writes made through the client drop the
+// responses of the resource they write to on their own; this is for writes
+// that change other resources as well.
+func (c *Client) InvalidateCache(resources ...string) {
+	if c.cache == nil {
+		return
+	}
+	for _, resource := range resources {
+		c.cache.invalidate(cacheScope(c.baseURL), resource)
+	}
+}
+
+// Identity identifies the site and credentials of the client. Clients with
+// the same identity act as the same Desk user on the same site.
+func (c *Client) Identity() string {
+	sum := sha256.Sum256([]byte(c.baseURL + "\x00" + c.apiKey))
+	return hex.EncodeToString(sum[:])
+}
+
+// Site identifies the Desk site of the client
+func (c *Client) Site() string {
+	return SiteOf(c.baseURL)
+}
+
+// SiteOf identifies the Desk site of an API URL, so that URLs differing only
+// in case or a trailing slash identify the same site
+func SiteOf(baseURL string) string {
+	return strings.ToLower(strings.TrimSuffix(baseURL, "/"))
 }
@@ -0,0 +1,37 @@
+package desk
+
+import "context"
+
+// clientKey is the context key for the Desk client of the current request
+type clientKey struct{}
+
+// WithClient returns a context carrying the Desk client to use for the request
+func WithClient(ctx context.Context, c *Client) context.Context {
+	return context.WithValue(ctx, clientKey{}, c)
+}
+
+// ClientFromContext returns the Desk client carried by ctx, or fallback if
+// there is none
+func ClientFromContext(ctx context.Context, fallback *Client) *Client {
+	if c, ok := ctx.Value(clientKey{}).(*Client); ok && c != nil {
+		return c
+	}
+	return fallback
+}
+
+// credentialsKey is the context key marking requests that carry Desk
+// credentials of their own
+type credentialsKey struct{}
+
+// WithSessionCredentials returns a context marking the request as carrying
+// Desk credentials, whether or not they are valid
+func WithSessionCredentials(ctx context.Context) context.Context {
+	return context.WithValue(ctx, credentialsKey{}, true)
+}
+
+// HasSessionCredentials reports whether the request of ctx carries Desk
+// credentials, i.e. is not meant to run with the server's
+func HasSessionCredentials(ctx context.Context) bool {
+	ok, _ := ctx.Value(credentialsKey{}).(bool)
+	return ok
+}
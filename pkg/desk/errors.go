@@ -0,0 +1,218 @@
+package desk
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+)
+
+// RequestIDHeader is the header Desk identifies a request by
+const RequestIDHeader = "X-Request-Id"
+
+// maxErrorBody bounds how much of an error response is read
+const maxErrorBody = 64 << 10
+
+// Kinds of API errors
+const (
+	ErrNotFound     = "not_found"
+	ErrUnauthorized = "unauthorized"
+	ErrForbidden    = "forbidden"
+	ErrValidation   = "validation"
+	ErrConflict     = "conflict"
+	ErrRateLimited  = "rate_limited"
+	ErrServer       = "server"
+	ErrRequest      = "request"
+)
+
+// APIError is an error response of the Desk API
+type APIError struct {
+	Method     string
+	Path       string
+	StatusCode int
+	RequestID  string
+	// Message is the error message of the response, if it had one
+	Message string
+	// Fields are the validation errors of individual fields
+	Fields     []FieldError
+	RetryAfter time.Duration
+}
+
+// FieldError is a validation error of a field
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+func (e *APIError) Error() string {
+	msg := fmt.Sprintf("%s %s: %d %s", e.Method, e.Path, e.StatusCode, http.StatusText(e.StatusCode))
+	if e.Message != "" {
+		msg += ": " + e.Message
+	}
+	for _, f := range e.Fields {
+		msg += fmt.Sprintf("; %s %s", f.Field, f.Message)
+	}
+	return msg
+}
+
+// Kind classifies the error by its status code
+func (e *APIError) Kind() string {
+	switch {
+	case e.StatusCode == http.StatusNotFound:
+		return ErrNotFound
+	case e.StatusCode == http.StatusUnauthorized:
+		return ErrUnauthorized
+	case e.StatusCode == http.StatusForbidden:
+		return ErrForbidden
+	case e.StatusCode == http.StatusConflict:
+		return ErrConflict
+	case e.StatusCode == http.StatusBadRequest, e.StatusCode == http.StatusUnprocessableEntity:
+		return ErrValidation
+	case e.StatusCode == http.StatusTooManyRequests:
+		return ErrRateLimited
+	case e.StatusCode >= 500:
+		return ErrServer
+	default:
+		return ErrRequest
+	}
+}
+
+// Resource returns the resource the failed request was made for, e.g.
+// "customers"
+func (e *APIError) Resource() string {
+	first, _, _ := strings.Cut(strings.TrimPrefix(e.Path, "/"), "/")
+	return strings.TrimSuffix(first, ".json")
+}
+
+// newAPIError reads an error response. The body is restored so that it can
+// be read again.
+func newAPIError(resp *http.Response, basePath string) *APIError {
+	e := &APIError{
+		StatusCode: resp.StatusCode,
+		RequestID:  resp.Header.Get(RequestIDHeader),
+	}
+	if resp.Request != nil {
+		e.Method = resp.Request.Method
+		e.Path = strings.TrimPrefix(resp.Request.URL.Path, basePath)
+	}
+	if after, ok := retryAfter(resp.Header, time.Now()); ok {
+		e.RetryAfter = after
+	}
+	if resp.Body != nil {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, maxErrorBody))
+		resp.Body.Close()
+		resp.Body = io.NopCloser(bytes.NewReader(body))
+		e.Message, e.Fields = parseErrorBody(body)
+	}
+	return e
+}
+
+// parseErrorBody extracts the message and field errors of an error response.
+// Desk reports errors as {"message": ..., "errors": ...}, where errors is
+// either a list of error objects or an object of messages by field.
+func parseErrorBody(body []byte) (string, []FieldError) {
+	var payload struct {
+		Message string          `json:"message"`
+		Error   json.RawMessage `json:"error"`
+		Errors  json.RawMessage `json:"errors"`
+	}
+	if err := json.Unmarshal(body, &payload); err != nil {
+		text := strings.TrimSpace(string(body))
+		if len(text) > 200 || strings.HasPrefix(text, "<") {
+			return "", nil
+		}
+		return text, nil
+	}
+
+	message := payload.Message
+	if message == "" && len(payload.Error) > 0 {
+		var text string
+		if json.Unmarshal(payload.Error, &text) == nil {
+			message = text
+		}
+	}
+
+	var fields []FieldError
+	var list []struct {
+		Field   string `json:"field"`
+		Message string `json:"message"`
+		Detail  string `json:"detail"`
+		Title   string `json:"title"`
+		Source  struct {
+			Pointer string `json:"pointer"`
+		} `json:"source"`
+	}
+	var byField map[string]json.RawMessage
+	switch {
+	case json.Unmarshal(payload.Errors, &list) == nil:
+		for _, item := range list {
+			msg := firstNonEmpty(item.Detail, item.Message, item.Title)
+			field := item.Field
+			if field == "" && item.Source.Pointer != "" {
+				field = item.Source.Pointer[strings.LastIndex(item.Source.Pointer, "/")+1:]
+			}
+			if field == "" {
+				if message == "" {
+					message = msg
+				}
+				continue
+			}
+			fields = append(fields, FieldError{Field: field, Message: msg})
+		}
+	case json.Unmarshal(payload.Errors, &byField) == nil:
+		for field, raw := range byField {
+			var messages []string
+			var text string
+			if json.Unmarshal(raw, &messages) == nil {
+				text = strings.Join(messages, ", ")
+			} else if json.Unmarshal(raw, &text) != nil {
+				text = string(raw)
+			}
+			fields = append(fields, FieldError{Field: field, Message: text})
+		}
+		sort.Slice(fields, func(i, j int) bool { return fields[i].Field < fields[j].Field })
+	}
+	return message, fields
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+// TranslateError returns the *APIError behind err, if there is one
+func TranslateError(err error) (*APIError, bool) {
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		return apiErr, true
+	}
+	return nil, false
+}
+
+// errorTransport turns error responses into *APIError errors. The SDK reduces
+// error responses to their status code, and the error of a request is the
+// only way to hand the response to the caller of the SDK.
+type errorTransport struct {
+	next     http.RoundTripper
+	basePath string
+}
+
+// RoundTrip implements http.RoundTripper
+func (t *errorTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.next.RoundTrip(req)
+	if err == nil && resp.StatusCode >= 400 {
+		apiErr := newAPIError(resp, t.basePath)
+		resp.Body.Close()
+		return nil, apiErr
+	}
+	return resp, err
+}
@@ -0,0 +1,71 @@
+package desk
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+)
+
+// Do performs a request against an endpoint the SDK does not cover. The body,
+// if any, is encoded as JSON and the response is decoded into out when it is
+// not nil.
+func (c *Client) Do(ctx context.Context, method, path string, params url.Values, body, out interface{}) error {
+	endpoint := fmt.Sprintf("%s/%s", c.baseURL, path)
+	if len(params) > 0 {
+		endpoint += "?" + params.Encode()
+	}
+
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, endpoint, reader)
+	if err != nil {
+		return err
+	}
+	if c.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+c.apiKey)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		if apiErr, ok := TranslateError(err); ok {
+			return apiErr
+		}
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return newAPIError(resp, c.basePath)
+	}
+
+	if out == nil || resp.StatusCode == http.StatusNoContent {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+// Delete deletes the resource with the given ID, e.g. Delete(ctx, "tickets", 42)
+func (c *Client) Delete(ctx context.Context, resource string, id int) error {
+	return c.Do(ctx, http.MethodDelete, fmt.Sprintf("%s/%d.json", resource, id), nil, nil, nil)
+}
+
+// Update changes only the given fields of the resource with the given ID.
+// key wraps the fields as the API expects, e.g.
+// Update(ctx, "tickets", "ticket", 42, map[string]interface{}{"subject": "Hi"}, &resp)
+func (c *Client) Update(ctx context.Context, resource, key string, id int, fields map[string]interface{}, out interface{}) error {
+	body := map[string]interface{}{key: fields}
+	return c.Do(ctx, http.MethodPatch, fmt.Sprintf("%s/%d.json", resource, id), nil, body, out)
+}
@@ -0,0 +1,352 @@
+package desk
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"math"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Rate limit headers sent by Desk with every response
+const (
+	RateLimitLimitHeader     = "X-RateLimit-Limit"
+	RateLimitRemainingHeader = "X-RateLimit-Remaining"
+	RateLimitResetHeader     = "X-RateLimit-Reset"
+)
+
+// requestTimeout bounds a single attempt of a request
+const requestTimeout = 30 * time.Second
+
+// RetryConfig configures how failed requests are retried. GET and HEAD
+// requests are retried after network errors and 502, 503 and 504 responses.
+// Requests of any method are retried after a 429 response, since Desk did not
+// process them.
+type RetryConfig struct {
+	// MaxRetries is the number of retries after the first attempt
+	MaxRetries int
+	// BaseDelay is the delay before the first retry. It doubles with every
+	// retry, and a random part of it is waited.
+	BaseDelay time.Duration
+	// MaxDelay is the longest a retry waits. A request Desk asks to retry
+	// later than that is not retried.
+	MaxDelay time.Duration
+}
+
+// DefaultRetryConfig is the retry configuration of clients created without
+// WithRetry
+var DefaultRetryConfig = RetryConfig{
+	MaxRetries: 3,
+	BaseDelay:  500 * time.Millisecond,
+	MaxDelay:   30 * time.Second,
+}
+
+// RateLimitStatus is the request quota last reported by Desk together with
+// the limits the client enforces itself
+type RateLimitStatus struct {
+	// Limit, Remaining and ResetAt are the quota reported by Desk. They are
+	// unset until a response carried rate limit headers.
+	Limit     *int       `json:"limit,omitempty"`
+	Remaining *int       `json:"remaining,omitempty"`
+	ResetAt   *time.Time `json:"reset_at,omitempty"`
+	UpdatedAt *time.Time `json:"updated_at,omitempty"`
+	// RequestsPerSecond and Burst are the client side limit, if any
+	RequestsPerSecond float64 `json:"requests_per_second,omitempty"`
+	Burst             int     `json:"burst,omitempty"`
+	// Retries counts the retried requests and RateLimited the 429 responses
+	Retries     int64 `json:"retries"`
+	RateLimited int64 `json:"rate_limited"`
+}
+
+// retryTransport retries failed requests, waits for the quota Desk reports
+// to reset once it is used up and enforces the client side rate limit
+type retryTransport struct {
+	next    http.RoundTripper
+	config  RetryConfig
+	limiter *tokenBucket
+
+	mu     sync.Mutex
+	status RateLimitStatus
+	// pausedUntil is when the quota reported by Desk resets after it was
+	// used up
+	pausedUntil time.Time
+}
+
+func newRetryTransport(next http.RoundTripper, config RetryConfig, limiter *tokenBucket) *retryTransport {
+	t := &retryTransport{next: next, config: config, limiter: limiter}
+	if limiter != nil {
+		t.status.RequestsPerSecond = limiter.rate
+		t.status.Burst = int(limiter.burst)
+	}
+	return t
+}
+
+// RoundTrip implements http.RoundTripper
+func (t *retryTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	ctx := req.Context()
+	for attempt := 0; ; attempt++ {
+		if err := t.wait(ctx); err != nil {
+			return nil, err
+		}
+
+		attemptReq, cancel, err := prepareAttempt(req, attempt)
+		if err != nil {
+			return nil, err
+		}
+		resp, err := t.next.RoundTrip(attemptReq)
+		if resp != nil {
+			t.observe(resp)
+		}
+
+		delay, retry := t.retryDelay(req, resp, err, attempt)
+		if !retry {
+			if err != nil {
+				cancel()
+				return nil, err
+			}
+			resp.Body = &cancelBody{ReadCloser: resp.Body, cancel: cancel}
+			return resp, nil
+		}
+
+		if err != nil {
+			slog.Warn("retrying Desk request", "method", req.Method, "path", req.URL.Path,
+				"delay", delay.Round(time.Millisecond), "error", err)
+		} else {
+			slog.Warn("retrying Desk request", "method", req.Method, "path", req.URL.Path,
+				"delay", delay.Round(time.Millisecond), "status", resp.StatusCode)
+			// Drain the body so that the connection can be reused
+			_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+			resp.Body.Close()
+		}
+		cancel()
+
+		t.mu.Lock()
+		t.status.Retries++
+		t.mu.Unlock()
+		if err := sleep(ctx, delay); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// prepareAttempt returns the request to send for an attempt, bounded by
+// requestTimeout. The body of the original request is read again for
+// retries.
+func prepareAttempt(req *http.Request, attempt int) (*http.Request, context.CancelFunc, error) {
+	ctx, cancel := context.WithTimeout(req.Context(), requestTimeout)
+	attemptReq := req.Clone(ctx)
+	if attempt > 0 && req.Body != nil && req.Body != http.NoBody {
+		body, err := req.GetBody()
+		if err != nil {
+			cancel()
+			return nil, nil, err
+		}
+		attemptReq.Body = body
+	}
+	return attemptReq, cancel, nil
+}
+
+// retryDelay decides whether an attempt is retried and how long to wait
+// before the retry
+func (t *retryTransport) retryDelay(req *http.Request, resp *http.Response, err error, attempt int) (time.Duration, bool) {
+	if attempt >= t.config.MaxRetries || req.Context().Err() != nil {
+		return 0, false
+	}
+	idempotent := req.Method == http.MethodGet || req.Method == http.MethodHead
+	rewindable := req.Body == nil || req.Body == http.NoBody || req.GetBody != nil
+
+	switch {
+	case err != nil:
+		if !idempotent || errors.Is(err, ErrNotRecorded) {
+			return 0, false
+		}
+	case resp.StatusCode == http.StatusTooManyRequests:
+		if !rewindable {
+			return 0, false
+		}
+	case resp.StatusCode == http.StatusBadGateway,
+		resp.StatusCode == http.StatusServiceUnavailable,
+		resp.StatusCode == http.StatusGatewayTimeout:
+		if !idempotent {
+			return 0, false
+		}
+	default:
+		return 0, false
+	}
+
+	if resp != nil {
+		if after, ok := retryAfter(resp.Header, time.Now()); ok {
+			if after > t.config.MaxDelay {
+				return 0, false
+			}
+			return after, true
+		}
+	}
+	return t.backoff(attempt), true
+}
+
+// backoff returns a random delay between half and all of BaseDelay doubled
+// attempt times, capped at MaxDelay
+func (t *retryTransport) backoff(attempt int) time.Duration {
+	ceiling := float64(t.config.BaseDelay) * math.Pow(2, float64(attempt))
+	if ceiling > float64(t.config.MaxDelay) {
+		ceiling = float64(t.config.MaxDelay)
+	}
+	if ceiling < 1 {
+		return 0
+	}
+	return time.Duration(ceiling/2 + rand.Float64()*ceiling/2)
+}
+
+// retryAfter parses the Retry-After header, which holds either a number of
+// seconds or an HTTP date
+func retryAfter(header http.Header, now time.Time) (time.Duration, bool) {
+	value := header.Get("Retry-After")
+	if value == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second, true
+	}
+	if at, err := http.ParseTime(value); err == nil {
+		if d := at.Sub(now); d > 0 {
+			return d, true
+		}
+		return 0, true
+	}
+	return 0, false
+}
+
+// observe records the quota reported by a response. Once the quota is used
+// up, requests wait for it to reset.
+func (t *retryTransport) observe(resp *http.Response) {
+	now := time.Now()
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if resp.StatusCode == http.StatusTooManyRequests {
+		t.status.RateLimited++
+	}
+	limit, limitErr := strconv.Atoi(resp.Header.Get(RateLimitLimitHeader))
+	remaining, remainingErr := strconv.Atoi(resp.Header.Get(RateLimitRemainingHeader))
+	if limitErr != nil && remainingErr != nil {
+		return
+	}
+	if limitErr == nil {
+		t.status.Limit = &limit
+	}
+	if remainingErr == nil {
+		t.status.Remaining = &remaining
+	}
+	t.status.ResetAt = nil
+	if reset, err := strconv.ParseInt(resp.Header.Get(RateLimitResetHeader), 10, 64); err == nil {
+		resetAt := resetTime(reset, now)
+		t.status.ResetAt = &resetAt
+		if remainingErr == nil && remaining <= 0 && resetAt.Sub(now) <= t.config.MaxDelay {
+			t.pausedUntil = resetAt
+		}
+	}
+	t.status.UpdatedAt = &now
+}
+
+// resetTime interprets the reset header, which is either a Unix timestamp or
+// a number of seconds from now
+func resetTime(reset int64, now time.Time) time.Time {
+	if reset > 1e9 {
+		return time.Unix(reset, 0)
+	}
+	return now.Add(time.Duration(reset) * time.Second)
+}
+
+// wait blocks until the request may be sent
+func (t *retryTransport) wait(ctx context.Context) error {
+	t.mu.Lock()
+	paused := time.Until(t.pausedUntil)
+	t.mu.Unlock()
+	if paused > 0 {
+		if err := sleep(ctx, paused); err != nil {
+			return err
+		}
+	}
+	if t.limiter != nil {
+		return t.limiter.wait(ctx)
+	}
+	return nil
+}
+
+func (t *retryTransport) rateLimitStatus() RateLimitStatus {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.status
+}
+
+func sleep(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// cancelBody releases the context of an attempt once its response body is
+// closed
+type cancelBody struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (b *cancelBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.cancel()
+	return err
+}
+
+// tokenBucket limits the rate of requests, allowing bursts of up to burst
+// requests
+type tokenBucket struct {
+	rate  float64
+	burst float64
+
+	mu     sync.Mutex
+	tokens float64
+	last   time.Time
+}
+
+func newTokenBucket(rate float64, burst int) *tokenBucket {
+	if burst < 1 {
+		burst = 1
+	}
+	return &tokenBucket{rate: rate, burst: float64(burst), tokens: float64(burst), last: time.Now()}
+}
+
+// wait takes a token, waiting for one to become available
+func (b *tokenBucket) wait(ctx context.Context) error {
+	for {
+		b.mu.Lock()
+		now := time.Now()
+		b.tokens = math.Min(b.burst, b.tokens+now.Sub(b.last).Seconds()*b.rate)
+		b.last = now
+		if b.tokens >= 1 {
+			b.tokens--
+			b.mu.Unlock()
+			return nil
+		}
+		delay := time.Duration((1 - b.tokens) / b.rate * float64(time.Second))
+		b.mu.Unlock()
+
+		if err := sleep(ctx, delay); err != nil {
+			return err
+		}
+	}
+}
@@ -0,0 +1,282 @@
+package desk
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+// scriptedAPI answers the requests it receives with the given responses in
+// turn, the last one repeated, and keeps the bodies of the requests
+type scriptedAPI struct {
+	*httptest.Server
+
+	mu        sync.Mutex
+	responses []scriptedResponse
+	bodies    []string
+}
+
+type scriptedResponse struct {
+	status int
+	header map[string]string
+}
+
+func newScriptedAPI(t *testing.T, responses ...scriptedResponse) *scriptedAPI {
+	api := &scriptedAPI{responses: responses}
+	api.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		api.mu.Lock()
+		resp := api.responses[min(len(api.bodies), len(api.responses)-1)]
+		api.bodies = append(api.bodies, string(body))
+		api.mu.Unlock()
+		for k, v := range resp.header {
+			w.Header().Set(k, v)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(resp.status)
+		w.Write([]byte(`{}`))
+	}))
+	t.Cleanup(api.Close)
+	return api
+}
+
+func (api *scriptedAPI) attempts() int {
+	api.mu.Lock()
+	defer api.mu.Unlock()
+	return len(api.bodies)
+}
+
+func respond(code int) scriptedResponse {
+	return scriptedResponse{status: code}
+}
+
+func TestRetry(t *testing.T) {
+	tooMany := func(after string) scriptedResponse {
+		return scriptedResponse{status: http.StatusTooManyRequests, header: map[string]string{"Retry-After": after}}
+	}
+
+	tests := []struct {
+		name      string
+		method    string
+		responses []scriptedResponse
+		attempts  int
+		ok        bool
+	}{
+		{"success", http.MethodGet, []scriptedResponse{respond(200)}, 1, true},
+		{"unavailable then success", http.MethodGet, []scriptedResponse{respond(503), respond(502), respond(200)}, 3, true},
+		{"gives up", http.MethodGet, []scriptedResponse{respond(504)}, 3, false},
+		{"not found", http.MethodGet, []scriptedResponse{respond(404)}, 1, false},
+		{"server error", http.MethodGet, []scriptedResponse{respond(500)}, 1, false},
+		// Desk may have processed a write it answered with a 503
+		{"write unavailable", http.MethodPost, []scriptedResponse{respond(503), respond(201)}, 1, false},
+		// but not one it answered with a 429
+		{"write rate limited", http.MethodPost, []scriptedResponse{tooMany("0"), respond(201)}, 2, true},
+		{"retry after too long", http.MethodGet, []scriptedResponse{tooMany("60"), respond(200)}, 1, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			api := newScriptedAPI(t, tt.responses...)
+			c := NewClient(api.URL, "token", WithRetry(RetryConfig{MaxRetries: 2, BaseDelay: time.Millisecond, MaxDelay: time.Second}))
+
+			var body interface{}
+			if tt.method == http.MethodPost {
+				body = map[string]interface{}{"tag": map[string]interface{}{"name": "vip"}}
+			}
+			err := c.Do(context.Background(), tt.method, "tags.json", nil, body, nil)
+			if (err == nil) != tt.ok {
+				t.Errorf("error = %v, want success %v", err, tt.ok)
+			}
+			if n := api.attempts(); n != tt.attempts {
+				t.Errorf("%d attempts, want %d", n, tt.attempts)
+			}
+			if retries := c.RateLimit().Retries; retries != int64(tt.attempts-1) {
+				t.Errorf("retries = %d, want %d", retries, tt.attempts-1)
+			}
+			// Retries send the body again
+			for i, b := range api.bodies {
+				if b != api.bodies[0] {
+					t.Errorf("body of attempt %d = %q, want %q", i+1, b, api.bodies[0])
+				}
+			}
+		})
+	}
+}
+
+func TestRetryAfterWaits(t *testing.T) {
+	api := newScriptedAPI(t, scriptedResponse{status: http.StatusTooManyRequests, header: map[string]string{"Retry-After": "1"}}, respond(200))
+	c := NewClient(api.URL, "token", WithRetry(RetryConfig{MaxRetries: 1, BaseDelay: time.Millisecond, MaxDelay: 5 * time.Second}))
+
+	start := time.Now()
+	if err := c.Do(context.Background(), http.MethodGet, "tags.json", nil, nil, nil); err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed < time.Second {
+		t.Errorf("retried after %s, want the second Desk asked for", elapsed)
+	}
+	if status := c.RateLimit(); status.RateLimited != 1 || status.Retries != 1 {
+		t.Errorf("status = %+v, want 1 rate limited response and 1 retry", status)
+	}
+}
+
+func TestRetryCanceled(t *testing.T) {
+	api := newScriptedAPI(t, respond(503))
+	c := NewClient(api.URL, "token", WithRetry(RetryConfig{MaxRetries: 5, BaseDelay: time.Minute, MaxDelay: time.Minute}))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	err := c.Do(ctx, http.MethodGet, "tags.json", nil, nil, nil)
+	if err == nil {
+		t.Fatal("request succeeded, want the context's error")
+	}
+	if n := api.attempts(); n != 1 {
+		t.Errorf("%d attempts, want the backoff interrupted after the first", n)
+	}
+}
+
+func TestRetryAfterHeader(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		value string
+		want  time.Duration
+		ok    bool
+	}{
+		{"", 0, false},
+		{"30", 30 * time.Second, true},
+		{"0", 0, true},
+		{"-5", 0, false},
+		{"soon", 0, false},
+		{now.Add(90 * time.Second).Format(http.TimeFormat), 90 * time.Second, true},
+		{now.Add(-time.Minute).Format(http.TimeFormat), 0, true},
+	}
+	for _, tt := range tests {
+		header := http.Header{}
+		if tt.value != "" {
+			header.Set("Retry-After", tt.value)
+		}
+		got, ok := retryAfter(header, now)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("retryAfter(%q) = %s, %v, want %s, %v", tt.value, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	rt := newRetryTransport(nil, RetryConfig{MaxRetries: 10, BaseDelay: 100 * time.Millisecond, MaxDelay: 300 * time.Millisecond}, nil)
+	tests := []struct {
+		attempt  int
+		from, to time.Duration
+	}{
+		{0, 50 * time.Millisecond, 100 * time.Millisecond},
+		{1, 100 * time.Millisecond, 200 * time.Millisecond},
+		// capped at MaxDelay
+		{2, 150 * time.Millisecond, 300 * time.Millisecond},
+		{8, 150 * time.Millisecond, 300 * time.Millisecond},
+	}
+	for _, tt := range tests {
+		for i := 0; i < 100; i++ {
+			if d := rt.backoff(tt.attempt); d < tt.from || d > tt.to {
+				t.Fatalf("backoff(%d) = %s, want between %s and %s", tt.attempt, d, tt.from, tt.to)
+			}
+		}
+	}
+
+	if d := newRetryTransport(nil, RetryConfig{}, nil).backoff(3); d != 0 {
+		t.Errorf("backoff without a base delay = %s, want 0", d)
+	}
+}
+
+func TestRateLimitHeaders(t *testing.T) {
+	api := newScriptedAPI(t, scriptedResponse{status: 200, header: map[string]string{
+		RateLimitLimitHeader:     "100",
+		RateLimitRemainingHeader: "0",
+		RateLimitResetHeader:     "1",
+	}})
+	c := NewClient(api.URL, "token", WithRetry(RetryConfig{MaxDelay: 5 * time.Second}))
+
+	if err := c.Do(context.Background(), http.MethodGet, "tickets.json", nil, nil, nil); err != nil {
+		t.Fatal(err)
+	}
+	status := c.RateLimit()
+	if status.Limit == nil || *status.Limit != 100 || status.Remaining == nil || *status.Remaining != 0 || status.ResetAt == nil {
+		t.Fatalf("status = %+v, want the quota of the response", status)
+	}
+
+	// The quota is used up, so the next request waits for it to reset
+	start := time.Now()
+	if err := c.Do(context.Background(), http.MethodGet, "tickets.json", nil, nil, nil); err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed < 900*time.Millisecond {
+		t.Errorf("second request sent after %s, want it to wait for the reset", elapsed)
+	}
+}
+
+func TestResetTime(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	if got := resetTime(30, now); !got.Equal(now.Add(30 * time.Second)) {
+		t.Errorf("resetTime(30) = %s, want 30 seconds from now", got)
+	}
+	if got := resetTime(1700000060, now); !got.Equal(time.Unix(1700000060, 0)) {
+		t.Errorf("resetTime of a timestamp = %s, want the timestamp", got)
+	}
+}
+
+func TestTokenBucket(t *testing.T) {
+	b := newTokenBucket(20, 2)
+	ctx := context.Background()
+
+	// The burst is available at once
+	start := time.Now()
+	for i := 0; i < 2; i++ {
+		if err := b.wait(ctx); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if elapsed := time.Since(start); elapsed > 25*time.Millisecond {
+		t.Errorf("burst took %s, want no wait", elapsed)
+	}
+	// then a token every 50ms
+	start = time.Now()
+	for i := 0; i < 2; i++ {
+		if err := b.wait(ctx); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if elapsed := time.Since(start); elapsed < 90*time.Millisecond {
+		t.Errorf("2 tokens after the burst took %s, want about 100ms", elapsed)
+	}
+
+	slow := newTokenBucket(0.1, 0)
+	if err := slow.wait(ctx); err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+	defer cancel()
+	if err := slow.wait(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("error = %v, want the context's deadline", err)
+	}
+}
+
+func TestWithRateLimit(t *testing.T) {
+	api := newScriptedAPI(t, respond(200))
+	c := NewClient(api.URL, "token", WithRateLimit(50, 1), WithRetry(RetryConfig{}))
+
+	start := time.Now()
+	for i := 0; i < 3; i++ {
+		if err := c.Do(context.Background(), http.MethodGet, "tickets/"+strconv.Itoa(i)+".json", nil, nil, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if elapsed := time.Since(start); elapsed < 35*time.Millisecond {
+		t.Errorf("3 requests at 50 per second took %s, want at least 40ms", elapsed)
+	}
+	if status := c.RateLimit(); status.RequestsPerSecond != 50 || status.Burst != 1 {
+		t.Errorf("status = %+v, want the client side limit", status)
+	}
+}
@@ -0,0 +1,287 @@
+package desk
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// Headers used to supply Desk credentials per session over HTTP transports.
+// The token may also be sent as an "Authorization: Bearer" header.
+const (
+	URLHeader   = "X-Desk-URL"
+	TokenHeader = "X-Desk-Token"
+)
+
+// SessionClients caches a Desk client per MCP session so that every session
+// acts as the Desk user whose credentials it supplied
+type SessionClients struct {
+	mu      sync.Mutex
+	clients map[string]*sessionClient
+	ttl     time.Duration
+	hosts   []string
+	opts    []Option
+	done    chan struct{}
+	once    sync.Once
+}
+
+type sessionClient struct {
+	client      *Client
+	credentials [sha256.Size]byte
+	lastUsed    time.Time
+}
+
+// DefaultAllowedHosts are the hosts sessions may send Desk requests to
+// unless configured otherwise
+var DefaultAllowedHosts = []string{"*.teamwork.com"}
+
+// NewSessionClients returns a cache that evicts clients of sessions that have
+// not been used for ttl. Sessions may only use Desk URLs whose host matches
+// one of the glob patterns of allowedHosts, e.g. "*.teamwork.com". The
+// clients are created with opts.
+func NewSessionClients(ttl time.Duration, allowedHosts []string, opts ...Option) *SessionClients {
+	p := &SessionClients{
+		clients: make(map[string]*sessionClient),
+		ttl:     ttl,
+		hosts:   allowedHosts,
+		opts:    opts,
+		done:    make(chan struct{}),
+	}
+	go p.evictIdle()
+	return p
+}
+
+// Get returns the client of the session, creating it if the session has no
+// client yet or its credentials changed
+func (p *SessionClients) Get(sessionID, baseURL, apiKey string) *Client {
+	credentials := sha256.Sum256([]byte(baseURL + "\x00" + apiKey))
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if sc, ok := p.clients[sessionID]; ok && sc.credentials == credentials {
+		sc.lastUsed = time.Now()
+		return sc.client
+	}
+
+	sc := &sessionClient{
+		client:      NewClient(baseURL, apiKey, p.opts...),
+		credentials: credentials,
+		lastUsed:    time.Now(),
+	}
+	p.clients[sessionID] = sc
+	return sc.client
+}
+
+// Remove drops the client of the session
+func (p *SessionClients) Remove(sessionID string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	delete(p.clients, sessionID)
+}
+
+// Len returns the number of cached clients
+func (p *SessionClients) Len() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return len(p.clients)
+}
+
+// Close stops the eviction of idle clients
+func (p *SessionClients) Close() {
+	p.once.Do(func() { close(p.done) })
+}
+
+// Hooks returns server hooks that drop the client of a session when the
+// session ends
+func (p *SessionClients) Hooks() *server.Hooks {
+	hooks := &server.Hooks{}
+	hooks.AddOnUnregisterSession(func(_ context.Context, session server.ClientSession) {
+		p.Remove(session.SessionID())
+	})
+	return hooks
+}
+
+// ContextFunc adds the client for the credentials of the request to the
+// context, and marks the context of a request with any credentials header.
+// It is meant to be used as the context function of the HTTP transports,
+// after the session has been added to the context. Requests with incomplete
+// or disallowed credentials never fall back to the server's client: they get
+// a client that fails every request with 401 or 403. CheckCredentials
+// rejects them before they get here.
+func (p *SessionClients) ContextFunc(ctx context.Context, r *http.Request) context.Context {
+	baseURL, apiKey := CredentialsFromRequest(r)
+	if baseURL == "" && apiKey == "" {
+		return ctx
+	}
+	ctx = WithSessionCredentials(ctx)
+	if status, err := p.checkCredentials(baseURL, apiKey); err != nil {
+		return WithClient(ctx, NewClient(baseURL, "", withRejection(status, err.Error())))
+	}
+
+	sessionID := ""
+	if session := server.ClientSessionFromContext(ctx); session != nil {
+		sessionID = session.SessionID()
+	}
+	return WithClient(ctx, p.Get(sessionID, baseURL, apiKey))
+}
+
+// checkCredentials returns the HTTP status and error that credentials sent
+// with a request are refused with: 401 unless both the URL and the token are
+// sent, and 403 for a URL that is not allowed
+func (p *SessionClients) checkCredentials(baseURL, apiKey string) (int, error) {
+	if baseURL == "" || apiKey == "" {
+		return http.StatusUnauthorized, fmt.Errorf("Desk credentials are incomplete: send both the %s and %s headers", URLHeader, TokenHeader)
+	}
+	if err := p.CheckURL(baseURL); err != nil {
+		return http.StatusForbidden, err
+	}
+	return 0, nil
+}
+
+// CheckURL returns an error unless baseURL is an http or https URL of an
+// allowed host
+func (p *SessionClients) CheckURL(baseURL string) error {
+	u, err := url.Parse(baseURL)
+	if err != nil {
+		return fmt.Errorf("invalid Desk URL: %w", err)
+	}
+	if u.Scheme != "https" && u.Scheme != "http" {
+		return fmt.Errorf("invalid Desk URL %q: expected an http or https URL", baseURL)
+	}
+	if u.User != nil {
+		return fmt.Errorf("invalid Desk URL %q: it must not contain credentials", baseURL)
+	}
+	host := strings.ToLower(u.Hostname())
+	for _, pattern := range p.hosts {
+		if ok, _ := path.Match(strings.ToLower(pattern), host); ok {
+			return nil
+		}
+	}
+	return fmt.Errorf("Desk host %q is not allowed", host)
+}
+
+// ParseHostPatterns parses a comma separated list of host glob patterns
+func ParseHostPatterns(list string) ([]string, error) {
+	var patterns []string
+	for _, p := range strings.Split(list, ",") {
+		p = strings.TrimSpace(p)
+		if p == "" {
+			continue
+		}
+		if _, err := path.Match(p, ""); err != nil {
+			return nil, fmt.Errorf("invalid host pattern %q: %w", p, err)
+		}
+		patterns = append(patterns, p)
+	}
+	return patterns, nil
+}
+
+// CheckCredentials rejects requests with incomplete Desk credentials with
+// 401, so that they do not run as the server's Desk user, and requests whose
+// Desk URL names a host that is not allowed with 403, so that clients cannot
+// make the server send requests elsewhere. It is meant to wrap the HTTP
+// transports whether or not the server has default credentials.
+func (p *SessionClients) CheckCredentials(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if baseURL, apiKey := CredentialsFromRequest(r); baseURL != "" || apiKey != "" {
+			if status, err := p.checkCredentials(baseURL, apiKey); err != nil {
+				writeJSONRPCError(w, status, err.Error())
+				return
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (p *SessionClients) evictIdle() {
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
+	for {
+		select {
+		case now := <-ticker.C:
+			p.mu.Lock()
+			for id, sc := range p.clients {
+				if now.Sub(sc.lastUsed) > p.ttl {
+					delete(p.clients, id)
+				}
+			}
+			p.mu.Unlock()
+		case <-p.done:
+			return
+		}
+	}
+}
+
+// CredentialsFromRequest returns the Desk URL and API token sent with the request
+func CredentialsFromRequest(r *http.Request) (baseURL, apiKey string) {
+	baseURL = r.Header.Get(URLHeader)
+	apiKey = r.Header.Get(TokenHeader)
+	if apiKey == "" {
+		if auth := r.Header.Get("Authorization"); strings.HasPrefix(auth, "Bearer ") {
+			apiKey = strings.TrimPrefix(auth, "Bearer ")
+		}
+	}
+	return baseURL, apiKey
+}
+
+// RequireCredentials rejects messages that do not carry Desk credentials. It
+// is used when the server has no default credentials to fall back to.
+func RequireCredentials(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPost {
+			if baseURL, apiKey := CredentialsFromRequest(r); baseURL == "" || apiKey == "" {
+				writeJSONRPCError(w, http.StatusUnauthorized,
+					"Desk credentials are required: send the "+URLHeader+" and "+TokenHeader+" headers")
+				return
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// withRejection makes a client answer every request with status and message
+// instead of sending it to Desk
+func withRejection(status int, message string) Option {
+	return func(c *Client) {
+		c.reject = &rejectTransport{status: status, message: message}
+	}
+}
+
+// rejectTransport answers every request with an error response
+type rejectTransport struct {
+	status  int
+	message string
+}
+
+func (t *rejectTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Body != nil {
+		req.Body.Close()
+	}
+	body, _ := json.Marshal(map[string]string{"message": t.message})
+	return &http.Response{
+		StatusCode: t.status,
+		Status:     http.StatusText(t.status),
+		Header:     http.Header{"Content-Type": []string{"application/json"}},
+		Body:       io.NopCloser(bytes.NewReader(body)),
+		Request:    req,
+	}, nil
+}
+
+func writeJSONRPCError(w http.ResponseWriter, status int, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(mcp.NewJSONRPCError(nil, mcp.INVALID_REQUEST, message, nil))
+}
@@ -0,0 +1,81 @@
+package desk
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestCheckCredentials(t *testing.T) {
+	sessions := NewSessionClients(time.Minute, DefaultAllowedHosts)
+	defer sessions.Close()
+	handler := sessions.CheckCredentials(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+
+	tests := []struct {
+		name    string
+		headers map[string]string
+		want    int
+	}{
+		{"none", nil, http.StatusNoContent},
+		{"both", map[string]string{URLHeader: "https://acme.teamwork.com/desk/api/v2", TokenHeader: "t"}, http.StatusNoContent},
+		{"bearer", map[string]string{URLHeader: "https://acme.teamwork.com/desk/api/v2", "Authorization": "Bearer t"}, http.StatusNoContent},
+		{"token only", map[string]string{TokenHeader: "t"}, http.StatusUnauthorized},
+		{"url only", map[string]string{URLHeader: "https://acme.teamwork.com/desk/api/v2"}, http.StatusUnauthorized},
+		{"host not allowed", map[string]string{URLHeader: "https://evil.example.com", TokenHeader: "t"}, http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPost, "/mcp", nil)
+			for k, v := range tt.headers {
+				r.Header.Set(k, v)
+			}
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, r)
+			if w.Code != tt.want {
+				t.Errorf("status = %d, want %d", w.Code, tt.want)
+			}
+		})
+	}
+}
+
+func TestContextFuncRejectsInvalidCredentials(t *testing.T) {
+	sessions := NewSessionClients(time.Minute, DefaultAllowedHosts)
+	defer sessions.Close()
+	fallback := NewClient("https://default.teamwork.com/desk/api/v2", "server-token")
+
+	tests := []struct {
+		name    string
+		headers map[string]string
+		status  int
+	}{
+		{"token only", map[string]string{TokenHeader: "t"}, http.StatusUnauthorized},
+		{"url only", map[string]string{URLHeader: "https://acme.teamwork.com/desk/api/v2"}, http.StatusUnauthorized},
+		{"host not allowed", map[string]string{URLHeader: "https://evil.example.com", TokenHeader: "t"}, http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPost, "/mcp", nil)
+			for k, v := range tt.headers {
+				r.Header.Set(k, v)
+			}
+			ctx := sessions.ContextFunc(context.Background(), r)
+			if !HasSessionCredentials(ctx) {
+				t.Error("context is not marked as carrying session credentials")
+			}
+			client := ClientFromContext(ctx, fallback)
+			if client == fallback {
+				t.Fatal("fell back to the server's client")
+			}
+			err := client.Do(context.Background(), http.MethodGet, "tickets.json", nil, nil, nil)
+			var apiErr *APIError
+			if !errors.As(err, &apiErr) || apiErr.StatusCode != tt.status {
+				t.Errorf("error = %v, want status %d", err, tt.status)
+			}
+		})
+	}
+}
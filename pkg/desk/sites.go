@@ -0,0 +1,80 @@
+package desk
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Sites is a registry of clients for named Desk sites, e.g. one per brand.
+// One of the sites is the default used when no site is asked for.
+type Sites struct {
+	clients     map[string]*Client
+	defaultSite string
+}
+
+// Site describes a registered site
+type Site struct {
+	Name    string `json:"name"`
+	URL     string `json:"url"`
+	Default bool   `json:"default"`
+}
+
+// NewSites returns an empty registry whose default site is named defaultSite
+func NewSites(defaultSite string) *Sites {
+	return &Sites{
+		clients:     make(map[string]*Client),
+		defaultSite: defaultSite,
+	}
+}
+
+// Add registers the client of a site
+func (s *Sites) Add(name string, client *Client) {
+	s.clients[name] = client
+}
+
+// Client returns the client of the named site, or of the default site if
+// name is empty
+func (s *Sites) Client(name string) (*Client, error) {
+	if name == "" {
+		name = s.defaultSite
+	}
+	c, ok := s.clients[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown site %q, expected one of %s", name, strings.Join(s.Names(), ", "))
+	}
+	return c, nil
+}
+
+// Default returns the name of the default site
+func (s *Sites) Default() string {
+	return s.defaultSite
+}
+
+// Names returns the names of the sites in order
+func (s *Sites) Names() []string {
+	names := make([]string, 0, len(s.clients))
+	for name := range s.clients {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// List describes the sites in order
+func (s *Sites) List() []Site {
+	sites := make([]Site, 0, len(s.clients))
+	for _, name := range s.Names() {
+		sites = append(sites, Site{
+			Name:    name,
+			URL:     s.clients[name].baseURL,
+			Default: name == s.defaultSite,
+		})
+	}
+	return sites
+}
+
+// Len returns the number of sites
+func (s *Sites) Len() int {
+	return len(s.clients)
+}
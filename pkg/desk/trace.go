@@ -0,0 +1,56 @@
+package desk
+
+import (
+	"net/http"
+	"strconv"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// tracerName identifies the spans of Desk requests
+const tracerName = "github.com/ready4god2513/deskmcp/pkg/desk"
+
+// requestIDKey is the span attribute of the ID Desk gave a request
+const requestIDKey = attribute.Key("desk.request_id")
+
+// traceTransport makes a span of every request, a child of the span in its
+// context such as the one of a tool call. The spans go to the global tracer
+// provider, which drops them unless tracing was set up.
+type traceTransport struct {
+	next     http.RoundTripper
+	basePath string
+}
+
+// RoundTrip implements http.RoundTripper
+func (t *traceTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	endpoint := Endpoint(req.URL.Path, t.basePath)
+	ctx, span := otel.Tracer(tracerName).Start(req.Context(), req.Method+" "+endpoint,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			semconv.HTTPRequestMethodKey.String(req.Method),
+			semconv.URLTemplate(endpoint),
+			semconv.ServerAddress(req.URL.Hostname()),
+		),
+	)
+	defer span.End()
+
+	resp, err := t.next.RoundTrip(req.WithContext(ctx))
+	if err != nil {
+		span.SetAttributes(semconv.ErrorTypeOther)
+		span.SetStatus(codes.Error, err.Error())
+		return resp, err
+	}
+	span.SetAttributes(semconv.HTTPResponseStatusCode(resp.StatusCode))
+	if id := resp.Header.Get(RequestIDHeader); id != "" {
+		span.SetAttributes(requestIDKey.String(id))
+	}
+	if resp.StatusCode >= 400 {
+		span.SetAttributes(semconv.ErrorTypeKey.String(strconv.Itoa(resp.StatusCode)))
+		span.SetStatus(codes.Error, http.StatusText(resp.StatusCode))
+	}
+	return resp, err
+}
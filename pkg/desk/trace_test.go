@@ -0,0 +1,102 @@
+package desk
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// recordSpans installs a tracer provider that records the spans ended during
+// a test
+func recordSpans(t *testing.T) *tracetest.SpanRecorder {
+	recorder := tracetest.NewSpanRecorder()
+	previous := otel.GetTracerProvider()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	t.Cleanup(func() { otel.SetTracerProvider(previous) })
+	return recorder
+}
+
+func spanAttributes(span sdktrace.ReadOnlySpan) map[attribute.Key]string {
+	attrs := make(map[attribute.Key]string)
+	for _, kv := range span.Attributes() {
+		attrs[kv.Key] = kv.Value.Emit()
+	}
+	return attrs
+}
+
+func TestTraceRequests(t *testing.T) {
+	recorder := recordSpans(t)
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set(RequestIDHeader, "req-1")
+		if r.URL.Path == "/desk/api/v2/tickets/404.json" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Write([]byte(`{}`))
+	}))
+	defer api.Close()
+	c := NewClient(api.URL+"/desk/api/v2", "token", WithRetry(RetryConfig{}))
+
+	// The spans of requests are children of the span in their context
+	ctx, parent := otel.Tracer("test").Start(context.Background(), "tools/call get_ticket")
+	if err := c.Do(ctx, http.MethodGet, "tickets/42.json", nil, nil, nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Do(ctx, http.MethodGet, "tickets/404.json", nil, nil, nil); err == nil {
+		t.Fatal("GET of a missing ticket succeeded")
+	}
+	parent.End()
+
+	spans := recorder.Ended()
+	if len(spans) != 3 {
+		t.Fatalf("%d spans, want 2 requests and their parent", len(spans))
+	}
+	for i, want := range []struct {
+		status string
+		code   codes.Code
+	}{{"200", codes.Unset}, {"404", codes.Error}} {
+		span := spans[i]
+		if span.Name() != "GET tickets/{id}.json" || span.SpanKind() != trace.SpanKindClient {
+			t.Errorf("span %d = %s of kind %s, want a client span of GET tickets/{id}.json", i, span.Name(), span.SpanKind())
+		}
+		if span.Parent().SpanID() != parent.SpanContext().SpanID() || span.SpanContext().TraceID() != parent.SpanContext().TraceID() {
+			t.Errorf("span %d is not a child of the tool call span", i)
+		}
+		attrs := spanAttributes(span)
+		if attrs["http.request.method"] != "GET" || attrs["url.template"] != "tickets/{id}.json" || attrs["desk.request_id"] != "req-1" {
+			t.Errorf("attributes of span %d = %v, want the method, endpoint and request ID", i, attrs)
+		}
+		if attrs["http.response.status_code"] != want.status || span.Status().Code != want.code {
+			t.Errorf("span %d has status %s and code %s, want %s and %s", i, attrs["http.response.status_code"], span.Status().Code, want.status, want.code)
+		}
+	}
+	if attrs := spanAttributes(spans[1]); attrs["error.type"] != "404" {
+		t.Errorf("error type = %q, want 404", attrs["error.type"])
+	}
+}
+
+func TestTraceNetworkError(t *testing.T) {
+	recorder := recordSpans(t)
+	api := httptest.NewServer(http.NotFoundHandler())
+	api.Close()
+	c := NewClient(api.URL, "token", WithRetry(RetryConfig{}))
+
+	if err := c.Do(context.Background(), http.MethodGet, "tags.json", nil, nil, nil); err == nil {
+		t.Fatal("request to a closed server succeeded")
+	}
+	spans := recorder.Ended()
+	if len(spans) != 1 {
+		t.Fatalf("%d spans, want 1", len(spans))
+	}
+	if span := spans[0]; span.Status().Code != codes.Error || spanAttributes(span)["error.type"] != "_OTHER" {
+		t.Errorf("span status = %v, attributes = %v, want an error", span.Status(), spanAttributes(span))
+	}
+}
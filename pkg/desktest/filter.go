@@ -0,0 +1,222 @@
+package desktest
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// match reports whether a record satisfies a decoded filter. Conditions on
+// a relation compare its ID, and conditions on a list of relations hold if
+// they hold for any of them.
+func match(record Record, filter interface{}) (bool, error) {
+	conditions, ok := filter.(map[string]interface{})
+	if !ok {
+		return false, fmt.Errorf("expected an object, got %v", filter)
+	}
+	for key, value := range conditions {
+		var ok bool
+		var err error
+		switch key {
+		case "$and", "$or":
+			ok, err = matchLogical(record, key, value)
+		default:
+			ok, err = matchField(lookup(record, key), value)
+		}
+		if err != nil || !ok {
+			return false, err
+		}
+	}
+	return true, nil
+}
+
+func matchLogical(record Record, op string, value interface{}) (bool, error) {
+	children, ok := value.([]interface{})
+	if !ok {
+		return false, fmt.Errorf("%s expects an array", op)
+	}
+	for _, child := range children {
+		ok, err := match(record, child)
+		if err != nil {
+			return false, err
+		}
+		if op == "$or" && ok {
+			return true, nil
+		}
+		if op == "$and" && !ok {
+			return false, nil
+		}
+	}
+	return op == "$and", nil
+}
+
+// matchField applies the operators of a field condition. A bare value is
+// compared with $eq.
+func matchField(actual, condition interface{}) (bool, error) {
+	ops, ok := condition.(map[string]interface{})
+	if !ok {
+		ops = map[string]interface{}{"$eq": condition}
+	}
+	values := scalars(actual)
+	for op, expected := range ops {
+		ok, err := apply(op, values, expected)
+		if err != nil || !ok {
+			return false, err
+		}
+	}
+	return true, nil
+}
+
+func apply(op string, values []interface{}, expected interface{}) (bool, error) {
+	switch op {
+	case "$eq":
+		return anyOf(values, func(v interface{}) bool { return equal(v, expected) }), nil
+	case "$ne":
+		return !anyOf(values, func(v interface{}) bool { return equal(v, expected) }), nil
+	case "$in", "$nin":
+		list, ok := expected.([]interface{})
+		if !ok {
+			return false, fmt.Errorf("%s expects an array", op)
+		}
+		in := anyOf(values, func(v interface{}) bool {
+			return anyOf(list, func(e interface{}) bool { return equal(v, e) })
+		})
+		return in == (op == "$in"), nil
+	case "$lt", "$lte", "$gt", "$gte":
+		return anyOf(values, func(v interface{}) bool {
+			c, ok := compare(v, expected)
+			if !ok {
+				return false
+			}
+			switch op {
+			case "$lt":
+				return c < 0
+			case "$lte":
+				return c <= 0
+			case "$gt":
+				return c > 0
+			}
+			return c >= 0
+		}), nil
+	}
+	return false, fmt.Errorf("unsupported operator %s", op)
+}
+
+// lookup returns the value of a field, following dotted paths into objects
+func lookup(record Record, field string) interface{} {
+	var value interface{} = record
+	for _, part := range strings.Split(field, ".") {
+		m, ok := value.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		value = m[part]
+	}
+	return value
+}
+
+// scalars flattens a field value to the values conditions compare against:
+// relations become their IDs and lists their elements
+func scalars(v interface{}) []interface{} {
+	switch v := v.(type) {
+	case []interface{}:
+		var out []interface{}
+		for _, e := range v {
+			out = append(out, scalars(e)...)
+		}
+		return out
+	case map[string]interface{}:
+		if id, ok := v["id"]; ok {
+			return []interface{}{id}
+		}
+	}
+	return []interface{}{v}
+}
+
+func anyOf(values []interface{}, f func(interface{}) bool) bool {
+	for _, v := range values {
+		if f(v) {
+			return true
+		}
+	}
+	return false
+}
+
+// equal compares values loosely: numbers by value, strings without regard
+// to case and timestamps by instant
+func equal(a, b interface{}) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	if ab, ok := a.(bool); ok {
+		bb, ok := b.(bool)
+		return ok && ab == bb
+	}
+	c, ok := compare(a, b)
+	return ok && c == 0
+}
+
+// compare orders two values of the same kind. Relations are ordered by ID.
+func compare(a, b interface{}) (int, bool) {
+	if m, ok := a.(map[string]interface{}); ok {
+		a = m["id"]
+	}
+	if m, ok := b.(map[string]interface{}); ok {
+		b = m["id"]
+	}
+	if af, ok := toFloat(a); ok {
+		bf, ok := toFloat(b)
+		if !ok {
+			return 0, false
+		}
+		switch {
+		case af < bf:
+			return -1, true
+		case af > bf:
+			return 1, true
+		}
+		return 0, true
+	}
+	as, ok := a.(string)
+	if !ok {
+		return 0, false
+	}
+	bs, ok := b.(string)
+	if !ok {
+		return 0, false
+	}
+	if at, ok := parseTime(as); ok {
+		if bt, ok := parseTime(bs); ok {
+			return at.Compare(bt), true
+		}
+	}
+	return strings.Compare(strings.ToLower(as), strings.ToLower(bs)), true
+}
+
+func parseTime(s string) (time.Time, bool) {
+	for _, layout := range []string{time.RFC3339Nano, "2006-01-02"} {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
+
+func toFloat(v interface{}) (float64, bool) {
+	switch v := v.(type) {
+	case float64:
+		return v, true
+	case int:
+		return float64(v), true
+	case json.Number:
+		f, err := v.Float64()
+		return f, err == nil
+	}
+	return 0, false
+}
+
+func toInt(v interface{}) (int, bool) {
+	f, ok := toFloat(v)
+	return int(f), ok
+}
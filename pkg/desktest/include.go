@@ -0,0 +1,123 @@
+package desktest
+
+import (
+	"sort"
+	"strings"
+)
+
+// relations maps the fields that refer to other records to the collection
+// of the records. The type of a relation takes precedence, since fields such
+// as createdBy can refer to users or customers.
+var relations = map[string]string{
+	"agent":     "users",
+	"assignee":  "users",
+	"user":      "users",
+	"users":     "users",
+	"createdBy": "users",
+	"updatedBy": "users",
+	"customer":  "customers",
+	"contact":   "customers",
+	"company":   "companies",
+	"companies": "companies",
+	"inbox":     "inboxes",
+	"inboxes":   "inboxes",
+	"status":    "ticketstatuses",
+	"type":      "tickettypes",
+	"priority":  "ticketpriorities",
+	"source":    "ticketsources",
+	"tags":      "tags",
+}
+
+type ref struct {
+	collection string
+	id         int
+}
+
+// included sideloads the records related to records of collection. includes
+// is "all" or a comma separated list of collections; related records of
+// included records are sideloaded as well. Tickets include their messages.
+func (s *Server) included(collection string, records []Record, includes string) map[string][]Record {
+	out := map[string][]Record{}
+	if includes == "" {
+		return out
+	}
+	wanted := map[string]bool{}
+	for _, name := range strings.Split(includes, ",") {
+		wanted[strings.TrimSpace(name)] = true
+	}
+	want := func(collection string) bool {
+		return wanted["all"] || wanted[collection]
+	}
+
+	pending := append([]Record(nil), records...)
+	if collection == "tickets" && want("messages") {
+		ids := map[int]bool{}
+		for _, r := range records {
+			id, _ := toInt(r["id"])
+			ids[id] = true
+		}
+		for _, m := range s.sorted("messages") {
+			if id, _ := toInt(lookup(m, "ticket.id")); ids[id] {
+				out["messages"] = append(out["messages"], m)
+				pending = append(pending, m)
+			}
+		}
+	}
+
+	seen := map[ref]bool{}
+	for len(pending) > 0 {
+		r := pending[0]
+		pending = pending[1:]
+		for _, rel := range refs(r) {
+			if seen[rel] || !want(rel.collection) {
+				continue
+			}
+			seen[rel] = true
+			if related, ok := s.records[rel.collection][rel.id]; ok {
+				out[rel.collection] = append(out[rel.collection], related)
+				pending = append(pending, related)
+			}
+		}
+	}
+	for _, list := range out {
+		sort.SliceStable(list, func(i, j int) bool {
+			a, _ := toInt(list[i]["id"])
+			b, _ := toInt(list[j]["id"])
+			return a < b
+		})
+	}
+	return out
+}
+
+// refs returns the records a record refers to
+func refs(r Record) []ref {
+	var out []ref
+	for field, value := range r {
+		collection, ok := relations[field]
+		if !ok {
+			continue
+		}
+		var items []interface{}
+		if list, ok := value.([]interface{}); ok {
+			items = list
+		} else {
+			items = []interface{}{value}
+		}
+		for _, item := range items {
+			m, ok := item.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			id, _ := toInt(m["id"])
+			if id == 0 {
+				continue
+			}
+			c := collection
+			if t, _ := m["type"].(string); singulars[t] != "" {
+				c = t
+			}
+			out = append(out, ref{collection: c, id: id})
+		}
+	}
+	return out
+}
@@ -0,0 +1,89 @@
+package desktest
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"github.com/mark3labs/mcp-go/client"
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"github.com/ready4god2513/deskmcp/pkg/desk"
+)
+
+// Setup starts a fake Desk site and returns it with an MCP client of a server
+// whose tools register adds with a client of the site. Both are closed when
+// the test ends.
+func Setup(t testing.TB, register func(s *server.MCPServer, client *desk.Client)) (*Server, *client.Client) {
+	t.Helper()
+	api := NewServer()
+	t.Cleanup(api.Close)
+
+	s := server.NewMCPServer("test", "1.0.0")
+	register(s, api.Client())
+
+	c, err := NewMCPClient(context.Background(), s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { c.Close() })
+	return api, c
+}
+
+// Call calls the named tool with args and fails the test if the call could
+// not be made. Failures of the tool are returned in the result.
+func Call(t testing.TB, c *client.Client, name string, args map[string]interface{}) *mcp.CallToolResult {
+	t.Helper()
+	result, err := CallTool(context.Background(), c, name, args)
+	if err != nil {
+		t.Fatalf("%s: %v", name, err)
+	}
+	return result
+}
+
+// NewMCPClient returns an initialized MCP client connected in process to s
+func NewMCPClient(ctx context.Context, s *server.MCPServer) (*client.Client, error) {
+	c, err := client.NewInProcessClient(s)
+	if err != nil {
+		return nil, err
+	}
+	if err := c.Start(ctx); err != nil {
+		return nil, err
+	}
+	var init mcp.InitializeRequest
+	init.Params.ProtocolVersion = mcp.LATEST_PROTOCOL_VERSION
+	init.Params.ClientInfo = mcp.Implementation{Name: "desktest", Version: "1.0.0"}
+	if _, err := c.Initialize(ctx, init); err != nil {
+		c.Close()
+		return nil, err
+	}
+	return c, nil
+}
+
+// CallTool calls the named tool with args
+func CallTool(ctx context.Context, c *client.Client, name string, args map[string]interface{}) (*mcp.CallToolResult, error) {
+	var request mcp.CallToolRequest
+	request.Params.Name = name
+	request.Params.Arguments = args
+	return c.CallTool(ctx, request)
+}
+
+// DecodeResult decodes the JSON text of a successful tool result into out
+func DecodeResult(result *mcp.CallToolResult, out interface{}) error {
+	text := ResultText(result)
+	if result.IsError {
+		return fmt.Errorf("tool failed: %s", text)
+	}
+	return json.Unmarshal([]byte(text), out)
+}
+
+// ResultText returns the text content of a tool result
+func ResultText(result *mcp.CallToolResult) string {
+	for _, content := range result.Content {
+		if text, ok := content.(mcp.TextContent); ok {
+			return text.Text
+		}
+	}
+	return ""
+}
@@ -0,0 +1,23 @@
+package desktest
+
+// SeedReferenceData stores the reference data a new Desk site starts with:
+// ticket statuses, types, priorities and sources, a support inbox and an
+// agent. The agent is the first user, who authors the messages posted
+// through the fake.
+func (s *Server) SeedReferenceData() {
+	s.Add("users", Record{"firstName": "Alex", "lastName": "Agent", "email": "alex@example.com", "role": "admin"})
+
+	for _, name := range []string{"Active", "Waiting on customer", "On hold", "Solved", "Closed"} {
+		s.Add("ticketstatuses", Record{"name": name, "code": name})
+	}
+	for _, name := range []string{"Question", "Problem", "Feature request"} {
+		s.Add("tickettypes", Record{"name": name})
+	}
+	for _, name := range []string{"Low", "Medium", "High", "Urgent"} {
+		s.Add("ticketpriorities", Record{"name": name})
+	}
+	for _, name := range []string{"Email", "Phone", "Web form"} {
+		s.Add("ticketsources", Record{"name": name})
+	}
+	s.Add("inboxes", Record{"name": "Support", "email": "support@example.com"})
+}
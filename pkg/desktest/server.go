@@ -0,0 +1,604 @@
+// Package desktest provides an in-memory fake of the Teamwork Desk v2 API for
+// hermetic tests. The fake serves the endpoints the handlers use, with
+// pagination, filters and sideloaded includes, so that a handler can be
+// exercised end to end:
+//
+//	api := desktest.NewServer()
+//	defer api.Close()
+//	api.SeedReferenceData()
+//	api.Add("customers", models.Customer{Email: "ada@example.com"})
+//
+//	s := server.NewMCPServer("test", "1.0.0")
+//	customers.NewCustomerHandler(api.Client()).RegisterTools(s)
+//
+//	c, _ := desktest.NewMCPClient(ctx, s)
+//	result, _ := desktest.CallTool(ctx, c, "list_customers", nil)
+package desktest
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ready4god2513/deskmcp/pkg/desk"
+)
+
+// DefaultPageSize is the page size of list requests without pageSize
+const DefaultPageSize = 50
+
+// singulars maps the collections the fake serves to the key a single record
+// is wrapped in
+var singulars = map[string]string{
+	"tickets":          "ticket",
+	"customers":        "customer",
+	"companies":        "company",
+	"users":            "user",
+	"tags":             "tag",
+	"ticketstatuses":   "ticketstatus",
+	"tickettypes":      "tickettype",
+	"ticketpriorities": "ticketpriority",
+	"ticketsources":    "ticketsource",
+	"inboxes":          "inbox",
+	"messages":         "message",
+}
+
+// uniqueFields names the field that must be unique within a collection
+var uniqueFields = map[string]string{
+	"customers":      "email",
+	"users":          "email",
+	"companies":      "name",
+	"tags":           "name",
+	"ticketstatuses": "name",
+	"tickettypes":    "name",
+}
+
+// requiredFields names the fields a record must have to be created
+var requiredFields = map[string][]string{
+	"tickets":        {"subject"},
+	"customers":      {"email"},
+	"users":          {"email"},
+	"companies":      {"name"},
+	"tags":           {"name"},
+	"ticketstatuses": {"name"},
+	"tickettypes":    {"name"},
+}
+
+// serverFields are set by the server and ignored in request bodies
+var serverFields = []string{"id", "createdAt", "updatedAt"}
+
+// Record is a stored record, as decoded from JSON
+type Record = map[string]interface{}
+
+// Request is a request received by the fake
+type Request struct {
+	Method string
+	Path   string
+	Query  url.Values
+}
+
+// Server is a fake Desk API backed by an in-memory store
+type Server struct {
+	*httptest.Server
+
+	token string
+	now   func() time.Time
+
+	mu       sync.Mutex
+	records  map[string]map[int]Record
+	nextID   int
+	requests []Request
+	failures []*failure
+}
+
+type failure struct {
+	method string
+	path   string
+	status int
+	times  int
+}
+
+// Option configures a Server
+type Option func(*Server)
+
+// WithToken sets the API token the fake accepts. Requests with another
+// token are rejected with 401, and an empty token accepts any request.
+func WithToken(token string) Option {
+	return func(s *Server) {
+		s.token = token
+	}
+}
+
+// WithClock sets the clock used to timestamp records
+func WithClock(now func() time.Time) Option {
+	return func(s *Server) {
+		s.now = now
+	}
+}
+
+// NewServer starts a fake Desk API. Close it when done.
+func NewServer(opts ...Option) *Server {
+	s := &Server{
+		token:   "desktest-token",
+		now:     time.Now,
+		records: make(map[string]map[int]Record),
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	for collection := range singulars {
+		s.records[collection] = make(map[int]Record)
+	}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.serveHTTP))
+	return s
+}
+
+// Client returns a client of the fake. Retries are disabled unless opts
+// enable them, so that injected failures surface immediately.
+func (s *Server) Client(opts ...desk.Option) *desk.Client {
+	opts = append([]desk.Option{desk.WithRetry(desk.RetryConfig{})}, opts...)
+	return desk.NewClient(s.URL, s.token, opts...)
+}
+
+// Add stores a record in collection, e.g. Add("tags", models.Tag{Name: "vip"}),
+// and returns its ID. The record may be a model or a map. A record without
+// an ID is given one, and missing timestamps are set to now.
+func (s *Server) Add(collection string, record interface{}) int {
+	r, err := toRecord(record)
+	if err != nil {
+		panic(fmt.Sprintf("desktest: invalid %s record: %v", collection, err))
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.records[collection]; !ok {
+		panic(fmt.Sprintf("desktest: unknown collection %q", collection))
+	}
+	id, _ := toInt(r["id"])
+	if id == 0 {
+		s.nextID++
+		id = s.nextID
+	} else if id > s.nextID {
+		s.nextID = id
+	}
+	now := s.timestamp()
+	r["id"] = id
+	for _, key := range []string{"createdAt", "updatedAt"} {
+		if isZeroTime(r[key]) {
+			r[key] = now
+		}
+	}
+	s.records[collection][id] = r
+	return id
+}
+
+// Get returns a copy of the record of collection with the given ID
+func (s *Server) Get(collection string, id int) (Record, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	r, ok := s.records[collection][id]
+	if !ok {
+		return nil, false
+	}
+	return clone(r), true
+}
+
+// Records returns copies of the records of collection in ID order
+func (s *Server) Records(collection string) []Record {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	records := s.sorted(collection)
+	for i, r := range records {
+		records[i] = clone(r)
+	}
+	return records
+}
+
+// Find returns the ID of the first record of collection whose field equals
+// value, or 0 if there is none
+func (s *Server) Find(collection, field string, value interface{}) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, r := range s.sorted(collection) {
+		if equal(r[field], value) {
+			id, _ := toInt(r["id"])
+			return id
+		}
+	}
+	return 0
+}
+
+// Fail makes the next times requests matching method and path fail with
+// status. An empty method matches every method, and path matches request
+// paths it is a prefix of, e.g. "tickets" or "tickets/42.json".
+func (s *Server) Fail(method, path string, status, times int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.failures = append(s.failures, &failure{
+		method: method,
+		path:   strings.TrimPrefix(path, "/"),
+		status: status,
+		times:  times,
+	})
+}
+
+// Requests returns the requests received so far
+func (s *Server) Requests() []Request {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Request(nil), s.requests...)
+}
+
+// Reset drops the records, recorded requests and pending failures
+func (s *Server) Reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for collection := range s.records {
+		s.records[collection] = make(map[int]Record)
+	}
+	s.nextID = 0
+	s.requests = nil
+	s.failures = nil
+}
+
+func (s *Server) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	path := strings.TrimPrefix(r.URL.Path, "/")
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.requests = append(s.requests, Request{Method: r.Method, Path: path, Query: r.URL.Query()})
+	w.Header().Set(desk.RequestIDHeader, "desktest-"+strconv.Itoa(len(s.requests)))
+
+	if s.token != "" && r.Header.Get("Authorization") != "Bearer "+s.token {
+		writeError(w, http.StatusUnauthorized, "Invalid API token", nil)
+		return
+	}
+	if f := s.failure(r.Method, path); f != nil {
+		if f.status == http.StatusTooManyRequests {
+			w.Header().Set("Retry-After", "1")
+		}
+		writeError(w, f.status, http.StatusText(f.status), nil)
+		return
+	}
+
+	parts := strings.Split(strings.TrimSuffix(path, ".json"), "/")
+	if _, ok := singulars[parts[0]]; !ok || !strings.HasSuffix(path, ".json") {
+		writeError(w, http.StatusNotFound, "Not found", nil)
+		return
+	}
+	collection := parts[0]
+
+	switch {
+	case len(parts) == 1 && r.Method == http.MethodGet:
+		s.list(w, r, collection)
+	case len(parts) == 1 && r.Method == http.MethodPost:
+		s.create(w, r, collection)
+	case len(parts) == 2:
+		id, err := strconv.Atoi(parts[1])
+		if err != nil {
+			writeError(w, http.StatusNotFound, "Not found", nil)
+			return
+		}
+		switch r.Method {
+		case http.MethodGet:
+			s.get(w, r, collection, id)
+		case http.MethodPut, http.MethodPatch:
+			s.update(w, r, collection, id)
+		case http.MethodDelete:
+			s.delete(w, collection, id)
+		default:
+			writeError(w, http.StatusMethodNotAllowed, "Method not allowed", nil)
+		}
+	case len(parts) == 3 && collection == "tickets" && parts[2] == "messages" && r.Method == http.MethodPost:
+		id, err := strconv.Atoi(parts[1])
+		if err != nil {
+			writeError(w, http.StatusNotFound, "Not found", nil)
+			return
+		}
+		s.postMessage(w, r, id)
+	default:
+		writeError(w, http.StatusNotFound, "Not found", nil)
+	}
+}
+
+// failure returns the pending failure matching a request, if any
+func (s *Server) failure(method, path string) *failure {
+	for i, f := range s.failures {
+		if (f.method == "" || f.method == method) && strings.HasPrefix(path, f.path) {
+			f.times--
+			if f.times <= 0 {
+				s.failures = append(s.failures[:i], s.failures[i+1:]...)
+			}
+			return f
+		}
+	}
+	return nil
+}
+
+func (s *Server) list(w http.ResponseWriter, r *http.Request, collection string) {
+	query := r.URL.Query()
+	records := s.sorted(collection)
+
+	if raw := query.Get("filter"); raw != "" {
+		var f interface{}
+		if err := json.Unmarshal([]byte(raw), &f); err != nil {
+			writeError(w, http.StatusBadRequest, "Invalid filter: "+err.Error(), nil)
+			return
+		}
+		matched := records[:0:0]
+		for _, record := range records {
+			ok, err := match(record, f)
+			if err != nil {
+				writeError(w, http.StatusBadRequest, "Invalid filter: "+err.Error(), nil)
+				return
+			}
+			if ok {
+				matched = append(matched, record)
+			}
+		}
+		records = matched
+	}
+
+	if orderBy := query.Get("orderBy"); orderBy != "" {
+		desc := strings.EqualFold(query.Get("orderMode"), "desc")
+		sort.SliceStable(records, func(i, j int) bool {
+			c, _ := compare(records[i][orderBy], records[j][orderBy])
+			if desc {
+				return c > 0
+			}
+			return c < 0
+		})
+	}
+
+	page, pageSize := 1, DefaultPageSize
+	if v, err := strconv.Atoi(query.Get("page")); err == nil && v > 0 {
+		page = v
+	}
+	if v, err := strconv.Atoi(query.Get("pageSize")); err == nil && v > 0 {
+		pageSize = v
+	}
+	total := len(records)
+	pages := (total + pageSize - 1) / pageSize
+	start := min((page-1)*pageSize, total)
+	end := min(start+pageSize, total)
+	records = records[start:end]
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		collection: records,
+		"included": s.included(collection, records, query.Get("includes")),
+		"pagination": map[string]interface{}{
+			"records":      total,
+			"pageSize":     pageSize,
+			"pages":        pages,
+			"page":         page,
+			"hasMorePages": page < pages,
+		},
+	})
+}
+
+func (s *Server) get(w http.ResponseWriter, r *http.Request, collection string, id int) {
+	record, ok := s.records[collection][id]
+	if !ok {
+		writeError(w, http.StatusNotFound, "Not found", nil)
+		return
+	}
+	s.writeRecord(w, http.StatusOK, collection, record, r.URL.Query().Get("includes"))
+}
+
+func (s *Server) create(w http.ResponseWriter, r *http.Request, collection string) {
+	record, ok := s.readRecord(w, r, collection)
+	if !ok {
+		return
+	}
+	if !s.validate(w, collection, 0, record) {
+		return
+	}
+	s.nextID++
+	now := s.timestamp()
+	record["id"] = s.nextID
+	record["createdAt"] = now
+	record["updatedAt"] = now
+	s.records[collection][s.nextID] = record
+	s.writeRecord(w, http.StatusCreated, collection, record, "")
+}
+
+func (s *Server) update(w http.ResponseWriter, r *http.Request, collection string, id int) {
+	current, ok := s.records[collection][id]
+	if !ok {
+		writeError(w, http.StatusNotFound, "Not found", nil)
+		return
+	}
+	changes, ok := s.readRecord(w, r, collection)
+	if !ok {
+		return
+	}
+	record := clone(current)
+	for key, value := range changes {
+		record[key] = value
+	}
+	if !s.validate(w, collection, id, record) {
+		return
+	}
+	record["updatedAt"] = s.timestamp()
+	s.records[collection][id] = record
+	s.writeRecord(w, http.StatusOK, collection, record, "")
+}
+
+func (s *Server) delete(w http.ResponseWriter, collection string, id int) {
+	if _, ok := s.records[collection][id]; !ok {
+		writeError(w, http.StatusNotFound, "Not found", nil)
+		return
+	}
+	delete(s.records[collection], id)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) postMessage(w http.ResponseWriter, r *http.Request, ticketID int) {
+	ticket, ok := s.records["tickets"][ticketID]
+	if !ok {
+		writeError(w, http.StatusNotFound, "Not found", nil)
+		return
+	}
+	var payload struct {
+		Message struct {
+			Body       string `json:"body"`
+			ThreadType string `json:"threadType"`
+		} `json:"message"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid JSON: "+err.Error(), nil)
+		return
+	}
+	if strings.TrimSpace(payload.Message.Body) == "" {
+		writeError(w, http.StatusUnprocessableEntity, "Validation failed",
+			map[string][]string{"body": {"can't be blank"}})
+		return
+	}
+
+	s.nextID++
+	now := s.timestamp()
+	message := Record{
+		"id":         s.nextID,
+		"htmlBody":   payload.Message.Body,
+		"textBody":   payload.Message.Body,
+		"threadType": payload.Message.ThreadType,
+		"ticket":     Record{"id": ticketID, "type": "tickets"},
+		"createdAt":  now,
+		"updatedAt":  now,
+	}
+	if agents := s.sorted("users"); len(agents) > 0 {
+		message["createdBy"] = Record{"id": agents[0]["id"], "type": "users"}
+	}
+	s.records["messages"][s.nextID] = message
+	count, _ := toInt(ticket["messageCount"])
+	ticket["messageCount"] = count + 1
+	ticket["updatedAt"] = now
+	s.writeRecord(w, http.StatusCreated, "messages", message, "all")
+}
+
+// readRecord decodes the record wrapped in the singular key of collection,
+// dropping the fields the server sets
+func (s *Server) readRecord(w http.ResponseWriter, r *http.Request, collection string) (Record, bool) {
+	data, err := io.ReadAll(r.Body)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid body: "+err.Error(), nil)
+		return nil, false
+	}
+	var payload map[string]Record
+	if err := json.Unmarshal(data, &payload); err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid JSON: "+err.Error(), nil)
+		return nil, false
+	}
+	record, ok := payload[singulars[collection]]
+	if !ok || record == nil {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("Missing %q in the request body", singulars[collection]), nil)
+		return nil, false
+	}
+	for _, key := range serverFields {
+		delete(record, key)
+	}
+	return record, true
+}
+
+// validate checks the required and unique fields of a record being saved
+// under id, 0 for a new record, and writes a 422 response if they are invalid
+func (s *Server) validate(w http.ResponseWriter, collection string, id int, record Record) bool {
+	errs := map[string][]string{}
+	for _, field := range requiredFields[collection] {
+		if v, _ := record[field].(string); strings.TrimSpace(v) == "" {
+			errs[field] = append(errs[field], "can't be blank")
+		}
+	}
+	if field, ok := uniqueFields[collection]; ok && len(errs[field]) == 0 {
+		for otherID, other := range s.records[collection] {
+			if otherID != id && equal(other[field], record[field]) {
+				errs[field] = append(errs[field], "has already been taken")
+				break
+			}
+		}
+	}
+	if len(errs) > 0 {
+		writeError(w, http.StatusUnprocessableEntity, "Validation failed", errs)
+		return false
+	}
+	return true
+}
+
+func (s *Server) writeRecord(w http.ResponseWriter, status int, collection string, record Record, includes string) {
+	writeJSON(w, status, map[string]interface{}{
+		singulars[collection]: record,
+		"included":            s.included(collection, []Record{record}, includes),
+	})
+}
+
+// sorted returns the records of collection in ID order
+func (s *Server) sorted(collection string) []Record {
+	records := make([]Record, 0, len(s.records[collection]))
+	for _, r := range s.records[collection] {
+		records = append(records, r)
+	}
+	sort.Slice(records, func(i, j int) bool {
+		a, _ := toInt(records[i]["id"])
+		b, _ := toInt(records[j]["id"])
+		return a < b
+	})
+	return records
+}
+
+func (s *Server) timestamp() string {
+	return s.now().UTC().Format(time.RFC3339Nano)
+}
+
+func writeJSON(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(body)
+}
+
+func writeError(w http.ResponseWriter, status int, message string, fields map[string][]string) {
+	body := map[string]interface{}{"message": message}
+	if len(fields) > 0 {
+		body["errors"] = fields
+	}
+	writeJSON(w, status, body)
+}
+
+// toRecord converts a model or map to a record by a JSON round trip
+func toRecord(v interface{}) (Record, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var r Record
+	if err := json.Unmarshal(data, &r); err != nil {
+		return nil, err
+	}
+	if r == nil {
+		return nil, fmt.Errorf("not an object")
+	}
+	return r, nil
+}
+
+func clone(r Record) Record {
+	c, err := toRecord(r)
+	if err != nil {
+		panic(fmt.Sprintf("desktest: %v", err))
+	}
+	return c
+}
+
+func isZeroTime(v interface{}) bool {
+	s, _ := v.(string)
+	if s == "" {
+		return true
+	}
+	t, err := time.Parse(time.RFC3339Nano, s)
+	return err == nil && t.IsZero()
+}
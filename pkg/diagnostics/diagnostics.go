@@ -0,0 +1,62 @@
+// Package diagnostics exposes tools for debugging the server itself rather
+// than working with Desk data.
+package diagnostics
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"github.com/ready4god2513/deskmcp/pkg/desk"
+	"github.com/ready4god2513/deskmcp/pkg/utils"
+)
+
+type DiagnosticsHandler struct {
+	deskClient *desk.Client
+}
+
+func NewDiagnosticsHandler(deskClient *desk.Client) *DiagnosticsHandler {
+	return &DiagnosticsHandler{
+		deskClient: deskClient,
+	}
+}
+
+func (h *DiagnosticsHandler) RegisterTools(s *server.MCPServer) {
+	// Cache statistics
+	utils.AddTool(s, mcp.NewTool("cache_stats",
+		mcp.WithDescription("Show the statistics of the cache of Desk reference data (ticket statuses, types, priorities and sources, tags, users and inboxes): hits, misses, evictions, invalidations and the cached entries and TTL of each resource"),
+	), h.cacheStats)
+
+	// Rate limit
+	utils.AddTool(s, mcp.NewTool("get_rate_limit",
+		mcp.WithDescription("Get the Desk API request quota: the limit, the remaining requests and when the quota resets, as last reported by Desk, together with the client side rate limit and the number of retried and rate limited requests. Use it to pace bulk work."),
+	), h.getRateLimit)
+}
+
+func (h *DiagnosticsHandler) cacheStats(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	client := desk.ClientFromContext(ctx, h.deskClient)
+	if client == nil || client.Cache() == nil {
+		return mcp.NewToolResultText("Caching is disabled"), nil
+	}
+
+	data, err := json.Marshal(client.Cache().Stats())
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Failed to marshal cache stats: %v", err)), nil
+	}
+	return mcp.NewToolResultText(string(data)), nil
+}
+
+func (h *DiagnosticsHandler) getRateLimit(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	client := desk.ClientFromContext(ctx, h.deskClient)
+	if client == nil {
+		return mcp.NewToolResultError("Failed to get rate limit: no Desk credentials"), nil
+	}
+
+	data, err := json.Marshal(client.RateLimit())
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Failed to marshal rate limit: %v", err)), nil
+	}
+	return mcp.NewToolResultText(string(data)), nil
+}
@@ -0,0 +1,150 @@
+package filter
+
+import (
+	"math"
+	"strconv"
+	"time"
+)
+
+// Kind describes the type of values a field can be compared against
+type Kind int
+
+const (
+	// KindAny accepts strings, numbers and booleans unchanged
+	KindAny Kind = iota
+	// KindText accepts strings
+	KindText
+	// KindNumber accepts numbers and numeric strings
+	KindNumber
+	// KindID accepts integer IDs given as numbers or strings
+	KindID
+	// KindTime accepts RFC 3339 timestamps and YYYY-MM-DD dates
+	KindTime
+	// KindBool accepts booleans
+	KindBool
+	// KindRef accepts integer IDs given as numbers or strings, or the names
+	// of the related records, which are resolved to IDs before sending
+	KindRef
+)
+
+// Field describes a filterable field. Name is the field name sent to the
+// Desk API, Aliases are alternative names accepted from callers.
+type Field struct {
+	Name    string
+	Aliases []string
+	Kind    Kind
+}
+
+// Text returns a text field
+func Text(name string, aliases ...string) Field {
+	return Field{Name: name, Aliases: aliases, Kind: KindText}
+}
+
+// Number returns a numeric field
+func Number(name string, aliases ...string) Field {
+	return Field{Name: name, Aliases: aliases, Kind: KindNumber}
+}
+
+// ID returns a field holding the ID of a related record
+func ID(name string, aliases ...string) Field {
+	return Field{Name: name, Aliases: aliases, Kind: KindID}
+}
+
+// Time returns a date/time field
+func Time(name string, aliases ...string) Field {
+	return Field{Name: name, Aliases: aliases, Kind: KindTime}
+}
+
+// Bool returns a boolean field
+func Bool(name string, aliases ...string) Field {
+	return Field{Name: name, Aliases: aliases, Kind: KindBool}
+}
+
+// Ref returns a field holding the ID of a related record that can also be
+// given by name
+func Ref(name string, aliases ...string) Field {
+	return Field{Name: name, Aliases: aliases, Kind: KindRef}
+}
+
+// Any returns a field that accepts any scalar value
+func Any(name string, aliases ...string) Field {
+	return Field{Name: name, Aliases: aliases, Kind: KindAny}
+}
+
+func (k Kind) normalize(path string, value interface{}) (interface{}, error) {
+	switch value.(type) {
+	case map[string]interface{}, []interface{}:
+		return nil, errorf(path, "expected a single value, got %s", describe(value))
+	case nil:
+		return nil, nil
+	}
+
+	switch k {
+	case KindText:
+		if s, ok := value.(string); ok {
+			return s, nil
+		}
+		return nil, errorf(path, "expected a string, got %s", describe(value))
+
+	case KindNumber:
+		switch v := value.(type) {
+		case float64:
+			return v, nil
+		case string:
+			if f, err := strconv.ParseFloat(v, 64); err == nil {
+				return f, nil
+			}
+		}
+		return nil, errorf(path, "expected a number, got %s", describe(value))
+
+	case KindID:
+		switch v := value.(type) {
+		case float64:
+			if v == math.Trunc(v) && v > 0 {
+				return int(v), nil
+			}
+		case string:
+			if id, err := strconv.Atoi(v); err == nil && id > 0 {
+				return id, nil
+			}
+		}
+		return nil, errorf(path, "expected a positive integer ID, got %v", value)
+
+	case KindRef:
+		switch v := value.(type) {
+		case float64:
+			if v == math.Trunc(v) && v > 0 {
+				return int(v), nil
+			}
+		case string:
+			if id, err := strconv.Atoi(v); err == nil && id > 0 {
+				return id, nil
+			}
+			if v != "" {
+				return v, nil
+			}
+		}
+		return nil, errorf(path, "expected a positive integer ID or a name, got %v", value)
+
+	case KindTime:
+		s, ok := value.(string)
+		if !ok {
+			return nil, errorf(path, "expected a date string, got %s", describe(value))
+		}
+		if t, err := time.Parse(time.RFC3339, s); err == nil {
+			return t.UTC().Format(time.RFC3339), nil
+		}
+		if t, err := time.Parse("2006-01-02", s); err == nil {
+			return t.Format(time.RFC3339), nil
+		}
+		return nil, errorf(path, "expected an RFC 3339 timestamp or YYYY-MM-DD date, got %q", s)
+
+	case KindBool:
+		if b, ok := value.(bool); ok {
+			return b, nil
+		}
+		return nil, errorf(path, "expected a boolean, got %s", describe(value))
+	}
+
+	return value, nil
+}
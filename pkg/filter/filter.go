@@ -0,0 +1,419 @@
+// Package filter compiles the MongoDB-style filter objects accepted by the
+// list tools into the JSON "filter" query parameter understood by the Desk API.
+package filter
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// Operator represents a filter operator
+type Operator string
+
+const (
+	// Comparison operators
+	OpEq  Operator = "$eq"
+	OpNe  Operator = "$ne"
+	OpLt  Operator = "$lt"
+	OpLte Operator = "$lte"
+	OpGt  Operator = "$gt"
+	OpGte Operator = "$gte"
+	OpIn  Operator = "$in"
+	OpNin Operator = "$nin"
+
+	// Logical operators
+	OpAnd Operator = "$and"
+	OpOr  Operator = "$or"
+)
+
+var comparisonOperators = map[Operator]bool{
+	OpEq: true, OpNe: true, OpLt: true, OpLte: true,
+	OpGt: true, OpGte: true, OpIn: true, OpNin: true,
+}
+
+// Expr is a node of a parsed filter expression
+type Expr interface {
+	toJSON() interface{}
+}
+
+// Condition compares a single field against a value
+type Condition struct {
+	Field string
+	Op    Operator
+	Value interface{}
+}
+
+func (c Condition) toJSON() interface{} {
+	return map[string]interface{}{
+		c.Field: map[string]interface{}{string(c.Op): c.Value},
+	}
+}
+
+// Logical combines expressions with $and or $or
+type Logical struct {
+	Op    Operator
+	Exprs []Expr
+}
+
+func (l Logical) toJSON() interface{} {
+	children := make([]interface{}, 0, len(l.Exprs))
+	for _, e := range l.Exprs {
+		children = append(children, e.toJSON())
+	}
+
+	// An $and of conditions on distinct fields can be written as one object,
+	// which is also how the filter was most likely written by the caller.
+	if l.Op == OpAnd && len(children) > 0 {
+		merged := map[string]interface{}{}
+		for _, child := range children {
+			m := child.(map[string]interface{})
+			for k, v := range m {
+				if _, exists := merged[k]; exists || strings.HasPrefix(k, "$") {
+					return map[string]interface{}{string(l.Op): children}
+				}
+				merged[k] = v
+			}
+		}
+		return merged
+	}
+
+	return map[string]interface{}{string(l.Op): children}
+}
+
+// Error describes a malformed filter expression, or a name that could not be
+// resolved to an ID, in which case Err is the error of the resolver
+type Error struct {
+	Path    string
+	Message string
+	Err     error
+}
+
+func (e *Error) Error() string {
+	if e.Path == "" {
+		return e.Message
+	}
+	return fmt.Sprintf("%s: %s", e.Path, e.Message)
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+func errorf(path, format string, args ...interface{}) error {
+	return &Error{Path: path, Message: fmt.Sprintf(format, args...)}
+}
+
+// Encode serializes an expression into the JSON accepted by the Desk API
+func Encode(e Expr) (string, error) {
+	data, err := json.Marshal(e.toJSON())
+	if err != nil {
+		return "", fmt.Errorf("failed to encode filter: %w", err)
+	}
+	return string(data), nil
+}
+
+// Resolver returns the ID of the record named name
+type Resolver func(ctx context.Context, name string) (int, error)
+
+// Resolvers maps the names of Ref fields, as sent to the Desk API, to the
+// resolvers of the names they are compared against
+type Resolvers map[string]Resolver
+
+// Schema describes the filterable fields of a resource
+type Schema struct {
+	resource string
+	fields   []Field
+	byName   map[string]Field
+}
+
+// NewSchema returns a schema for the given resource and fields
+func NewSchema(resource string, fields ...Field) *Schema {
+	s := &Schema{
+		resource: resource,
+		fields:   fields,
+		byName:   make(map[string]Field),
+	}
+	for _, f := range fields {
+		s.byName[f.Name] = f
+		for _, alias := range f.Aliases {
+			s.byName[alias] = f
+		}
+	}
+	return s
+}
+
+// FieldNames returns the names accepted by the schema, including aliases
+func (s *Schema) FieldNames() []string {
+	names := make([]string, 0, len(s.byName))
+	for name := range s.byName {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Parse parses a raw filter object into an expression
+func (s *Schema) Parse(raw map[string]interface{}) (Expr, error) {
+	return s.parseObject("filter", raw)
+}
+
+// Compile parses and validates a raw filter object and returns its JSON form
+func (s *Schema) Compile(raw map[string]interface{}) (string, error) {
+	return s.CompileResolved(context.Background(), raw, nil)
+}
+
+// CompileResolved is like Compile, but also replaces the names compared
+// against Ref fields with the IDs returned by their resolvers
+func (s *Schema) CompileResolved(ctx context.Context, raw map[string]interface{}, resolvers Resolvers) (string, error) {
+	expr, err := s.Parse(raw)
+	if err != nil {
+		return "", err
+	}
+	expr, err = s.resolve(ctx, expr, resolvers)
+	if err != nil {
+		return "", err
+	}
+	return Encode(expr)
+}
+
+// AddToParams compiles the "filter" argument of the request, if any, and adds
+// it to the URL values
+func (s *Schema) AddToParams(params url.Values, request mcp.CallToolRequest) error {
+	return s.AddResolvedToParams(context.Background(), params, request, nil)
+}
+
+// AddResolvedToParams is like AddToParams, but resolves the names compared
+// against Ref fields to IDs
+func (s *Schema) AddResolvedToParams(ctx context.Context, params url.Values, request mcp.CallToolRequest, resolvers Resolvers) error {
+	raw, ok := request.Params.Arguments["filter"]
+	if !ok || raw == nil {
+		return nil
+	}
+
+	filterParams, ok := raw.(map[string]interface{})
+	if !ok {
+		return errorf("filter", "must be an object, got %s", describe(raw))
+	}
+	if len(filterParams) == 0 {
+		return nil
+	}
+
+	encoded, err := s.CompileResolved(ctx, filterParams, resolvers)
+	if err != nil {
+		return err
+	}
+	params.Set("filter", encoded)
+	return nil
+}
+
+func (s *Schema) parseObject(path string, raw map[string]interface{}) (Expr, error) {
+	keys := make([]string, 0, len(raw))
+	for k := range raw {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	exprs := make([]Expr, 0, len(keys))
+	for _, key := range keys {
+		value := raw[key]
+		keyPath := path + "." + key
+
+		if strings.HasPrefix(key, "$") {
+			op := Operator(key)
+			if op != OpAnd && op != OpOr {
+				return nil, errorf(keyPath, "operator %s is not allowed here, expected a field name, $and or $or", key)
+			}
+			expr, err := s.parseLogical(keyPath, op, value)
+			if err != nil {
+				return nil, err
+			}
+			exprs = append(exprs, expr)
+			continue
+		}
+
+		field, ok := s.byName[key]
+		if !ok {
+			return nil, errorf(keyPath, "unknown field %q for %s, available fields: %s",
+				key, s.resource, strings.Join(s.FieldNames(), ", "))
+		}
+		conditions, err := parseConditions(keyPath, field, value)
+		if err != nil {
+			return nil, err
+		}
+		exprs = append(exprs, conditions...)
+	}
+
+	if len(exprs) == 1 {
+		return exprs[0], nil
+	}
+	return Logical{Op: OpAnd, Exprs: exprs}, nil
+}
+
+func (s *Schema) parseLogical(path string, op Operator, value interface{}) (Expr, error) {
+	items, ok := value.([]interface{})
+	if !ok {
+		return nil, errorf(path, "%s expects an array of filter objects, got %s", op, describe(value))
+	}
+
+	exprs := make([]Expr, 0, len(items))
+	for i, item := range items {
+		itemPath := fmt.Sprintf("%s[%d]", path, i)
+		obj, ok := item.(map[string]interface{})
+		if !ok {
+			return nil, errorf(itemPath, "expected a filter object, got %s", describe(item))
+		}
+		if len(obj) == 0 {
+			return nil, errorf(itemPath, "filter object must not be empty")
+		}
+		expr, err := s.parseObject(itemPath, obj)
+		if err != nil {
+			return nil, err
+		}
+		exprs = append(exprs, expr)
+	}
+	return Logical{Op: op, Exprs: exprs}, nil
+}
+
+func parseConditions(path string, field Field, value interface{}) ([]Expr, error) {
+	ops, ok := value.(map[string]interface{})
+	if !ok {
+		v, err := field.Kind.normalize(path, value)
+		if err != nil {
+			return nil, err
+		}
+		return []Expr{Condition{Field: field.Name, Op: OpEq, Value: v}}, nil
+	}
+
+	if len(ops) == 0 {
+		return nil, errorf(path, "expected at least one operator")
+	}
+
+	keys := make([]string, 0, len(ops))
+	for k := range ops {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	conditions := make([]Expr, 0, len(keys))
+	for _, key := range keys {
+		op := Operator(key)
+		opPath := path + "." + key
+		if !comparisonOperators[op] {
+			return nil, errorf(opPath, "unknown operator %q, expected one of $eq, $ne, $lt, $lte, $gt, $gte, $in, $nin", key)
+		}
+
+		var (
+			v   interface{}
+			err error
+		)
+		if op == OpIn || op == OpNin {
+			v, err = normalizeList(opPath, field.Kind, ops[key])
+		} else {
+			v, err = field.Kind.normalize(opPath, ops[key])
+		}
+		if err != nil {
+			return nil, err
+		}
+		conditions = append(conditions, Condition{Field: field.Name, Op: op, Value: v})
+	}
+	return conditions, nil
+}
+
+func normalizeList(path string, kind Kind, value interface{}) (interface{}, error) {
+	items, ok := value.([]interface{})
+	if !ok {
+		return nil, errorf(path, "expects an array of values, got %s", describe(value))
+	}
+	out := make([]interface{}, 0, len(items))
+	for i, item := range items {
+		v, err := kind.normalize(fmt.Sprintf("%s[%d]", path, i), item)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, v)
+	}
+	return out, nil
+}
+
+// resolve replaces the names in the conditions on Ref fields of e with IDs
+func (s *Schema) resolve(ctx context.Context, e Expr, resolvers Resolvers) (Expr, error) {
+	switch e := e.(type) {
+	case Logical:
+		exprs := make([]Expr, 0, len(e.Exprs))
+		for _, child := range e.Exprs {
+			resolved, err := s.resolve(ctx, child, resolvers)
+			if err != nil {
+				return nil, err
+			}
+			exprs = append(exprs, resolved)
+		}
+		return Logical{Op: e.Op, Exprs: exprs}, nil
+
+	case Condition:
+		if s.byName[e.Field].Kind != KindRef {
+			return e, nil
+		}
+		path := "filter." + e.Field
+		if items, ok := e.Value.([]interface{}); ok {
+			ids := make([]interface{}, 0, len(items))
+			for i, item := range items {
+				id, err := resolveName(ctx, fmt.Sprintf("%s.%s[%d]", path, e.Op, i), resolvers[e.Field], item)
+				if err != nil {
+					return nil, err
+				}
+				ids = append(ids, id)
+			}
+			e.Value = ids
+			return e, nil
+		}
+		id, err := resolveName(ctx, path, resolvers[e.Field], e.Value)
+		if err != nil {
+			return nil, err
+		}
+		e.Value = id
+		return e, nil
+	}
+	return e, nil
+}
+
+// resolveName returns the ID of the record named by value, or value if it is
+// already an ID
+func resolveName(ctx context.Context, path string, resolve Resolver, value interface{}) (interface{}, error) {
+	name, ok := value.(string)
+	if !ok {
+		return value, nil
+	}
+	if resolve == nil {
+		return nil, errorf(path, "expected a positive integer ID, got %q", name)
+	}
+	id, err := resolve(ctx, name)
+	if err != nil {
+		return nil, &Error{Path: path, Message: err.Error(), Err: err}
+	}
+	return id, nil
+}
+
+func describe(v interface{}) string {
+	switch v.(type) {
+	case nil:
+		return "null"
+	case string:
+		return "a string"
+	case float64, int, int64:
+		return "a number"
+	case bool:
+		return "a boolean"
+	case []interface{}:
+		return "an array"
+	case map[string]interface{}:
+		return "an object"
+	default:
+		return fmt.Sprintf("%T", v)
+	}
+}
@@ -0,0 +1,188 @@
+package filter
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+var testSchema = NewSchema("tickets",
+	ID("id"),
+	Ref("status", "status_id"),
+	Time("createdAt", "created_at"),
+	ID("customer", "customer_id"),
+	Text("subject"),
+	Number("score"),
+	Bool("spam"),
+	Any("source"),
+)
+
+// parse decodes a filter object the way tool arguments are decoded
+func parse(t *testing.T, s string) map[string]interface{} {
+	t.Helper()
+	var raw map[string]interface{}
+	if err := json.Unmarshal([]byte(s), &raw); err != nil {
+		t.Fatalf("%s: %v", s, err)
+	}
+	return raw
+}
+
+func TestCompile(t *testing.T) {
+	tests := []struct {
+		name   string
+		filter string
+		want   string
+	}{
+		{"equality", `{"subject": "Refund"}`, `{"subject":{"$eq":"Refund"}}`},
+		{"alias", `{"customer_id": 20}`, `{"customer":{"$eq":20}}`},
+		{"numeric string ID", `{"id": "7"}`, `{"id":{"$eq":7}}`},
+		{"number", `{"score": "1.5"}`, `{"score":{"$eq":1.5}}`},
+		{"bool", `{"spam": false}`, `{"spam":{"$eq":false}}`},
+		{"any", `{"source": "email"}`, `{"source":{"$eq":"email"}}`},
+		{"null", `{"subject": null}`, `{"subject":{"$eq":null}}`},
+		{"date", `{"created_at": {"$gte": "2024-01-01"}}`, `{"createdAt":{"$gte":"2024-01-01T00:00:00Z"}}`},
+		{"timestamp in UTC", `{"createdAt": {"$lt": "2024-01-01T02:00:00+02:00"}}`, `{"createdAt":{"$lt":"2024-01-01T00:00:00Z"}}`},
+		{"in", `{"id": {"$in": [1, "2"]}}`, `{"id":{"$in":[1,2]}}`},
+		{"nin", `{"subject": {"$nin": ["a", "b"]}}`, `{"subject":{"$nin":["a","b"]}}`},
+		{"fields merged", `{"customer_id": 1, "subject": "Refund"}`, `{"customer":{"$eq":1},"subject":{"$eq":"Refund"}}`},
+		{"operators on one field", `{"id": {"$gt": 1, "$lt": 9}}`, `{"$and":[{"id":{"$gt":1}},{"id":{"$lt":9}}]}`},
+		{"alias and name of one field", `{"customer": 1, "customer_id": 2}`, `{"$and":[{"customer":{"$eq":1}},{"customer":{"$eq":2}}]}`},
+		{"or", `{"$or": [{"customer_id": 1}, {"subject": "Refund"}]}`, `{"$or":[{"customer":{"$eq":1}},{"subject":{"$eq":"Refund"}}]}`},
+		{"and merged", `{"$and": [{"customer_id": 1}, {"subject": "Refund"}]}`, `{"customer":{"$eq":1},"subject":{"$eq":"Refund"}}`},
+		{"and with a nested or", `{"$and": [{"customer_id": 1}, {"$or": [{"id": 1}, {"id": 2}]}]}`,
+			`{"$and":[{"customer":{"$eq":1}},{"$or":[{"id":{"$eq":1}},{"id":{"$eq":2}}]}]}`},
+		{"empty or", `{"$or": []}`, `{"$or":[]}`},
+		{"status ID", `{"status_id": "3"}`, `{"status":{"$eq":3}}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := testSchema.Compile(parse(t, tt.filter))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("filter = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCompileErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		filter string
+		path   string
+		want   string
+	}{
+		{"unknown field", `{"bogus": 1}`, "filter.bogus", "unknown field"},
+		{"unknown operator", `{"id": {"$like": 1}}`, "filter.id.$like", "unknown operator"},
+		{"no operators", `{"id": {}}`, "filter.id", "at least one operator"},
+		{"comparison at top level", `{"$eq": 1}`, "filter.$eq", "not allowed here"},
+		{"or of an object", `{"$or": {}}`, "filter.$or", "expects an array"},
+		{"or of a value", `{"$or": [1]}`, "filter.$or[0]", "expected a filter object"},
+		{"or of an empty object", `{"$or": [{}]}`, "filter.$or[0]", "must not be empty"},
+		{"nested error", `{"$or": [{"customer_id": "x"}]}`, "filter.$or[0].customer_id", "positive integer ID"},
+		{"negative ID", `{"id": -1}`, "filter.id", "positive integer ID"},
+		{"fractional ID", `{"id": 1.5}`, "filter.id", "positive integer ID"},
+		{"text of a number", `{"subject": 1}`, "filter.subject", "expected a string"},
+		{"number of a word", `{"score": "high"}`, "filter.score", "expected a number"},
+		{"bool of a string", `{"spam": "yes"}`, "filter.spam", "expected a boolean"},
+		{"bad date", `{"created_at": "yesterday"}`, "filter.created_at", "RFC 3339"},
+		{"list value", `{"id": [1]}`, "filter.id", "expected a single value"},
+		{"in of a value", `{"id": {"$in": 1}}`, "filter.id.$in", "expects an array"},
+		{"in with a bad item", `{"id": {"$in": [1, "x"]}}`, "filter.id.$in[1]", "positive integer ID"},
+		{"empty name", `{"status": ""}`, "filter.status", "ID or a name"},
+		{"name without a resolver", `{"status": "Solved"}`, "filter.status", "positive integer ID"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := testSchema.Compile(parse(t, tt.filter))
+			var filterErr *Error
+			if !errors.As(err, &filterErr) {
+				t.Fatalf("error = %v, want a filter error", err)
+			}
+			if filterErr.Path != tt.path || !strings.Contains(filterErr.Message, tt.want) {
+				t.Errorf("error = %v, want %q at %s", err, tt.want, tt.path)
+			}
+		})
+	}
+}
+
+func TestCompileResolved(t *testing.T) {
+	errUnavailable := errors.New("unavailable")
+	statuses := map[string]int{"active": 1, "solved": 4}
+	resolvers := Resolvers{"status": func(ctx context.Context, name string) (int, error) {
+		if name == "Broken" {
+			return 0, errUnavailable
+		}
+		if id, ok := statuses[strings.ToLower(name)]; ok {
+			return id, nil
+		}
+		return 0, fmt.Errorf("no ticket status named %q", name)
+	}}
+
+	tests := []struct {
+		filter string
+		want   string
+	}{
+		{`{"status": "Solved"}`, `{"status":{"$eq":4}}`},
+		{`{"status_id": 2}`, `{"status":{"$eq":2}}`},
+		{`{"status": {"$nin": ["active", 9]}}`, `{"status":{"$nin":[1,9]}}`},
+		{`{"$or": [{"status": "Active"}, {"subject": "Solved"}]}`, `{"$or":[{"status":{"$eq":1}},{"subject":{"$eq":"Solved"}}]}`},
+	}
+	for _, tt := range tests {
+		got, err := testSchema.CompileResolved(context.Background(), parse(t, tt.filter), resolvers)
+		if err != nil {
+			t.Errorf("filter %s: %v", tt.filter, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("filter %s = %s, want %s", tt.filter, got, tt.want)
+		}
+	}
+
+	_, err := testSchema.CompileResolved(context.Background(), parse(t, `{"status": "Escalated"}`), resolvers)
+	var filterErr *Error
+	if !errors.As(err, &filterErr) || filterErr.Path != "filter.status" || !strings.Contains(err.Error(), "no ticket status named") {
+		t.Errorf("unknown name error = %v, want a filter error naming the status", err)
+	}
+	_, err = testSchema.CompileResolved(context.Background(), parse(t, `{"status": "Broken"}`), resolvers)
+	if !errors.Is(err, errUnavailable) {
+		t.Errorf("resolver failure = %v, want it wrapped", err)
+	}
+}
+
+func TestAddToParams(t *testing.T) {
+	tests := []struct {
+		name      string
+		arguments string
+		want      string
+		wantErr   bool
+	}{
+		{"no filter", `{}`, "", false},
+		{"null filter", `{"filter": null}`, "", false},
+		{"empty filter", `{"filter": {}}`, "", false},
+		{"filter", `{"filter": {"id": 1}}`, `{"id":{"$eq":1}}`, false},
+		{"not an object", `{"filter": "id=1"}`, "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var request mcp.CallToolRequest
+			request.Params.Arguments = parse(t, tt.arguments)
+			params := url.Values{}
+			err := testSchema.AddToParams(params, request)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("error = %v, want error %v", err, tt.wantErr)
+			}
+			if got := params.Get("filter"); got != tt.want {
+				t.Errorf("filter = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
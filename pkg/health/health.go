@@ -0,0 +1,130 @@
+// Package health serves the liveness and readiness probes of the HTTP
+// transports.
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/ready4god2513/deskmcp/pkg/desk"
+)
+
+const (
+	// checkTimeout bounds how long the check of a site may take
+	checkTimeout = 5 * time.Second
+	// checkInterval is how long the result of a check is reused, so that
+	// frequent probes do not use up the Desk rate limit
+	checkInterval = 15 * time.Second
+)
+
+// Report is the result of a readiness check
+type Report struct {
+	// Status is "ok" or "unavailable"
+	Status string                `json:"status"`
+	Sites  map[string]SiteReport `json:"sites"`
+}
+
+// SiteReport is the result of checking the credentials of a site
+type SiteReport struct {
+	OK        bool   `json:"ok"`
+	LatencyMS int64  `json:"latency_ms"`
+	Error     string `json:"error,omitempty"`
+}
+
+// Checker checks that the server can reach Desk with the credentials of
+// each site
+type Checker struct {
+	sites *desk.Sites
+
+	mu      sync.Mutex
+	checked time.Time
+	report  Report
+}
+
+// NewChecker returns a checker of the sites
+func NewChecker(sites *desk.Sites) *Checker {
+	return &Checker{sites: sites}
+}
+
+// Check lists a single customer of every site. The result is reused for a
+// while after a check. Without sites, e.g. when every session brings its own
+// credentials, there is nothing to check and the server is ready.
+func (c *Checker) Check(ctx context.Context) Report {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !c.checked.IsZero() && time.Since(c.checked) < checkInterval {
+		return c.report
+	}
+
+	report := Report{Status: "ok", Sites: make(map[string]SiteReport)}
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for _, name := range c.sites.Names() {
+		client, err := c.sites.Client(name)
+		if err != nil {
+			continue
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			site := checkSite(ctx, client)
+			mu.Lock()
+			defer mu.Unlock()
+			report.Sites[name] = site
+			if !site.OK {
+				report.Status = "unavailable"
+			}
+		}()
+	}
+	wg.Wait()
+
+	c.checked = time.Now()
+	c.report = report
+	return report
+}
+
+func checkSite(ctx context.Context, client *desk.Client) SiteReport {
+	ctx, cancel := context.WithTimeout(ctx, checkTimeout)
+	defer cancel()
+
+	params := url.Values{}
+	params.Set("page", "1")
+	params.Set("pageSize", "1")
+	start := time.Now()
+	err := client.Do(ctx, http.MethodGet, "customers.json", params, nil, nil)
+	site := SiteReport{OK: err == nil, LatencyMS: time.Since(start).Milliseconds()}
+	if err != nil {
+		site.Error = err.Error()
+	}
+	return site
+}
+
+// ServeHTTP serves the readiness probe. It responds with 503 Service
+// Unavailable if the check of a site failed.
+func (c *Checker) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	report := c.Check(r.Context())
+	status := http.StatusOK
+	if report.Status != "ok" {
+		status = http.StatusServiceUnavailable
+	}
+	writeJSON(w, status, report)
+}
+
+// Liveness serves the liveness probe, which succeeds while the server
+// handles requests
+func Liveness() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+	})
+}
+
+func writeJSON(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(body)
+}
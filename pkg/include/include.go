@@ -0,0 +1,176 @@
+// Package include resolves the related records that the Desk API sideloads
+// into the "included" section of its responses.
+package include
+
+import (
+	"strings"
+
+	"github.com/ready4god2513/desksdkgo/models"
+)
+
+// Priority is a ticket priority. The SDK has no model for priorities.
+type Priority struct {
+	ID   int    `json:"id"`
+	Name string `json:"name"`
+}
+
+// Data is the included section of a response. It extends the SDK's included
+// data with the collections the SDK does not know about.
+type Data struct {
+	models.IncludedData
+	Ticketpriorities []Priority `json:"ticketpriorities"`
+}
+
+// Ref is a related record resolved to a readable name
+type Ref struct {
+	ID    int    `json:"id,omitempty"`
+	Name  string `json:"name,omitempty"`
+	Email string `json:"email,omitempty"`
+}
+
+// Index looks up included records by ID. It is built once per response so
+// that resolving the relations of many records does not rescan the included
+// collections.
+type Index struct {
+	companies  map[int]models.Company
+	customers  map[int]models.Customer
+	inboxes    map[int]models.Inbox
+	priorities map[int]Priority
+	sources    map[int]models.TicketSource
+	statuses   map[int]models.TicketStatus
+	tags       map[int]models.Tag
+	types      map[int]models.TicketType
+	users      map[int]models.User
+}
+
+// New indexes the included records of a response
+func New(data Data) *Index {
+	return &Index{
+		companies:  byID(data.Companies, func(c models.Company) int { return c.ID }),
+		customers:  byID(data.Customers, func(c models.Customer) int { return c.ID }),
+		inboxes:    byID(data.Inboxes, func(i models.Inbox) int { return i.ID }),
+		priorities: byID(data.Ticketpriorities, func(p Priority) int { return p.ID }),
+		sources:    byID(data.Ticketsources, func(s models.TicketSource) int { return s.ID }),
+		statuses:   byID(data.Ticketstatuses, func(s models.TicketStatus) int { return s.ID }),
+		tags:       byID(data.Tags, func(t models.Tag) int { return t.ID }),
+		types:      byID(data.Tickettypes, func(t models.TicketType) int { return t.ID }),
+		users:      byID(data.Users, func(u models.User) int { return u.ID }),
+	}
+}
+
+func byID[T any](items []T, id func(T) int) map[int]T {
+	m := make(map[int]T, len(items))
+	for _, item := range items {
+		m[id(item)] = item
+	}
+	return m
+}
+
+// Company resolves a company. It returns nil for an empty reference.
+func (ix *Index) Company(id int) *Ref {
+	if id == 0 {
+		return nil
+	}
+	ref := &Ref{ID: id}
+	if c, ok := ix.companies[id]; ok {
+		ref.Name = c.Name
+	}
+	return ref
+}
+
+// Customer resolves a customer. It returns nil for an empty reference.
+func (ix *Index) Customer(id int) *Ref {
+	if id == 0 {
+		return nil
+	}
+	ref := &Ref{ID: id}
+	if c, ok := ix.customers[id]; ok {
+		ref.Name = fullName(c.FirstName, c.LastName)
+		ref.Email = c.Email
+	}
+	return ref
+}
+
+// CustomerOrganization returns the organization of an included customer
+func (ix *Index) CustomerOrganization(id int) string {
+	return ix.customers[id].Organization
+}
+
+// User resolves an agent. It returns nil for an empty reference.
+func (ix *Index) User(id int) *Ref {
+	if id == 0 {
+		return nil
+	}
+	ref := &Ref{ID: id}
+	if u, ok := ix.users[id]; ok {
+		ref.Name = fullName(u.FirstName, u.LastName)
+		ref.Email = u.Email
+	}
+	return ref
+}
+
+// Inbox resolves an inbox. It returns nil for an empty reference.
+func (ix *Index) Inbox(id int) *Ref {
+	if id == 0 {
+		return nil
+	}
+	ref := &Ref{ID: id}
+	if i, ok := ix.inboxes[id]; ok {
+		ref.Name = i.Name
+		ref.Email = i.Email
+	}
+	return ref
+}
+
+// Priority resolves a ticket priority. It returns nil for an empty reference.
+func (ix *Index) Priority(id int) *Ref {
+	if id == 0 {
+		return nil
+	}
+	return &Ref{ID: id, Name: ix.priorities[id].Name}
+}
+
+// Source resolves a ticket source. It returns nil for an empty reference.
+func (ix *Index) Source(id int) *Ref {
+	if id == 0 {
+		return nil
+	}
+	return &Ref{ID: id, Name: ix.sources[id].Name}
+}
+
+// Status resolves a ticket status. It returns nil for an empty reference.
+func (ix *Index) Status(id int) *Ref {
+	if id == 0 {
+		return nil
+	}
+	return &Ref{ID: id, Name: ix.statuses[id].Name}
+}
+
+// Tag resolves a tag. It returns nil for an empty reference.
+func (ix *Index) Tag(id int) *Ref {
+	if id == 0 {
+		return nil
+	}
+	return &Ref{ID: id, Name: ix.tags[id].Name}
+}
+
+// Type resolves a ticket type. It returns nil for an empty reference.
+func (ix *Index) Type(id int) *Ref {
+	if id == 0 {
+		return nil
+	}
+	return &Ref{ID: id, Name: ix.types[id].Name}
+}
+
+// Author resolves the creator of a record, which is either a customer or an
+// agent
+func (ix *Index) Author(ref models.UserRef) *Ref {
+	if ref.Type == "customers" {
+		return ix.Customer(ref.ID)
+	}
+	return ix.User(ref.ID)
+}
+
+func fullName(first, last string) string {
+	return strings.TrimSpace(first + " " + last)
+}
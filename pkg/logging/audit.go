@@ -0,0 +1,83 @@
+package logging
+
+import (
+	"encoding/json"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/ready4god2513/deskmcp/pkg/desk"
+)
+
+// AuditEntry records a call of a tool that can modify data
+type AuditEntry struct {
+	Time      time.Time              `json:"time"`
+	Tool      string                 `json:"tool"`
+	Site      string                 `json:"site,omitempty"`
+	Session   string                 `json:"session,omitempty"`
+	Arguments map[string]interface{} `json:"arguments"`
+	// Outcome is "ok" or "error"
+	Outcome    string     `json:"outcome"`
+	ErrorCode  string     `json:"error_code,omitempty"`
+	Error      string     `json:"error,omitempty"`
+	DurationMS int64      `json:"duration_ms"`
+	DeskCalls  []DeskCall `json:"desk_calls"`
+}
+
+// DeskCall is a Desk request made by a tool call
+type DeskCall struct {
+	Method     string `json:"method"`
+	Endpoint   string `json:"endpoint"`
+	Status     int    `json:"status"`
+	DurationMS int64  `json:"duration_ms"`
+}
+
+func deskCalls(calls []desk.Call) []DeskCall {
+	out := make([]DeskCall, 0, len(calls))
+	for _, c := range calls {
+		out = append(out, DeskCall{
+			Method:     c.Method,
+			Endpoint:   c.Endpoint,
+			Status:     c.Status,
+			DurationMS: c.Duration.Milliseconds(),
+		})
+	}
+	return out
+}
+
+// AuditLog is an append-only file of AuditEntry lines
+type AuditLog struct {
+	mu sync.Mutex
+	f  *os.File
+}
+
+// OpenAuditLog opens the audit log at path, creating it if needed. Entries
+// are appended to the existing ones.
+func OpenAuditLog(path string) (*AuditLog, error) {
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return nil, err
+	}
+	return &AuditLog{f: f}, nil
+}
+
+// Write appends an entry and syncs it to disk
+func (a *AuditLog) Write(entry AuditEntry) error {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	data = append(data, '\n')
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if _, err := a.f.Write(data); err != nil {
+		return err
+	}
+	return a.f.Sync()
+}
+
+// Close closes the audit log
+func (a *AuditLog) Close() error {
+	return a.f.Close()
+}
@@ -0,0 +1,142 @@
+package logging
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"regexp"
+	"time"
+	"unicode/utf8"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"github.com/ready4god2513/deskmcp/pkg/desk"
+	"github.com/ready4god2513/deskmcp/pkg/toolfilter"
+	"github.com/ready4god2513/deskmcp/pkg/utils"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// maxLoggedArgument bounds the length of the string arguments written to
+// the server log, e.g. the bodies of replies
+const maxLoggedArgument = 200
+
+// secretArgument matches the names of arguments whose values are never logged
+var secretArgument = regexp.MustCompile(`(?i)token|secret|password|api_?key`)
+
+// ToolCalls returns a middleware that logs every tool call with its
+// arguments, duration, the Desk requests it made and its outcome. The calls
+// of tools that can modify data are also written to audit, unless it is nil.
+func ToolCalls(logger *slog.Logger, audit *AuditLog) server.ToolHandlerMiddleware {
+	return func(next server.ToolHandlerFunc) server.ToolHandlerFunc {
+		return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+			ctx = desk.RecordCalls(ctx)
+			start := time.Now()
+			result, err := next(ctx, request)
+			duration := time.Since(start)
+
+			name := request.Params.Name
+			calls := desk.Calls(ctx)
+			code, message := utils.ResultError(result, err)
+			outcome := "ok"
+			if code != "" {
+				outcome = "error"
+			}
+			site, _ := request.Params.Arguments["site"].(string)
+			var session string
+			if cs := server.ClientSessionFromContext(ctx); cs != nil {
+				session = cs.SessionID()
+			}
+
+			level := slog.LevelInfo
+			if outcome != "ok" {
+				level = slog.LevelWarn
+			}
+			attrs := []slog.Attr{
+				slog.String("tool", name),
+				slog.Any("arguments", sanitize(request.Params.Arguments, maxLoggedArgument)),
+				slog.Duration("duration", duration),
+				slog.Any("desk_calls", summarize(calls)),
+				slog.String("outcome", outcome),
+			}
+			if site != "" {
+				attrs = append(attrs, slog.String("site", site))
+			}
+			if session != "" {
+				attrs = append(attrs, slog.String("session", session))
+			}
+			if code != "" {
+				attrs = append(attrs, slog.String("error_code", code), slog.String("error", message))
+			}
+			if sc := trace.SpanContextFromContext(ctx); sc.IsValid() {
+				attrs = append(attrs, slog.String("trace_id", sc.TraceID().String()))
+			}
+			logger.LogAttrs(ctx, level, "tool call", attrs...)
+
+			if audit != nil && !toolfilter.IsReadOnly(name) {
+				entry := AuditEntry{
+					Time:       start.UTC(),
+					Tool:       name,
+					Site:       site,
+					Session:    session,
+					Arguments:  sanitize(request.Params.Arguments, 0),
+					Outcome:    outcome,
+					ErrorCode:  code,
+					Error:      message,
+					DurationMS: duration.Milliseconds(),
+					DeskCalls:  deskCalls(calls),
+				}
+				if err := audit.Write(entry); err != nil {
+					logger.Error("failed to write the audit log", slog.String("tool", name), slog.Any("error", err))
+				}
+			}
+			return result, err
+		}
+	}
+}
+
+// sanitize copies the arguments of a tool call without secrets, including
+// those of nested objects. String arguments longer than maxLen bytes are
+// shortened unless maxLen is 0.
+func sanitize(args map[string]interface{}, maxLen int) map[string]interface{} {
+	out := make(map[string]interface{}, len(args))
+	for key, value := range args {
+		if secretArgument.MatchString(key) {
+			out[key] = "[redacted]"
+			continue
+		}
+		out[key] = sanitizeValue(value, maxLen)
+	}
+	return out
+}
+
+func sanitizeValue(value interface{}, maxLen int) interface{} {
+	switch v := value.(type) {
+	case string:
+		if maxLen > 0 && len(v) > maxLen {
+			// Cut on a rune boundary so that the log stays valid UTF-8
+			cut := maxLen
+			for cut > 0 && !utf8.RuneStart(v[cut]) {
+				cut--
+			}
+			return fmt.Sprintf("%s... (%d bytes)", v[:cut], len(v))
+		}
+	case map[string]interface{}:
+		return sanitize(v, maxLen)
+	case []interface{}:
+		out := make([]interface{}, len(v))
+		for i, e := range v {
+			out[i] = sanitizeValue(e, maxLen)
+		}
+		return out
+	}
+	return value
+}
+
+// summarize renders Desk requests compactly, e.g. "GET tickets/{id}.json 200 85ms"
+func summarize(calls []desk.Call) []string {
+	out := make([]string, 0, len(calls))
+	for _, c := range calls {
+		out = append(out, fmt.Sprintf("%s %s %d %s", c.Method, c.Endpoint, c.Status, c.Duration.Round(time.Millisecond)))
+	}
+	return out
+}
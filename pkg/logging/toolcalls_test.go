@@ -0,0 +1,62 @@
+package logging
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestSanitize(t *testing.T) {
+	args := map[string]interface{}{
+		"id":        "42",
+		"api_token": "t0ken",
+		"options": map[string]interface{}{
+			"password": "hunter2",
+			"nested":   []interface{}{map[string]interface{}{"secret": "s3cret", "name": "kept"}},
+		},
+		"count": float64(3),
+	}
+	want := map[string]interface{}{
+		"id":        "42",
+		"api_token": "[redacted]",
+		"options": map[string]interface{}{
+			"password": "[redacted]",
+			"nested":   []interface{}{map[string]interface{}{"secret": "[redacted]", "name": "kept"}},
+		},
+		"count": float64(3),
+	}
+
+	// Secrets of nested objects are redacted whether or not strings are
+	// shortened, as for the audit log
+	for _, maxLen := range []int{0, 200} {
+		if got := sanitize(args, maxLen); !reflect.DeepEqual(got, want) {
+			t.Errorf("sanitize(maxLen %d) = %v, want %v", maxLen, got, want)
+		}
+	}
+	if args["api_token"] != "t0ken" {
+		t.Error("sanitize modified the arguments")
+	}
+}
+
+func TestSanitizeShortens(t *testing.T) {
+	long := strings.Repeat("a", 9) + "é" + strings.Repeat("b", 10)
+	args := map[string]interface{}{"body": long, "tags": []interface{}{long}}
+
+	got := sanitize(args, 10)
+	// The cut falls inside the two bytes of é, which is left out
+	want := "aaaaaaaaa... (21 bytes)"
+	if got["body"] != want {
+		t.Errorf("body = %q, want %q", got["body"], want)
+	}
+	if tags := got["tags"].([]interface{}); tags[0] != want {
+		t.Errorf("tag = %q, want %q", tags[0], want)
+	}
+	if !utf8.ValidString(got["body"].(string)) {
+		t.Error("shortened body is not valid UTF-8")
+	}
+
+	if got := sanitize(args, 0); got["body"] != long {
+		t.Errorf("body = %q, want it unshortened without a maximum length", got["body"])
+	}
+}
@@ -0,0 +1,147 @@
+// Package metrics exports Prometheus metrics of the tool calls the server
+// handles and the Desk API requests it makes.
+package metrics
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/ready4god2513/deskmcp/pkg/desk"
+	"github.com/ready4god2513/deskmcp/pkg/utils"
+)
+
+const namespace = "deskmcp"
+
+// Metrics collects the metrics of the server
+type Metrics struct {
+	registry *prometheus.Registry
+
+	toolCalls    *prometheus.CounterVec
+	toolDuration *prometheus.HistogramVec
+	deskRequests *prometheus.CounterVec
+	deskDuration *prometheus.HistogramVec
+}
+
+// New returns the metrics of a server, including the Go runtime and process
+// metrics
+func New() *Metrics {
+	m := &Metrics{
+		registry: prometheus.NewRegistry(),
+		toolCalls: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "tool_calls_total",
+			Help:      "Tool calls by tool and error class. The error class is empty for calls that succeeded.",
+		}, []string{"tool", "error"}),
+		toolDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "tool_call_duration_seconds",
+			Help:      "Duration of tool calls by tool.",
+			Buckets:   []float64{.01, .05, .1, .25, .5, 1, 2.5, 5, 10, 30},
+		}, []string{"tool"}),
+		deskRequests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "desk_requests_total",
+			Help:      "Desk API requests by method, endpoint and status code. The status is 0 for requests that got no response.",
+		}, []string{"method", "endpoint", "status"}),
+		deskDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "desk_request_duration_seconds",
+			Help:      "Duration of Desk API requests by method and endpoint, including retries.",
+			Buckets:   []float64{.05, .1, .25, .5, 1, 2.5, 5, 10, 30},
+		}, []string{"method", "endpoint"}),
+	}
+	m.registry.MustRegister(
+		m.toolCalls, m.toolDuration, m.deskRequests, m.deskDuration,
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+	)
+	return m
+}
+
+// Handler serves the metrics in the Prometheus exposition format
+func (m *Metrics) Handler() http.Handler {
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{Registry: m.registry})
+}
+
+// ToolCalls returns a middleware that counts and times tool calls
+func (m *Metrics) ToolCalls() server.ToolHandlerMiddleware {
+	return func(next server.ToolHandlerFunc) server.ToolHandlerFunc {
+		return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+			start := time.Now()
+			result, err := next(ctx, request)
+
+			name := request.Params.Name
+			code, _ := utils.ResultError(result, err)
+			m.toolCalls.WithLabelValues(name, code).Inc()
+			m.toolDuration.WithLabelValues(name).Observe(time.Since(start).Seconds())
+			return result, err
+		}
+	}
+}
+
+// ObserveDeskCall records a Desk API request. Pass it to desk.WithCallObserver.
+func (m *Metrics) ObserveDeskCall(call desk.Call) {
+	m.deskRequests.WithLabelValues(call.Method, call.Endpoint, strconv.Itoa(call.Status)).Inc()
+	m.deskDuration.WithLabelValues(call.Method, call.Endpoint).Observe(call.Duration.Seconds())
+}
+
+// WatchSites exports the rate limit headroom of the clients of sites
+func (m *Metrics) WatchSites(sites *desk.Sites) {
+	m.registry.MustRegister(&rateLimitCollector{sites: sites})
+}
+
+var (
+	rateLimitDesc = prometheus.NewDesc(namespace+"_desk_rate_limit",
+		"Requests per period Desk allows the client of a site, as last reported by Desk.", []string{"site"}, nil)
+	rateLimitRemainingDesc = prometheus.NewDesc(namespace+"_desk_rate_limit_remaining",
+		"Requests the client of a site may still send in the current period, as last reported by Desk.", []string{"site"}, nil)
+	rateLimitResetDesc = prometheus.NewDesc(namespace+"_desk_rate_limit_reset_timestamp_seconds",
+		"When the rate limit of the client of a site resets, as last reported by Desk.", []string{"site"}, nil)
+	retriesDesc = prometheus.NewDesc(namespace+"_desk_retries_total",
+		"Desk API requests retried by the client of a site.", []string{"site"}, nil)
+	rateLimitedDesc = prometheus.NewDesc(namespace+"_desk_rate_limited_total",
+		"Desk API responses with status 429 received by the client of a site.", []string{"site"}, nil)
+)
+
+// rateLimitCollector reads the rate limit status of the sites on scrape
+type rateLimitCollector struct {
+	sites *desk.Sites
+}
+
+// Describe implements prometheus.Collector
+func (c *rateLimitCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- rateLimitDesc
+	ch <- rateLimitRemainingDesc
+	ch <- rateLimitResetDesc
+	ch <- retriesDesc
+	ch <- rateLimitedDesc
+}
+
+// Collect implements prometheus.Collector
+func (c *rateLimitCollector) Collect(ch chan<- prometheus.Metric) {
+	for _, name := range c.sites.Names() {
+		client, err := c.sites.Client(name)
+		if err != nil {
+			continue
+		}
+		status := client.RateLimit()
+		if status.Limit != nil {
+			ch <- prometheus.MustNewConstMetric(rateLimitDesc, prometheus.GaugeValue, float64(*status.Limit), name)
+		}
+		if status.Remaining != nil {
+			ch <- prometheus.MustNewConstMetric(rateLimitRemainingDesc, prometheus.GaugeValue, float64(*status.Remaining), name)
+		}
+		if status.ResetAt != nil {
+			ch <- prometheus.MustNewConstMetric(rateLimitResetDesc, prometheus.GaugeValue, float64(status.ResetAt.Unix()), name)
+		}
+		ch <- prometheus.MustNewConstMetric(retriesDesc, prometheus.CounterValue, float64(status.Retries), name)
+		ch <- prometheus.MustNewConstMetric(rateLimitedDesc, prometheus.CounterValue, float64(status.RateLimited), name)
+	}
+}
@@ -0,0 +1,347 @@
+// Package prompts provides MCP prompts for common support workflows. Each
+// prompt fetches the records it is about, so the model starts from the current
+// state of the helpdesk instead of asking for it.
+package prompts
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"github.com/ready4god2513/deskmcp/pkg/customers"
+	"github.com/ready4god2513/deskmcp/pkg/desk"
+	"github.com/ready4god2513/deskmcp/pkg/include"
+	"github.com/ready4god2513/deskmcp/pkg/tags"
+	"github.com/ready4god2513/deskmcp/pkg/tickets"
+	"github.com/ready4god2513/deskmcp/pkg/ticketstatuses"
+	"github.com/ready4god2513/deskmcp/pkg/tickettypes"
+)
+
+const (
+	defaultHistoryTickets = 20
+	maxHistoryTickets     = 100
+	defaultReviewDays     = 7
+	maxReviewTickets      = 500
+)
+
+type PromptHandler struct {
+	tickets        *tickets.TicketHandler
+	customers      *customers.CustomerHandler
+	tags           *tags.TagHandler
+	ticketStatuses *ticketstatuses.TicketStatusHandler
+	ticketTypes    *tickettypes.TicketTypeHandler
+}
+
+func NewPromptHandler(deskClient *desk.Client) *PromptHandler {
+	return &PromptHandler{
+		tickets:        tickets.NewTicketHandler(deskClient),
+		customers:      customers.NewCustomerHandler(deskClient),
+		tags:           tags.NewTagHandler(deskClient),
+		ticketStatuses: ticketstatuses.NewTicketStatusHandler(deskClient),
+		ticketTypes:    tickettypes.NewTicketTypeHandler(deskClient),
+	}
+}
+
+func (h *PromptHandler) RegisterPrompts(s *server.MCPServer) {
+	// Triage ticket
+	s.AddPrompt(mcp.NewPrompt("triage_ticket",
+		mcp.WithPromptDescription("Classify a ticket: summarize it and recommend its type, priority, status and tags"),
+		mcp.WithArgument("ticket_id",
+			mcp.RequiredArgument(),
+			mcp.ArgumentDescription("ID of the ticket to triage"),
+		),
+	), h.triageTicket)
+
+	// Draft reply
+	s.AddPrompt(mcp.NewPrompt("draft_reply",
+		mcp.WithPromptDescription("Draft a reply to the customer on a ticket, grounded in the ticket's thread"),
+		mcp.WithArgument("ticket_id",
+			mcp.RequiredArgument(),
+			mcp.ArgumentDescription("ID of the ticket to reply to"),
+		),
+		mcp.WithArgument("tone",
+			mcp.ArgumentDescription("Tone of the reply, e.g. \"friendly\" or \"formal\". Defaults to friendly and professional."),
+		),
+		mcp.WithArgument("instructions",
+			mcp.ArgumentDescription("Anything the reply must say or avoid"),
+		),
+	), h.draftReply)
+
+	// Summarize customer history
+	s.AddPrompt(mcp.NewPrompt("summarize_customer_history",
+		mcp.WithPromptDescription("Summarize a customer's history with support from their recent tickets"),
+		mcp.WithArgument("customer_id",
+			mcp.RequiredArgument(),
+			mcp.ArgumentDescription("ID of the customer"),
+		),
+		mcp.WithArgument("max_tickets",
+			mcp.ArgumentDescription(fmt.Sprintf("Number of most recently updated tickets to include (default %d, at most %d)", defaultHistoryTickets, maxHistoryTickets)),
+		),
+	), h.summarizeCustomerHistory)
+
+	// Weekly queue review
+	s.AddPrompt(mcp.NewPrompt("weekly_queue_review",
+		mcp.WithPromptDescription("Review the ticket queue: volume, backlog, unassigned and aging tickets, recurring themes"),
+		mcp.WithArgument("days",
+			mcp.ArgumentDescription(fmt.Sprintf("Number of days to review (default %d)", defaultReviewDays)),
+		),
+		mcp.WithArgument("inbox_id",
+			mcp.ArgumentDescription("Only review the tickets of this inbox"),
+		),
+	), h.weeklyQueueReview)
+}
+
+func (h *PromptHandler) triageTicket(ctx context.Context, request mcp.GetPromptRequest) (*mcp.GetPromptResult, error) {
+	id, err := requiredID(request, "ticket_id")
+	if err != nil {
+		return nil, err
+	}
+	ticket, err := h.tickets.GetTicket(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get ticket %d: %w", id, err)
+	}
+	types, err := h.ticketTypes.ListAll(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list ticket types: %w", err)
+	}
+	statuses, err := h.ticketStatuses.ListAll(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list ticket statuses: %w", err)
+	}
+	priorities, err := h.tickets.ListPriorities(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list ticket priorities: %w", err)
+	}
+	allTags, err := h.tags.ListAll(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list tags: %w", err)
+	}
+
+	var b promptBuilder
+	b.line("Triage Teamwork Desk ticket #%d.", id)
+	b.line("")
+	b.line("Read the ticket and its thread below, then recommend:")
+	b.line("1. A one sentence summary of the customer's problem")
+	b.line("2. The ticket type, priority and status, chosen from the available values")
+	b.line("3. The tags that apply, chosen from the available tags")
+	b.line("4. Whether the ticket needs an urgent response, and why")
+	b.line("")
+	b.line("Only recommend values from the lists below and explain each recommendation in one sentence. Do not change the ticket until the recommendations have been confirmed.")
+	b.section("Ticket", ticket)
+	b.names("Available ticket types", len(types), func(i int) (int, string) { return types[i].ID, types[i].Name })
+	b.names("Available priorities", len(priorities), func(i int) (int, string) { return priorities[i].ID, priorities[i].Name })
+	b.names("Available statuses", len(statuses), func(i int) (int, string) { return statuses[i].ID, statuses[i].Name })
+	b.names("Available tags", len(allTags), func(i int) (int, string) { return allTags[i].ID, allTags[i].Name })
+	return b.result(fmt.Sprintf("Triage ticket #%d", id))
+}
+
+func (h *PromptHandler) draftReply(ctx context.Context, request mcp.GetPromptRequest) (*mcp.GetPromptResult, error) {
+	id, err := requiredID(request, "ticket_id")
+	if err != nil {
+		return nil, err
+	}
+	ticket, err := h.tickets.GetTicket(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get ticket %d: %w", id, err)
+	}
+
+	tone := strings.TrimSpace(request.Params.Arguments["tone"])
+	if tone == "" {
+		tone = "friendly and professional"
+	}
+
+	var b promptBuilder
+	b.line("Draft a reply to the customer on Teamwork Desk ticket #%d.", id)
+	b.line("")
+	b.line("- Answer the customer's most recent inbound message and anything in the thread that is still unanswered")
+	b.line("- Address the customer by name and write in a %s tone", tone)
+	b.line("- Use internal notes as background, but never quote or reveal them")
+	b.line("- Only state facts that are supported by the thread. List anything that needs to be checked before sending after the draft.")
+	if instructions := strings.TrimSpace(request.Params.Arguments["instructions"]); instructions != "" {
+		b.line("- %s", instructions)
+	}
+	b.line("")
+	b.line("Return only the draft and the list of things to check. Send it with reply_to_ticket only once it has been approved.")
+	b.section("Ticket", ticket)
+	return b.result(fmt.Sprintf("Draft a reply to ticket #%d", id))
+}
+
+func (h *PromptHandler) summarizeCustomerHistory(ctx context.Context, request mcp.GetPromptRequest) (*mcp.GetPromptResult, error) {
+	id, err := requiredID(request, "customer_id")
+	if err != nil {
+		return nil, err
+	}
+	maxTickets, err := intArgument(request, "max_tickets", defaultHistoryTickets)
+	if err != nil {
+		return nil, err
+	}
+	if maxTickets > maxHistoryTickets {
+		maxTickets = maxHistoryTickets
+	}
+
+	customer, err := h.customers.Get(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get customer %d: %w", id, err)
+	}
+	history, page, err := h.tickets.FindTickets(ctx, map[string]interface{}{"customer_id": float64(id)}, maxTickets)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list the tickets of customer %d: %w", id, err)
+	}
+
+	var b promptBuilder
+	b.line("Summarize the support history of Teamwork Desk customer #%d for an agent who is about to help them.", id)
+	b.line("")
+	b.line("Cover:")
+	b.line("1. Who the customer is and how long they have been contacting support")
+	b.line("2. Recurring problems and how they were resolved")
+	b.line("3. Tickets that are still open and what they are waiting on")
+	b.line("4. The customer's overall sentiment and anything an agent should be careful about")
+	b.line("")
+	b.line("Refer to tickets by their ID. The customer has %d tickets in total; the %d most recently updated are included below.", page.Records, len(history))
+	b.section("Customer", customer)
+	b.section("Tickets", history)
+	return b.result(fmt.Sprintf("Summarize the history of customer #%d", id))
+}
+
+func (h *PromptHandler) weeklyQueueReview(ctx context.Context, request mcp.GetPromptRequest) (*mcp.GetPromptResult, error) {
+	days, err := intArgument(request, "days", defaultReviewDays)
+	if err != nil {
+		return nil, err
+	}
+	inboxID, err := intArgument(request, "inbox_id", 0)
+	if err != nil {
+		return nil, err
+	}
+
+	since := time.Now().AddDate(0, 0, -days).Format("2006-01-02")
+	where := map[string]interface{}{"updated_at": map[string]interface{}{"$gte": since}}
+	if inboxID != 0 {
+		where["inbox_id"] = float64(inboxID)
+	}
+	queue, page, err := h.tickets.FindTickets(ctx, where, maxReviewTickets)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list tickets: %w", err)
+	}
+
+	var b promptBuilder
+	b.line("Review the Teamwork Desk ticket queue for the %d days since %s.", days, since)
+	b.line("")
+	b.line("Report on:")
+	b.line("1. Ticket volume and how it is split across statuses, priorities and types")
+	b.line("2. The backlog: tickets that are still open, oldest first")
+	b.line("3. Unassigned tickets and high priority tickets that need attention")
+	b.line("4. Recurring themes in the subjects and previews, and what could reduce them")
+	b.line("5. Three concrete recommendations for the coming week")
+	b.line("")
+	if page.HasMore {
+		b.line("%d tickets were updated in this period; the %d most recently updated are included below.", page.Records, len(queue))
+	} else {
+		b.line("%d tickets were updated in this period.", len(queue))
+	}
+	b.section("Totals", queueTotals(queue))
+	b.section("Tickets", queue)
+	return b.result(fmt.Sprintf("Review the ticket queue of the last %d days", days))
+}
+
+// queueTotals counts tickets by status, priority and type so the review does
+// not depend on the model counting correctly
+func queueTotals(queue []tickets.TicketView) map[string]interface{} {
+	byStatus := map[string]int{}
+	byPriority := map[string]int{}
+	byType := map[string]int{}
+	unassigned := 0
+	for _, t := range queue {
+		byStatus[refName(t.Status)]++
+		byPriority[refName(t.Priority)]++
+		byType[refName(t.Type)]++
+		if t.Agent == nil {
+			unassigned++
+		}
+	}
+	return map[string]interface{}{
+		"tickets":     len(queue),
+		"unassigned":  unassigned,
+		"by_status":   byStatus,
+		"by_priority": byPriority,
+		"by_type":     byType,
+	}
+}
+
+func refName(ref *include.Ref) string {
+	if ref == nil || ref.Name == "" {
+		return "none"
+	}
+	return ref.Name
+}
+
+// requiredID parses a required ID argument. Prompt arguments are always
+// strings and their presence is not checked by the server.
+func requiredID(request mcp.GetPromptRequest, name string) (int, error) {
+	if strings.TrimSpace(request.Params.Arguments[name]) == "" {
+		return 0, fmt.Errorf("%s is required", name)
+	}
+	return intArgument(request, name, 0)
+}
+
+// intArgument parses an optional positive integer argument, returning def
+// when it is not given
+func intArgument(request mcp.GetPromptRequest, name string, def int) (int, error) {
+	raw := strings.TrimSpace(request.Params.Arguments[name])
+	if raw == "" {
+		return def, nil
+	}
+	n, err := strconv.Atoi(raw)
+	if err != nil || n <= 0 {
+		return 0, fmt.Errorf("%s must be a positive integer, got %q", name, raw)
+	}
+	return n, nil
+}
+
+// promptBuilder assembles the text of a prompt with the records it refers
+// to as JSON sections
+type promptBuilder struct {
+	strings.Builder
+	err error
+}
+
+func (b *promptBuilder) line(format string, args ...interface{}) {
+	fmt.Fprintf(b, format+"\n", args...)
+}
+
+func (b *promptBuilder) section(title string, v interface{}) {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		b.err = fmt.Errorf("failed to marshal %s: %w", strings.ToLower(title), err)
+		return
+	}
+	fmt.Fprintf(b, "\n## %s\n\n```json\n%s\n```\n", title, data)
+}
+
+// names adds a section listing the names and IDs of reference records
+func (b *promptBuilder) names(title string, n int, item func(int) (int, string)) {
+	lines := make([]string, 0, n)
+	for i := 0; i < n; i++ {
+		id, name := item(i)
+		lines = append(lines, fmt.Sprintf("- %s (ID %d)", name, id))
+	}
+	sort.Strings(lines)
+	if len(lines) == 0 {
+		lines = append(lines, "- none")
+	}
+	fmt.Fprintf(b, "\n## %s\n\n%s\n", title, strings.Join(lines, "\n"))
+}
+
+func (b *promptBuilder) result(description string) (*mcp.GetPromptResult, error) {
+	if b.err != nil {
+		return nil, b.err
+	}
+	return mcp.NewGetPromptResult(description, []mcp.PromptMessage{
+		mcp.NewPromptMessage(mcp.RoleUser, mcp.NewTextContent(b.String())),
+	}), nil
+}
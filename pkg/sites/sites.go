@@ -0,0 +1,42 @@
+// Package sites exposes the Desk sites the server fronts, so that an agent
+// can direct tool calls to the site of a brand.
+package sites
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"github.com/ready4god2513/deskmcp/pkg/desk"
+	"github.com/ready4god2513/deskmcp/pkg/utils"
+)
+
+type SiteHandler struct {
+	sites *desk.Sites
+}
+
+func NewSiteHandler(sites *desk.Sites) *SiteHandler {
+	return &SiteHandler{
+		sites: sites,
+	}
+}
+
+// ListSitesTool is the name of the one tool without a site argument
+const ListSitesTool = "list_sites"
+
+func (h *SiteHandler) RegisterTools(s *server.MCPServer) {
+	// List sites
+	utils.AddTool(s, mcp.NewTool(ListSitesTool,
+		mcp.WithDescription("List the Desk sites this server fronts. Pass a site's name as the site argument of any other tool to run it against that site, e.g. to look up a customer on every brand's site. Tools called without site use the default site."),
+	), h.listSites)
+}
+
+func (h *SiteHandler) listSites(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	data, err := json.Marshal(h.sites.List())
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Failed to marshal sites: %v", err)), nil
+	}
+	return mcp.NewToolResultText(string(data)), nil
+}
@@ -6,14 +6,23 @@ import (
 	"fmt"
 	"net/url"
 	"strconv"
+	"strings"
 
 	"github.com/mark3labs/mcp-go/mcp"
 	"github.com/mark3labs/mcp-go/server"
 	"github.com/ready4god2513/deskmcp/pkg/desk"
+	"github.com/ready4god2513/deskmcp/pkg/filter"
 	"github.com/ready4god2513/deskmcp/pkg/utils"
 	"github.com/ready4god2513/desksdkgo/models"
 )
 
+// tagFilter lists the fields tags can be filtered by
+var tagFilter = filter.NewSchema("tags",
+	filter.Text("name"),
+	filter.Time("createdAt", "created_at"),
+	filter.Time("updatedAt", "updated_at"),
+)
+
 type TagHandler struct {
 	deskClient *desk.Client
 }
@@ -24,15 +33,23 @@ func NewTagHandler(deskClient *desk.Client) *TagHandler {
 	}
 }
 
+// client returns the Desk client to use for the request. Over HTTP each
+// session may supply its own credentials, otherwise the handler's client is used.
+func (h *TagHandler) client(ctx context.Context) *desk.Client {
+	return desk.ClientFromContext(ctx, h.deskClient)
+}
+
 func (h *TagHandler) RegisterTools(s *server.MCPServer) {
 	// List tags
-	s.AddTool(mcp.NewTool("list_tags",
+	utils.AddTool(s, mcp.NewTool("list_tags",
 		mcp.WithDescription("List all tags"),
 		mcp.WithObject("filter",
 			mcp.Description(`Optional filter for tags. Available fields:
 - name: Filter by tag name
 - created_at: Filter by creation date
-- updated_at: Filter by last update date`),
+- updated_at: Filter by last update date
+
+Supports the $eq, $ne, $lt, $lte, $gt, $gte, $in, $nin, $and and $or operators described on list_tickets.`),
 		),
 		mcp.WithString("orderBy",
 			mcp.Description("Order by field"),
@@ -51,10 +68,24 @@ func (h *TagHandler) RegisterTools(s *server.MCPServer) {
 			mcp.Min(1),
 			mcp.Max(100),
 		),
+		utils.WithListOptions(),
 	), h.listTags)
 
+	// Count tags
+	utils.AddTool(s, mcp.NewTool("count_tags",
+		mcp.WithDescription("Count all filtered tags"),
+		mcp.WithObject("filter",
+			mcp.Description(`Optional filter for tags. Available fields:
+- name: Filter by tag name
+- created_at: Filter by creation date
+- updated_at: Filter by last update date
+
+Supports the $eq, $ne, $lt, $lte, $gt, $gte, $in, $nin, $and and $or operators described on list_tickets.`),
+		),
+	), h.countTags)
+
 	// Get tag
-	s.AddTool(mcp.NewTool("get_tag",
+	utils.AddTool(s, mcp.NewTool("get_tag",
 		mcp.WithDescription("Get a specific tag by ID"),
 		mcp.WithString("id",
 			mcp.Required(),
@@ -63,44 +94,97 @@ func (h *TagHandler) RegisterTools(s *server.MCPServer) {
 	), h.getTag)
 
 	// Create tag
-	s.AddTool(mcp.NewTool("create_tag",
+	utils.AddTool(s, mcp.NewTool("create_tag",
 		mcp.WithDescription("Create a new tag"),
 		mcp.WithString("name",
 			mcp.Required(),
 			mcp.Description("Tag name"),
 		),
 	), h.createTag)
+
+	// Update tag
+	utils.AddTool(s, mcp.NewTool("update_tag",
+		mcp.WithDescription("Update an existing tag. Only the provided fields are changed."),
+		mcp.WithString("id",
+			mcp.Required(),
+			mcp.Description("Tag ID"),
+		),
+		mcp.WithString("name",
+			mcp.Description("Tag name"),
+		),
+		mcp.WithString("color",
+			mcp.Description("Tag color, e.g. #ff0000"),
+		),
+	), h.updateTag)
+
+	// Delete tag
+	utils.AddTool(s, mcp.NewTool("delete_tag",
+		mcp.WithDescription("Delete a tag. This cannot be undone."),
+		mcp.WithString("id",
+			mcp.Required(),
+			mcp.Description("Tag ID"),
+		),
+		mcp.WithBoolean("confirm",
+			mcp.Required(),
+			mcp.Description("Must be true to confirm the deletion"),
+		),
+	), h.deleteTag)
+}
+
+// RegisterResources registers the desk://tags resource listing every tag
+func (h *TagHandler) RegisterResources(s *server.MCPServer) {
+	s.AddResource(mcp.NewResource("desk://tags", "Tags",
+		mcp.WithResourceDescription("All tags"),
+		mcp.WithMIMEType("application/json"),
+	), h.readAll)
 }
 
 func (h *TagHandler) listTags(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
 	params := url.Values{}
-	if filterParams, ok := request.Params.Arguments["filter"].(map[string]interface{}); ok {
-		for field, value := range filterParams {
-			params.Add(field, fmt.Sprintf("%v", value))
-		}
+	if err := tagFilter.AddToParams(params, request); err != nil {
+		return utils.InvalidArgumentResult(ctx, "Failed to list tags", err), nil
 	}
 
-	utils.AddPaginationToParams(params, request)
+	if err := utils.AddPaginationToParams(params, request); err != nil {
+		return utils.ArgumentErrorResult(err), nil
+	}
 
-	resp, err := h.deskClient.Client.Tags.List(ctx, params)
+	result, err := utils.ListPages(ctx, request, "tags", params, h.listPage)
 	if err != nil {
-		return mcp.NewToolResultError(fmt.Sprintf("Failed to list tags: %v", err)), nil
+		return utils.ErrorResult(ctx, "Failed to list tags", err), nil
 	}
-	data, err := json.Marshal(resp.Tags)
+	data, err := json.Marshal(result)
 	if err != nil {
 		return mcp.NewToolResultError(fmt.Sprintf("Failed to marshal tags: %v", err)), nil
 	}
 	return mcp.NewToolResultText(string(data)), nil
 }
 
-func (h *TagHandler) getTag(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
-	id, err := strconv.Atoi(request.Params.Arguments["id"].(string))
+// Count tags
+func (h *TagHandler) countTags(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	params := url.Values{}
+	if err := tagFilter.AddToParams(params, request); err != nil {
+		return utils.InvalidArgumentResult(ctx, "Failed to count tags", err), nil
+	}
+
+	utils.AddCountParams(params)
+
+	resp, err := h.client(ctx).Client.Tags.List(ctx, params)
 	if err != nil {
-		return mcp.NewToolResultError(fmt.Sprintf("Invalid tag ID: %v", err)), nil
+		return utils.ErrorResult(ctx, "Failed to count tags", err), nil
 	}
-	resp, err := h.deskClient.Client.Tags.Get(ctx, id)
+
+	return mcp.NewToolResultText(strconv.Itoa(resp.Pagination.Records)), nil
+}
+
+func (h *TagHandler) getTag(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	var args utils.IDArguments
+	if err := utils.BindArguments(request, &args); err != nil {
+		return utils.ArgumentErrorResult(err), nil
+	}
+	resp, err := h.client(ctx).Client.Tags.Get(ctx, args.ID)
 	if err != nil {
-		return mcp.NewToolResultError(fmt.Sprintf("Failed to get tag: %v", err)), nil
+		return utils.ErrorResult(ctx, "Failed to get tag", err), nil
 	}
 	data, err := json.Marshal(resp.Tag)
 	if err != nil {
@@ -110,12 +194,18 @@ func (h *TagHandler) getTag(ctx context.Context, request mcp.CallToolRequest) (*
 }
 
 func (h *TagHandler) createTag(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	var args struct {
+		Name string `arg:"name"`
+	}
+	if err := utils.BindArguments(request, &args); err != nil {
+		return utils.ArgumentErrorResult(err), nil
+	}
 	tag := &models.Tag{
-		Name: request.Params.Arguments["name"].(string),
+		Name: args.Name,
 	}
-	resp, err := h.deskClient.Client.Tags.Create(ctx, tag)
+	resp, err := h.client(ctx).Client.Tags.Create(ctx, tag)
 	if err != nil {
-		return mcp.NewToolResultError(fmt.Sprintf("Failed to create tag: %v", err)), nil
+		return utils.ErrorResult(ctx, "Failed to create tag", err), nil
 	}
 	data, err := json.Marshal(resp.Tag)
 	if err != nil {
@@ -123,3 +213,78 @@ func (h *TagHandler) createTag(ctx context.Context, request mcp.CallToolRequest)
 	}
 	return mcp.NewToolResultText(string(data)), nil
 }
+
+func (h *TagHandler) updateTag(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	var args struct {
+		ID    int     `arg:"id"`
+		Name  *string `arg:"name" field:"name"`
+		Color *string `arg:"color" field:"color"`
+	}
+	if err := utils.BindArguments(request, &args); err != nil {
+		return utils.ArgumentErrorResult(err), nil
+	}
+	fields := utils.ChangedFields(&args)
+	if len(fields) == 0 {
+		return mcp.NewToolResultError("No fields to update were provided"), nil
+	}
+
+	var resp models.TagResponse
+	if err := h.client(ctx).Update(ctx, "tags", "tag", args.ID, fields, &resp); err != nil {
+		return utils.ErrorResult(ctx, "Failed to update tag", err), nil
+	}
+	data, err := json.Marshal(resp.Tag)
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Failed to marshal tag: %v", err)), nil
+	}
+	return mcp.NewToolResultText(string(data)), nil
+}
+
+func (h *TagHandler) deleteTag(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	var args utils.DeleteArguments
+	if err := utils.BindArguments(request, &args); err != nil {
+		return utils.ArgumentErrorResult(err), nil
+	}
+	if !args.Confirm {
+		return utils.InvalidArgumentResult(ctx, fmt.Sprintf("Not deleting tag %d", args.ID), &utils.ArgumentError{Argument: "confirm", Message: "must be true to delete it"}), nil
+	}
+	if err := h.client(ctx).Delete(ctx, "tags", args.ID); err != nil {
+		return utils.ErrorResult(ctx, "Failed to delete tag", err), nil
+	}
+	return mcp.NewToolResultText(fmt.Sprintf("Tag %d deleted", args.ID)), nil
+}
+
+// ResolveID returns the ID of the tag identified by nameOrID, which is
+// either a numeric ID or a tag name (matched case-insensitively)
+func (h *TagHandler) ResolveID(ctx context.Context, nameOrID string) (int, error) {
+	all, err := h.ListAll(ctx)
+	if err != nil {
+		return 0, err
+	}
+	if id, ok := utils.ResolveName(nameOrID, all, func(r models.Tag, name string) bool {
+		return strings.EqualFold(r.Name, name)
+	}, func(r models.Tag) int { return r.ID }); ok {
+		return id, nil
+	}
+	return 0, fmt.Errorf("no tag named %q", nameOrID)
+}
+
+// ListAll returns every tag
+func (h *TagHandler) ListAll(ctx context.Context) ([]models.Tag, error) {
+	return utils.FetchAll(ctx, url.Values{}, h.listPage)
+}
+
+func (h *TagHandler) listPage(ctx context.Context, params url.Values) ([]models.Tag, models.Pagination, error) {
+	resp, err := h.client(ctx).Client.Tags.List(ctx, params)
+	if err != nil {
+		return nil, models.Pagination{}, err
+	}
+	return resp.Tags, resp.Pagination, nil
+}
+
+func (h *TagHandler) readAll(ctx context.Context, request mcp.ReadResourceRequest) ([]mcp.ResourceContents, error) {
+	all, err := h.ListAll(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list tags: %w", err)
+	}
+	return utils.JSONResource(request.Params.URI, all)
+}
@@ -0,0 +1,189 @@
+package tags
+
+import (
+	"context"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/mark3labs/mcp-go/client"
+	"github.com/mark3labs/mcp-go/server"
+	"github.com/ready4god2513/deskmcp/pkg/desk"
+	"github.com/ready4god2513/deskmcp/pkg/desktest"
+	"github.com/ready4god2513/deskmcp/pkg/utils"
+	"github.com/ready4god2513/desksdkgo/models"
+)
+
+// setup starts a fake Desk site and returns it with an MCP client of a
+// server with the tag tools
+func setup(t *testing.T) (*desktest.Server, *client.Client) {
+	t.Helper()
+	return desktest.Setup(t, func(s *server.MCPServer, deskClient *desk.Client) {
+		NewTagHandler(deskClient).RegisterTools(s)
+	})
+}
+
+func addTags(api *desktest.Server) {
+	for _, name := range []string{"billing", "bug", "vip"} {
+		api.Add("tags", map[string]interface{}{"name": name, "color": "#888888"})
+	}
+}
+
+// names returns the names of tags in alphabetical order
+func names(tags []models.Tag) string {
+	s := make([]string, len(tags))
+	for i, tag := range tags {
+		s[i] = tag.Name
+	}
+	sort.Strings(s)
+	return strings.Join(s, ", ")
+}
+
+func TestListTags(t *testing.T) {
+	api, c := setup(t)
+	addTags(api)
+
+	tests := []struct {
+		name string
+		args map[string]interface{}
+		want string
+	}{
+		{"all", nil, "billing, bug, vip"},
+		{"name", map[string]interface{}{"filter": map[string]interface{}{"name": "VIP"}}, "vip"},
+		{"in", map[string]interface{}{"filter": map[string]interface{}{"name": map[string]interface{}{"$in": []interface{}{"bug", "billing"}}}}, "billing, bug"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var result utils.ListResult[models.Tag]
+			if err := desktest.DecodeResult(desktest.Call(t, c, "list_tags", tt.args), &result); err != nil {
+				t.Fatal(err)
+			}
+			if got := names(result.Items); got != tt.want {
+				t.Errorf("tags = %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	result := desktest.Call(t, c, "count_tags", nil)
+	if got := desktest.ResultText(result); result.IsError || got != "3" {
+		t.Errorf("count = %s, want 3", got)
+	}
+}
+
+func TestGetTag(t *testing.T) {
+	api, c := setup(t)
+	addTags(api)
+	id := api.Find("tags", "name", "bug")
+
+	var tag models.Tag
+	if err := desktest.DecodeResult(desktest.Call(t, c, "get_tag", map[string]interface{}{"id": strconv.Itoa(id)}), &tag); err != nil {
+		t.Fatal(err)
+	}
+	if tag.ID != id || tag.Name != "bug" {
+		t.Errorf("tag = %d %q, want %d bug", tag.ID, tag.Name, id)
+	}
+}
+
+func TestCreateTag(t *testing.T) {
+	api, c := setup(t)
+
+	var tag models.Tag
+	if err := desktest.DecodeResult(desktest.Call(t, c, "create_tag", map[string]interface{}{"name": "urgent"}), &tag); err != nil {
+		t.Fatal(err)
+	}
+	if tag.ID == 0 || tag.Name != "urgent" {
+		t.Errorf("tag = %+v, want the created tag", tag)
+	}
+
+	result := desktest.Call(t, c, "create_tag", map[string]interface{}{"name": "urgent"})
+	if code, message := utils.ResultError(result, nil); code != desk.ErrValidation {
+		t.Errorf("code of duplicate = %q, want %q: %s", code, desk.ErrValidation, message)
+	}
+	if n := len(api.Records("tags")); n != 1 {
+		t.Errorf("%d tags stored, want 1", n)
+	}
+}
+
+func TestUpdateTag(t *testing.T) {
+	api, c := setup(t)
+	addTags(api)
+	id := api.Find("tags", "name", "vip")
+
+	result := desktest.Call(t, c, "update_tag", map[string]interface{}{"id": strconv.Itoa(id), "color": "#ff0000"})
+	if result.IsError {
+		t.Fatal(desktest.ResultText(result))
+	}
+	stored, _ := api.Get("tags", id)
+	if stored["color"] != "#ff0000" || stored["name"] != "vip" {
+		t.Errorf("stored tag = %v, want the new color and the name unchanged", stored)
+	}
+}
+
+func TestDeleteTag(t *testing.T) {
+	api, c := setup(t)
+	addTags(api)
+	id := api.Find("tags", "name", "bug")
+
+	result := desktest.Call(t, c, "delete_tag", map[string]interface{}{"id": strconv.Itoa(id), "confirm": false})
+	if code, _ := utils.ResultError(result, nil); code != utils.ErrInvalidArguments {
+		t.Errorf("code without confirm = %q, want %q", code, utils.ErrInvalidArguments)
+	}
+	result = desktest.Call(t, c, "delete_tag", map[string]interface{}{"id": strconv.Itoa(id), "confirm": true})
+	if result.IsError {
+		t.Fatal(desktest.ResultText(result))
+	}
+	if _, ok := api.Get("tags", id); ok {
+		t.Error("tag was not deleted")
+	}
+}
+
+func TestResolveID(t *testing.T) {
+	api := desktest.NewServer()
+	defer api.Close()
+	addTags(api)
+	bug := api.Find("tags", "name", "bug")
+	h := NewTagHandler(api.Client())
+
+	for _, nameOrID := range []string{"bug", "BUG", strconv.Itoa(bug)} {
+		if id, err := h.ResolveID(context.Background(), nameOrID); err != nil || id != bug {
+			t.Errorf("ResolveID(%q) = %d, %v, want %d", nameOrID, id, err, bug)
+		}
+	}
+	if id, err := h.ResolveID(context.Background(), "feature"); err == nil {
+		t.Errorf("ResolveID of an unknown tag = %d, want an error", id)
+	}
+}
+
+func TestTagErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		tool   string
+		args   map[string]interface{}
+		method string
+		path   string
+		status int
+		want   string
+	}{
+		{"list server error", "list_tags", nil, http.MethodGet, "tags.json", http.StatusBadGateway, desk.ErrServer},
+		{"get missing", "get_tag", map[string]interface{}{"id": "999"}, "", "", 0, desk.ErrNotFound},
+		{"update missing", "update_tag", map[string]interface{}{"id": "999", "name": "x"}, "", "", 0, desk.ErrNotFound},
+		{"delete forbidden", "delete_tag", map[string]interface{}{"id": "1", "confirm": true}, http.MethodDelete, "tags", http.StatusForbidden, desk.ErrForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			api, c := setup(t)
+			addTags(api)
+			if tt.status != 0 {
+				api.Fail(tt.method, tt.path, tt.status, 1)
+			}
+
+			result := desktest.Call(t, c, tt.tool, tt.args)
+			code, message := utils.ResultError(result, nil)
+			if code != tt.want {
+				t.Errorf("code = %q, want %q: %s", code, tt.want, message)
+			}
+		})
+	}
+}
@@ -0,0 +1,163 @@
+package tickets
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"html"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/ready4god2513/deskmcp/pkg/include"
+	"github.com/ready4god2513/deskmcp/pkg/utils"
+	"github.com/ready4god2513/desksdkgo/models"
+)
+
+const (
+	threadTypeMessage = "message"
+	threadTypeNote    = "note"
+)
+
+// ThreadMessage is a message of a ticket thread as shown to agents
+type ThreadMessage struct {
+	ID        int          `json:"id"`
+	Type      string       `json:"type"`
+	Direction string       `json:"direction"`
+	Author    ThreadAuthor `json:"author"`
+	CreatedAt string       `json:"created_at"`
+	Body      string       `json:"body"`
+}
+
+// ThreadAuthor is the customer or agent who wrote a message
+type ThreadAuthor struct {
+	ID    int    `json:"id"`
+	Type  string `json:"type"`
+	Name  string `json:"name,omitempty"`
+	Email string `json:"email,omitempty"`
+}
+
+// messageRequest is the payload used to post a message to a ticket thread
+type messageRequest struct {
+	Message struct {
+		Body       string `json:"body"`
+		ThreadType string `json:"threadType"`
+	} `json:"message"`
+}
+
+type messageResponse struct {
+	Message  models.Message `json:"message"`
+	Included include.Data   `json:"included"`
+}
+
+var htmlTags = regexp.MustCompile(`<[^>]*>`)
+
+func (h *TicketHandler) listTicketMessages(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	var args utils.IDArguments
+	if err := utils.BindArguments(request, &args); err != nil {
+		return utils.ArgumentErrorResult(err), nil
+	}
+	resp, err := h.fetchTicket(ctx, args.ID)
+	if err != nil {
+		return utils.ErrorResult(ctx, "Failed to get ticket", err), nil
+	}
+	thread := ticketThread(args.ID, resp.Included.Messages, include.New(resp.Included))
+
+	data, err := json.Marshal(thread)
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Failed to marshal messages: %v", err)), nil
+	}
+	return mcp.NewToolResultText(string(data)), nil
+}
+
+func (h *TicketHandler) replyToTicket(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	return h.postMessage(ctx, request, threadTypeMessage)
+}
+
+func (h *TicketHandler) addInternalNote(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	return h.postMessage(ctx, request, threadTypeNote)
+}
+
+func (h *TicketHandler) postMessage(ctx context.Context, request mcp.CallToolRequest, threadType string) (*mcp.CallToolResult, error) {
+	var args struct {
+		ID   int    `arg:"id"`
+		Body string `arg:"body"`
+	}
+	if err := utils.BindArguments(request, &args); err != nil {
+		return utils.ArgumentErrorResult(err), nil
+	}
+	if strings.TrimSpace(args.Body) == "" {
+		return mcp.NewToolResultError("Message body must not be empty"), nil
+	}
+
+	var payload messageRequest
+	payload.Message.Body = args.Body
+	payload.Message.ThreadType = threadType
+
+	var resp messageResponse
+	path := fmt.Sprintf("tickets/%d/messages.json", args.ID)
+	if err := h.client(ctx).Do(ctx, http.MethodPost, path, nil, &payload, &resp); err != nil {
+		return utils.ErrorResult(ctx, "Failed to post "+threadType, err), nil
+	}
+
+	data, err := json.Marshal(formatMessage(resp.Message, include.New(resp.Included)))
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Failed to marshal message: %v", err)), nil
+	}
+	return mcp.NewToolResultText(string(data)), nil
+}
+
+// ticketThread returns the messages of a ticket in the order they were written
+func ticketThread(ticketID int, messages []models.Message, ix *include.Index) []ThreadMessage {
+	own := make([]models.Message, 0, len(messages))
+	for _, m := range messages {
+		if m.Ticket.ID == 0 || m.Ticket.ID == ticketID {
+			own = append(own, m)
+		}
+	}
+	// Timestamps are compared as times, since their text differs in offset
+	// and precision
+	sort.SliceStable(own, func(i, j int) bool {
+		return own[i].CreatedAt.Before(own[j].CreatedAt)
+	})
+
+	thread := make([]ThreadMessage, 0, len(own))
+	for _, m := range own {
+		thread = append(thread, formatMessage(m, ix))
+	}
+	return thread
+}
+
+// formatMessage renders a message with its author resolved from the included data
+func formatMessage(m models.Message, ix *include.Index) ThreadMessage {
+	author := ThreadAuthor{ID: m.CreatedBy.ID, Type: m.CreatedBy.Type}
+	if ref := ix.Author(m.CreatedBy); ref != nil {
+		author.Name = ref.Name
+		author.Email = ref.Email
+	}
+
+	direction := "outbound"
+	switch {
+	case m.ThreadType == threadTypeNote:
+		direction = "internal"
+	case m.CreatedBy.Type == "customers":
+		direction = "inbound"
+	}
+
+	body := m.TextBody
+	if body == "" {
+		body = strings.TrimSpace(html.UnescapeString(htmlTags.ReplaceAllString(m.HTMLBody, "")))
+	}
+
+	return ThreadMessage{
+		ID:        m.ID,
+		Type:      m.ThreadType,
+		Direction: direction,
+		Author:    author,
+		CreatedAt: m.CreatedAt.Format(time.RFC3339),
+		Body:      body,
+	}
+}
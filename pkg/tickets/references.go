@@ -0,0 +1,72 @@
+package tickets
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/ready4god2513/deskmcp/pkg/include"
+	"github.com/ready4god2513/deskmcp/pkg/utils"
+	"github.com/ready4god2513/desksdkgo/models"
+)
+
+type ticketPrioritiesResponse struct {
+	TicketPriorities []include.Priority `json:"ticketpriorities"`
+	Pagination       models.Pagination  `json:"pagination"`
+}
+
+// resolveInboxID returns the ID of the inbox identified by nameOrID, which is
+// either a numeric ID, an inbox name or an inbox email address
+func (h *TicketHandler) resolveInboxID(ctx context.Context, nameOrID string) (int, error) {
+	inboxes, err := h.listInboxes(ctx)
+	if err != nil {
+		return 0, err
+	}
+	if id, ok := utils.ResolveName(nameOrID, inboxes, func(inbox models.Inbox, name string) bool {
+		return strings.EqualFold(inbox.Name, name) || strings.EqualFold(inbox.Email, name)
+	}, func(inbox models.Inbox) int { return inbox.ID }); ok {
+		return id, nil
+	}
+	return 0, fmt.Errorf("no inbox named %q", nameOrID)
+}
+
+// listInboxes returns every inbox
+func (h *TicketHandler) listInboxes(ctx context.Context) ([]models.Inbox, error) {
+	return utils.FetchAll(ctx, url.Values{}, func(ctx context.Context, params url.Values) ([]models.Inbox, models.Pagination, error) {
+		var resp models.InboxesResponse
+		if err := h.client(ctx).Do(ctx, http.MethodGet, "inboxes.json", params, nil, &resp); err != nil {
+			return nil, models.Pagination{}, err
+		}
+		return resp.Inboxes, resp.Pagination, nil
+	})
+}
+
+// resolvePriorityID returns the ID of the ticket priority identified by
+// nameOrID, which is either a numeric ID or a priority name such as "high"
+func (h *TicketHandler) resolvePriorityID(ctx context.Context, nameOrID string) (int, error) {
+	priorities, err := h.ListPriorities(ctx)
+	if err != nil {
+		return 0, err
+	}
+	if id, ok := utils.ResolveName(nameOrID, priorities, func(p include.Priority, name string) bool {
+		return strings.EqualFold(p.Name, name)
+	}, func(p include.Priority) int { return p.ID }); ok {
+		return id, nil
+	}
+	return 0, fmt.Errorf("no ticket priority named %q", nameOrID)
+}
+
+// ListPriorities returns the ticket priorities
+func (h *TicketHandler) ListPriorities(ctx context.Context) ([]include.Priority, error) {
+	var resp ticketPrioritiesResponse
+	if err := h.client(ctx).Do(ctx, http.MethodGet, "ticketpriorities.json", nil, nil, &resp); err != nil {
+		return nil, err
+	}
+	return resp.TicketPriorities, nil
+}
+
+func entityRef(id int, kind string) map[string]interface{} {
+	return map[string]interface{}{"id": id, "type": kind}
+}
@@ -4,63 +4,106 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"net/http"
 	"net/url"
 	"strconv"
-	"time"
 
 	"github.com/mark3labs/mcp-go/mcp"
 	"github.com/mark3labs/mcp-go/server"
+	"github.com/ready4god2513/deskmcp/pkg/customers"
 	"github.com/ready4god2513/deskmcp/pkg/desk"
+	"github.com/ready4god2513/deskmcp/pkg/filter"
+	"github.com/ready4god2513/deskmcp/pkg/tags"
+	"github.com/ready4god2513/deskmcp/pkg/ticketstatuses"
+	"github.com/ready4god2513/deskmcp/pkg/tickettypes"
+	"github.com/ready4god2513/deskmcp/pkg/users"
 	"github.com/ready4god2513/deskmcp/pkg/utils"
 	"github.com/ready4god2513/desksdkgo/models"
 )
 
+// ticketFilter lists the fields tickets can be filtered by
+var ticketFilter = filter.NewSchema("tickets",
+	filter.ID("id"),
+	filter.Ref("status", "status_id"),
+	filter.Ref("priority", "priority_id"),
+	filter.Time("createdAt", "created_at"),
+	filter.Time("updatedAt", "updated_at"),
+	filter.ID("customer", "customer_id"),
+	filter.ID("company", "company_id"),
+	filter.ID("agent", "agent_id", "assigned_user_id"),
+	filter.ID("inbox", "inbox_id"),
+	filter.ID("type", "type_id"),
+	filter.Text("subject"),
+)
+
 type TicketHandler struct {
 	deskClient *desk.Client
+
+	// Handlers used to resolve names to IDs when creating tickets
+	customers      *customers.CustomerHandler
+	users          *users.UserHandler
+	tags           *tags.TagHandler
+	ticketTypes    *tickettypes.TicketTypeHandler
+	ticketStatuses *ticketstatuses.TicketStatusHandler
 }
 
 func NewTicketHandler(deskClient *desk.Client) *TicketHandler {
 	return &TicketHandler{
-		deskClient: deskClient,
+		deskClient:     deskClient,
+		customers:      customers.NewCustomerHandler(deskClient),
+		users:          users.NewUserHandler(deskClient),
+		tags:           tags.NewTagHandler(deskClient),
+		ticketTypes:    tickettypes.NewTicketTypeHandler(deskClient),
+		ticketStatuses: ticketstatuses.NewTicketStatusHandler(deskClient),
 	}
 }
 
+// client returns the Desk client to use for the request. Over HTTP each
+// session may supply its own credentials, otherwise the handler's client is used.
+func (h *TicketHandler) client(ctx context.Context) *desk.Client {
+	return desk.ClientFromContext(ctx, h.deskClient)
+}
+
 func (h *TicketHandler) RegisterTools(s *server.MCPServer) {
 	// List tickets
-	s.AddTool(mcp.NewTool("list_tickets",
+	utils.AddTool(s, mcp.NewTool("list_tickets",
 		mcp.WithDescription("List all tickets"),
 		mcp.WithObject("filter",
 			mcp.Description(`Optional filter for tickets. Available fields and syntax:
 
 Basic fields:
-- status: Filter by ticket status (e.g. "open", "closed", "pending")
-- priority: Filter by priority level
-- created_at: Filter by creation date
-- updated_at: Filter by last update date
+- id: Filter by ticket ID
+- status: Filter by ticket status ID or name (e.g. "Active", "Solved")
+- priority: Filter by priority ID or name (e.g. "High")
+- created_at: Filter by creation date (RFC 3339 timestamp or YYYY-MM-DD)
+- updated_at: Filter by last update date (RFC 3339 timestamp or YYYY-MM-DD)
 - customer_id: Filter by customer ID
 - company_id: Filter by company ID
 - assigned_user_id: Filter by assigned user ID
+- inbox_id: Filter by inbox ID
+- type_id: Filter by ticket type ID
+- subject: Filter by subject
 
 Filter syntax examples:
 1. Simple equality:
-   {"status": "open"}
-   {"priority": "high", "status": "closed"}
+   {"status": "Active"}
+   {"priority": "High", "status": "Solved"}
 
 2. Comparison operators:
-   {"age": {"$eq": 20}}  // equals
-   {"age": {"$ne": 20}}  // not equals
-   {"age": {"$lt": 20}}  // less than
-   {"age": {"$gt": 20}}  // greater than
-   {"age": {"$lte": 20}} // less than or equal
-   {"age": {"$gte": 20}} // greater than or equal
+   {"customer_id": {"$eq": 20}}             // equals
+   {"customer_id": {"$ne": 20}}             // not equals
+   {"created_at": {"$lt": "2024-01-01"}}    // less than
+   {"created_at": {"$gt": "2024-01-01"}}    // greater than
+   {"updated_at": {"$lte": "2024-01-01"}}   // less than or equal
+   {"updated_at": {"$gte": "2024-01-01"}}   // greater than or equal
 
 3. Set operations:
-   {"age": {"$in": [20, 1]}}    // in list
-   {"age": {"$nin": [20, 1]}}   // not in list
+   {"inbox_id": {"$in": [20, 1]}}    // in list
+   {"inbox_id": {"$nin": [20, 1]}}   // not in list
 
 4. Logical operators:
-   {"$or": [{"age": {"$gt": 20}}, {"name": "mike"}]}
-   {"$or": [{"age": {"$gt": 20}}, {"name": "mike"}, {"$and": [{"age": {"$gt": 12}}, {"age": {"$lte": 18}}]}]}
+   {"$or": [{"customer_id": 20}, {"status": "Active"}]}
+   {"$or": [{"customer_id": 20}, {"status": "Active"}, {"$and": [{"created_at": {"$gte": "2024-01-01"}}, {"created_at": {"$lt": "2024-02-01"}}]}]}
    {"$or": []}
 
 Operators can be combined to create complex queries.`),
@@ -82,25 +125,32 @@ Operators can be combined to create complex queries.`),
 			mcp.Min(1),
 			mcp.Max(100),
 		),
+		utils.WithListOptions(),
 	), h.listTickets)
 
 	// Count tickets
-	s.AddTool(mcp.NewTool("count_tickets",
+	utils.AddTool(s, mcp.NewTool("count_tickets",
 		mcp.WithDescription("Count all filtered tickets"),
 		mcp.WithObject("filter",
 			mcp.Description(`Optional filter for tickets. Available fields:
-- status: Filter by ticket status (e.g. "open", "closed", "pending")
-- priority: Filter by priority level
+- id: Filter by ticket ID
+- status: Filter by ticket status ID or name (e.g. "Active", "Solved")
+- priority: Filter by priority ID or name (e.g. "High")
 - created_at: Filter by creation date
 - updated_at: Filter by last update date
 - customer_id: Filter by customer ID
 - company_id: Filter by company ID
-- assigned_user_id: Filter by assigned user ID`),
+- assigned_user_id: Filter by assigned user ID
+- inbox_id: Filter by inbox ID
+- type_id: Filter by ticket type ID
+- subject: Filter by subject
+
+Supports the same operators as list_tickets.`),
 		),
 	), h.countTickets)
 
 	// Get ticket
-	s.AddTool(mcp.NewTool("get_ticket",
+	utils.AddTool(s, mcp.NewTool("get_ticket",
 		mcp.WithDescription("Get a specific ticket by ID"),
 		mcp.WithString("id",
 			mcp.Required(),
@@ -109,8 +159,8 @@ Operators can be combined to create complex queries.`),
 	), h.getTicket)
 
 	// Create ticket
-	s.AddTool(mcp.NewTool("create_ticket",
-		mcp.WithDescription("Create a new ticket"),
+	utils.AddTool(s, mcp.NewTool("create_ticket",
+		mcp.WithDescription("Create a new ticket. Related records can be given by ID or by name."),
 		mcp.WithString("subject",
 			mcp.Required(),
 			mcp.Description("Ticket subject"),
@@ -119,55 +169,160 @@ Operators can be combined to create complex queries.`),
 			mcp.Required(),
 			mcp.Description("Ticket preview text"),
 		),
+		mcp.WithString("customer_id",
+			mcp.Description("ID of the customer the ticket is for"),
+		),
+		mcp.WithString("customer_email",
+			mcp.Description("Email of the customer the ticket is for. The customer is created if it does not exist yet. Ignored when customer_id is set."),
+		),
+		mcp.WithString("customer_first_name",
+			mcp.Description("First name used when the customer has to be created"),
+		),
+		mcp.WithString("customer_last_name",
+			mcp.Description("Last name used when the customer has to be created"),
+		),
+		mcp.WithString("inbox",
+			mcp.Description("Inbox ID, name or email address"),
+		),
+		mcp.WithString("type",
+			mcp.Description("Ticket type ID or name"),
+		),
+		mcp.WithString("status",
+			mcp.Description("Ticket status ID or name"),
+		),
+		mcp.WithString("priority",
+			mcp.Description("Ticket priority ID or name (e.g. \"low\", \"high\")"),
+		),
+		mcp.WithArray("tags",
+			mcp.Description("Tag IDs or names"),
+			mcp.Items(map[string]interface{}{"type": "string"}),
+		),
+		mcp.WithString("agent",
+			mcp.Description("ID, email address or full name of the agent to assign"),
+		),
 	), h.createTicket)
+
+	// List ticket messages
+	utils.AddTool(s, mcp.NewTool("list_ticket_messages",
+		mcp.WithDescription("List the messages and internal notes of a ticket in chronological order"),
+		mcp.WithString("id",
+			mcp.Required(),
+			mcp.Description("Ticket ID"),
+		),
+	), h.listTicketMessages)
+
+	// Reply to ticket
+	utils.AddTool(s, mcp.NewTool("reply_to_ticket",
+		mcp.WithDescription("Send a reply to the customer on a ticket. The reply is visible to the customer."),
+		mcp.WithString("id",
+			mcp.Required(),
+			mcp.Description("Ticket ID"),
+		),
+		mcp.WithString("body",
+			mcp.Required(),
+			mcp.Description("Reply body"),
+		),
+	), h.replyToTicket)
+
+	// Add internal note
+	utils.AddTool(s, mcp.NewTool("add_internal_note",
+		mcp.WithDescription("Add an internal note to a ticket. Notes are only visible to agents."),
+		mcp.WithString("id",
+			mcp.Required(),
+			mcp.Description("Ticket ID"),
+		),
+		mcp.WithString("body",
+			mcp.Required(),
+			mcp.Description("Note body"),
+		),
+	), h.addInternalNote)
+
+	// Update ticket
+	utils.AddTool(s, mcp.NewTool("update_ticket",
+		mcp.WithDescription("Update an existing ticket. Only the provided fields are changed."),
+		mcp.WithString("id",
+			mcp.Required(),
+			mcp.Description("Ticket ID"),
+		),
+		mcp.WithString("subject",
+			mcp.Description("Ticket subject"),
+		),
+		mcp.WithString("status",
+			mcp.Description("Ticket status ID or name"),
+		),
+		mcp.WithString("type",
+			mcp.Description("Ticket type ID or name"),
+		),
+		mcp.WithString("agent",
+			mcp.Description("ID, email address or full name of the agent to assign"),
+		),
+		mcp.WithString("inbox",
+			mcp.Description("Inbox ID, name or email address"),
+		),
+		mcp.WithString("priority",
+			mcp.Description("Ticket priority ID or name (e.g. \"low\", \"high\")"),
+		),
+		mcp.WithString("customer_id",
+			mcp.Description("ID of the customer"),
+		),
+		mcp.WithString("status_id",
+			mcp.Description("ID of the ticket status. Prefer status, which also takes a name."),
+		),
+		mcp.WithString("type_id",
+			mcp.Description("ID of the ticket type. Prefer type, which also takes a name."),
+		),
+		mcp.WithString("agent_id",
+			mcp.Description("ID of the assigned agent. Prefer agent, which also takes an email address or name."),
+		),
+		mcp.WithString("inbox_id",
+			mcp.Description("ID of the inbox. Prefer inbox, which also takes a name or email address."),
+		),
+		mcp.WithArray("tags",
+			mcp.Description("Tag IDs or names. Replaces the tags of the ticket; an empty array removes them all."),
+			mcp.Items(map[string]interface{}{"type": "string"}),
+		),
+	), h.updateTicket)
+
+	// Delete ticket
+	utils.AddTool(s, mcp.NewTool("delete_ticket",
+		mcp.WithDescription("Delete a ticket. This cannot be undone."),
+		mcp.WithString("id",
+			mcp.Required(),
+			mcp.Description("Ticket ID"),
+		),
+		mcp.WithBoolean("confirm",
+			mcp.Required(),
+			mcp.Description("Must be true to confirm the deletion"),
+		),
+	), h.deleteTicket)
+}
+
+// RegisterResources registers the desk://tickets/{id} resource template
+func (h *TicketHandler) RegisterResources(s *server.MCPServer) {
+	s.AddResourceTemplate(mcp.NewResourceTemplate("desk://tickets/{id}", "Ticket",
+		mcp.WithTemplateDescription("A ticket by ID with its related records and its thread of messages"),
+		mcp.WithTemplateMIMEType("application/json"),
+	), h.readTicket)
 }
 
 func (h *TicketHandler) listTickets(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
 	params := url.Values{}
-	if filterParams, ok := request.Params.Arguments["filter"].(map[string]interface{}); ok {
-		for field, value := range filterParams {
-			params.Add(field, fmt.Sprintf("%v", value))
-		}
-	}
-
-	utils.AddPaginationToParams(params, request)
-
-	resp, err := h.deskClient.Client.Tickets.List(ctx, params)
-	if err != nil {
-		return mcp.NewToolResultError(fmt.Sprintf("Failed to list tickets: %v", err)), nil
+	if err := ticketFilter.AddResolvedToParams(ctx, params, request, h.filterResolvers()); err != nil {
+		return utils.InvalidArgumentResult(ctx, "Failed to list tickets", err), nil
 	}
 
-	// Format the tickets into a more readable structure
-	type formattedTicket struct {
-		ID          int    `json:"id"`
-		Subject     string `json:"subject"`
-		Status      string `json:"status"`
-		CreatedAt   string `json:"created_at"`
-		UpdatedAt   string `json:"updated_at"`
-		PreviewText string `json:"preview_text"`
+	if err := utils.AddPaginationToParams(params, request); err != nil {
+		return utils.ArgumentErrorResult(err), nil
 	}
 
-	tickets := make([]formattedTicket, 0, len(resp.Tickets))
-	for _, t := range resp.Tickets {
-		var status string
+	params.Set("includes", listIncludes)
 
-		for _, s := range resp.Included.Ticketstatuses {
-			if s.ID == t.Status.ID {
-				status = s.Name
-				break
-			}
-		}
-		tickets = append(tickets, formattedTicket{
-			ID:          t.ID,
-			Subject:     t.Subject,
-			Status:      status,
-			CreatedAt:   t.CreatedAt.Format(time.RFC3339),
-			UpdatedAt:   t.UpdatedAt.Format(time.RFC3339),
-			PreviewText: t.PreviewText,
-		})
+	result, err := utils.ListPages(ctx, request, "tickets", params, h.listTicketViews)
+	if err != nil {
+		return utils.ErrorResult(ctx, "Failed to list tickets", err), nil
 	}
 
-	data, err := json.Marshal(tickets)
+	data, err := json.Marshal(result)
 	if err != nil {
 		return mcp.NewToolResultError(fmt.Sprintf("Failed to marshal tickets: %v", err)), nil
 	}
@@ -178,26 +333,30 @@ func (h *TicketHandler) listTickets(ctx context.Context, request mcp.CallToolReq
 // Count tickets
 func (h *TicketHandler) countTickets(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
 	params := url.Values{}
-	utils.AddPaginationToParams(params, request)
+	if err := ticketFilter.AddResolvedToParams(ctx, params, request, h.filterResolvers()); err != nil {
+		return utils.InvalidArgumentResult(ctx, "Failed to count tickets", err), nil
+	}
+
+	utils.AddCountParams(params)
 
-	tickets, err := h.deskClient.Client.Tickets.List(ctx, params)
+	tickets, err := h.client(ctx).Client.Tickets.List(ctx, params)
 	if err != nil {
-		return mcp.NewToolResultError(fmt.Sprintf("Failed to count tickets: %v", err)), nil
+		return utils.ErrorResult(ctx, "Failed to count tickets", err), nil
 	}
 
 	return mcp.NewToolResultText(strconv.Itoa(tickets.Pagination.Records)), nil
 }
 
 func (h *TicketHandler) getTicket(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
-	id, err := strconv.Atoi(request.Params.Arguments["id"].(string))
-	if err != nil {
-		return mcp.NewToolResultError(fmt.Sprintf("Invalid ticket ID: %v", err)), nil
+	var args utils.IDArguments
+	if err := utils.BindArguments(request, &args); err != nil {
+		return utils.ArgumentErrorResult(err), nil
 	}
-	resp, err := h.deskClient.Client.Tickets.Get(ctx, id)
+	ticket, err := h.getTicketView(ctx, args.ID)
 	if err != nil {
-		return mcp.NewToolResultError(fmt.Sprintf("Failed to get ticket: %v", err)), nil
+		return utils.ErrorResult(ctx, "Failed to get ticket", err), nil
 	}
-	data, err := json.Marshal(resp.Ticket)
+	data, err := json.Marshal(ticket)
 	if err != nil {
 		return mcp.NewToolResultError(fmt.Sprintf("Failed to marshal ticket: %v", err)), nil
 	}
@@ -205,13 +364,129 @@ func (h *TicketHandler) getTicket(ctx context.Context, request mcp.CallToolReque
 }
 
 func (h *TicketHandler) createTicket(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
-	ticket := &models.Ticket{
-		Subject:     request.Params.Arguments["subject"].(string),
-		PreviewText: request.Params.Arguments["preview_text"].(string),
+	var args struct {
+		Subject           string   `arg:"subject"`
+		PreviewText       string   `arg:"preview_text"`
+		CustomerID        *int     `arg:"customer_id"`
+		CustomerEmail     string   `arg:"customer_email"`
+		CustomerFirstName string   `arg:"customer_first_name"`
+		CustomerLastName  string   `arg:"customer_last_name"`
+		Inbox             string   `arg:"inbox"`
+		Type              string   `arg:"type"`
+		Status            string   `arg:"status"`
+		Priority          string   `arg:"priority"`
+		Agent             string   `arg:"agent"`
+		Tags              []string `arg:"tags"`
+	}
+	if err := utils.BindArguments(request, &args); err != nil {
+		return utils.ArgumentErrorResult(err), nil
+	}
+
+	// The SDK ticket model has no priority or tags, so the payload is built
+	// by hand and only contains the fields that were provided.
+	ticket := map[string]interface{}{
+		"subject":     args.Subject,
+		"previewText": args.PreviewText,
+	}
+
+	if args.CustomerID == nil && args.CustomerEmail != "" {
+		customerID, err := h.customers.FindOrCreate(ctx, args.CustomerEmail, args.CustomerFirstName, args.CustomerLastName)
+		if err != nil {
+			return utils.ErrorResult(ctx, "Failed to resolve customer", err), nil
+		}
+		args.CustomerID = &customerID
+	}
+	if args.CustomerID != nil {
+		ticket["customer"] = entityRef(*args.CustomerID, "customers")
+	}
+
+	if err := h.setReferences(ctx, ticket, references{args.Inbox, args.Type, args.Status, args.Priority, args.Agent}); err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
 	}
-	resp, err := h.deskClient.Client.Tickets.Create(ctx, ticket)
+
+	if len(args.Tags) > 0 {
+		tagRefs, err := h.tagRefs(ctx, args.Tags)
+		if err != nil {
+			return mcp.NewToolResultError(fmt.Sprintf("Invalid tag: %v", err)), nil
+		}
+		ticket["tags"] = tagRefs
+	}
+
+	var resp models.TicketResponse
+	payload := map[string]interface{}{"ticket": ticket}
+	if err := h.client(ctx).Do(ctx, http.MethodPost, "tickets.json", nil, payload, &resp); err != nil {
+		return utils.ErrorResult(ctx, "Failed to create ticket", err), nil
+	}
+	data, err := json.Marshal(resp.Ticket)
 	if err != nil {
-		return mcp.NewToolResultError(fmt.Sprintf("Failed to create ticket: %v", err)), nil
+		return mcp.NewToolResultError(fmt.Sprintf("Failed to marshal ticket: %v", err)), nil
+	}
+	return mcp.NewToolResultText(string(data)), nil
+}
+
+func (h *TicketHandler) updateTicket(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	var args struct {
+		ID         int      `arg:"id"`
+		Subject    *string  `arg:"subject" field:"subject"`
+		StatusID   *int     `arg:"status_id"`
+		TypeID     *int     `arg:"type_id"`
+		AgentID    *int     `arg:"agent_id"`
+		InboxID    *int     `arg:"inbox_id"`
+		CustomerID *int     `arg:"customer_id"`
+		Inbox      string   `arg:"inbox"`
+		Type       string   `arg:"type"`
+		Status     string   `arg:"status"`
+		Priority   string   `arg:"priority"`
+		Agent      string   `arg:"agent"`
+		Tags       []string `arg:"tags"`
+	}
+	if err := utils.BindArguments(request, &args); err != nil {
+		return utils.ArgumentErrorResult(err), nil
+	}
+
+	fields := utils.ChangedFields(&args)
+	ids := []struct {
+		field string
+		id    *int
+		kind  string
+	}{
+		{"status", args.StatusID, "ticketstatuses"},
+		{"type", args.TypeID, "tickettypes"},
+		{"agent", args.AgentID, "users"},
+		{"inbox", args.InboxID, "inboxes"},
+		{"customer", args.CustomerID, "customers"},
+	}
+	var conflicts utils.ArgumentErrors
+	for _, ref := range ids {
+		if ref.id == nil {
+			continue
+		}
+		if _, ok := request.Params.Arguments[ref.field]; ok {
+			conflicts = append(conflicts, &utils.ArgumentError{Argument: ref.field + "_id", Message: "cannot be given together with " + ref.field})
+			continue
+		}
+		fields[ref.field] = entityRef(*ref.id, ref.kind)
+	}
+	if len(conflicts) > 0 {
+		return utils.ArgumentErrorResult(conflicts), nil
+	}
+	if err := h.setReferences(ctx, fields, references{args.Inbox, args.Type, args.Status, args.Priority, args.Agent}); err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+	if args.Tags != nil {
+		tagRefs, err := h.tagRefs(ctx, args.Tags)
+		if err != nil {
+			return mcp.NewToolResultError(fmt.Sprintf("Invalid tag: %v", err)), nil
+		}
+		fields["tags"] = tagRefs
+	}
+	if len(fields) == 0 {
+		return mcp.NewToolResultError("No fields to update were provided"), nil
+	}
+
+	var resp models.TicketResponse
+	if err := h.client(ctx).Update(ctx, "tickets", "ticket", args.ID, fields, &resp); err != nil {
+		return utils.ErrorResult(ctx, "Failed to update ticket", err), nil
 	}
 	data, err := json.Marshal(resp.Ticket)
 	if err != nil {
@@ -219,3 +494,84 @@ func (h *TicketHandler) createTicket(ctx context.Context, request mcp.CallToolRe
 	}
 	return mcp.NewToolResultText(string(data)), nil
 }
+
+// references are the related records of a ticket that can be given by ID or
+// name
+type references struct {
+	inbox, ticketType, status, priority, agent string
+}
+
+// setReferences resolves the given references and sets them in a ticket
+// payload
+func (h *TicketHandler) setReferences(ctx context.Context, ticket map[string]interface{}, refs references) error {
+	resolvers := []struct {
+		arg     string
+		value   string
+		kind    string
+		resolve func(context.Context, string) (int, error)
+	}{
+		{"inbox", refs.inbox, "inboxes", h.resolveInboxID},
+		{"type", refs.ticketType, "tickettypes", h.ticketTypes.ResolveID},
+		{"status", refs.status, "ticketstatuses", h.ticketStatuses.ResolveID},
+		{"priority", refs.priority, "ticketpriorities", h.resolvePriorityID},
+		{"agent", refs.agent, "users", h.users.ResolveID},
+	}
+	for _, ref := range resolvers {
+		if ref.value == "" {
+			continue
+		}
+		id, err := ref.resolve(ctx, ref.value)
+		if err != nil {
+			return fmt.Errorf("Invalid %s: %v", ref.arg, err)
+		}
+		ticket[ref.arg] = entityRef(id, ref.kind)
+	}
+	return nil
+}
+
+// filterResolvers resolves the status and priority names of a ticket filter
+func (h *TicketHandler) filterResolvers() filter.Resolvers {
+	return filter.Resolvers{
+		"status":   h.ticketStatuses.ResolveID,
+		"priority": h.resolvePriorityID,
+	}
+}
+
+// tagRefs resolves tag IDs or names to the references of a ticket payload
+func (h *TicketHandler) tagRefs(ctx context.Context, tags []string) ([]map[string]interface{}, error) {
+	refs := make([]map[string]interface{}, 0, len(tags))
+	for _, tag := range tags {
+		id, err := h.tags.ResolveID(ctx, tag)
+		if err != nil {
+			return nil, err
+		}
+		refs = append(refs, entityRef(id, "tags"))
+	}
+	return refs, nil
+}
+
+func (h *TicketHandler) deleteTicket(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	var args utils.DeleteArguments
+	if err := utils.BindArguments(request, &args); err != nil {
+		return utils.ArgumentErrorResult(err), nil
+	}
+	if !args.Confirm {
+		return utils.InvalidArgumentResult(ctx, fmt.Sprintf("Not deleting ticket %d", args.ID), &utils.ArgumentError{Argument: "confirm", Message: "must be true to delete it"}), nil
+	}
+	if err := h.client(ctx).Delete(ctx, "tickets", args.ID); err != nil {
+		return utils.ErrorResult(ctx, "Failed to delete ticket", err), nil
+	}
+	return mcp.NewToolResultText(fmt.Sprintf("Ticket %d deleted", args.ID)), nil
+}
+
+func (h *TicketHandler) readTicket(ctx context.Context, request mcp.ReadResourceRequest) ([]mcp.ResourceContents, error) {
+	id, err := utils.ResourceID(request)
+	if err != nil {
+		return nil, err
+	}
+	doc, err := h.GetTicket(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get ticket %d: %w", id, err)
+	}
+	return utils.JSONResource(request.Params.URI, doc)
+}
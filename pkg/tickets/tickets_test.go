@@ -0,0 +1,446 @@
+package tickets
+
+import (
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/mark3labs/mcp-go/client"
+	"github.com/mark3labs/mcp-go/server"
+	"github.com/ready4god2513/deskmcp/pkg/desk"
+	"github.com/ready4god2513/deskmcp/pkg/desktest"
+	"github.com/ready4god2513/deskmcp/pkg/utils"
+)
+
+// setup starts a fake Desk site with its reference data and returns it with
+// an MCP client of a server with the ticket tools
+func setup(t *testing.T) (*desktest.Server, *client.Client) {
+	t.Helper()
+	api, c := desktest.Setup(t, func(s *server.MCPServer, deskClient *desk.Client) {
+		NewTicketHandler(deskClient).RegisterTools(s)
+	})
+	api.SeedReferenceData()
+	return api, c
+}
+
+func ref(id int, kind string) map[string]interface{} {
+	return map[string]interface{}{"id": id, "type": kind}
+}
+
+// addTickets stores tickets of two customers, the first two active and the
+// last one solved
+func addTickets(api *desktest.Server) (ada, bob int) {
+	ada = api.Add("customers", map[string]interface{}{"email": "ada@example.com", "firstName": "Ada"})
+	bob = api.Add("customers", map[string]interface{}{"email": "bob@example.com", "firstName": "Bob"})
+	active := api.Find("ticketstatuses", "name", "Active")
+	solved := api.Find("ticketstatuses", "name", "Solved")
+	api.Add("tickets", map[string]interface{}{"subject": "Printer on fire", "customer": ref(ada, "customers"), "status": ref(active, "ticketstatuses")})
+	api.Add("tickets", map[string]interface{}{"subject": "Invoice missing", "customer": ref(ada, "customers"), "status": ref(active, "ticketstatuses")})
+	api.Add("tickets", map[string]interface{}{"subject": "Password reset", "customer": ref(bob, "customers"), "status": ref(solved, "ticketstatuses")})
+	return ada, bob
+}
+
+// subjects returns the subjects of tickets in alphabetical order
+func subjects(tickets []TicketView) string {
+	s := make([]string, len(tickets))
+	for i, ticket := range tickets {
+		s[i] = ticket.Subject
+	}
+	sort.Strings(s)
+	return strings.Join(s, ", ")
+}
+
+func TestListTickets(t *testing.T) {
+	api, c := setup(t)
+	ada, _ := addTickets(api)
+
+	var result utils.ListResult[TicketView]
+	if err := desktest.DecodeResult(desktest.Call(t, c, "list_tickets", nil), &result); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := subjects(result.Items), "Invoice missing, Password reset, Printer on fire"; got != want {
+		t.Errorf("tickets = %s, want %s", got, want)
+	}
+	if result.Pagination.Records != 3 || result.Pagination.HasMore {
+		t.Errorf("pagination = %+v, want 3 records and no more", result.Pagination)
+	}
+	for _, ticket := range result.Items {
+		if ticket.Subject != "Printer on fire" {
+			continue
+		}
+		if ticket.Customer == nil || ticket.Customer.ID != ada || ticket.Customer.Email != "ada@example.com" {
+			t.Errorf("customer = %+v, want ada resolved from the included records", ticket.Customer)
+		}
+		if ticket.Status == nil || ticket.Status.Name != "Active" {
+			t.Errorf("status = %+v, want Active", ticket.Status)
+		}
+	}
+}
+
+func TestListTicketsPages(t *testing.T) {
+	api, c := setup(t)
+	addTickets(api)
+
+	var result utils.ListResult[TicketView]
+	if err := desktest.DecodeResult(desktest.Call(t, c, "list_tickets", map[string]interface{}{"pageSize": 2}), &result); err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Items) != 2 || !result.Pagination.HasMore || result.Pagination.NextCursor == "" {
+		t.Fatalf("first page = %d tickets, %+v, want 2 tickets and a cursor", len(result.Items), result.Pagination)
+	}
+
+	var next utils.ListResult[TicketView]
+	args := map[string]interface{}{"cursor": result.Pagination.NextCursor}
+	if err := desktest.DecodeResult(desktest.Call(t, c, "list_tickets", args), &next); err != nil {
+		t.Fatal(err)
+	}
+	if len(next.Items) != 1 || next.Pagination.HasMore {
+		t.Errorf("second page = %d tickets, %+v, want the last ticket", len(next.Items), next.Pagination)
+	}
+	all := append(result.Items, next.Items...)
+	if got, want := subjects(all), "Invoice missing, Password reset, Printer on fire"; got != want {
+		t.Errorf("tickets of both pages = %s, want %s", got, want)
+	}
+}
+
+func TestListTicketsFilter(t *testing.T) {
+	api, c := setup(t)
+	ada, bob := addTickets(api)
+	solved := api.Find("ticketstatuses", "name", "Solved")
+
+	tests := []struct {
+		name   string
+		filter map[string]interface{}
+		want   string
+	}{
+		{"customer", map[string]interface{}{"customer_id": ada}, "Invoice missing, Printer on fire"},
+		{"not equal", map[string]interface{}{"customer_id": map[string]interface{}{"$ne": ada}}, "Password reset"},
+		{"in", map[string]interface{}{"status": map[string]interface{}{"$in": []interface{}{solved}}}, "Password reset"},
+		{"status name", map[string]interface{}{"status": "solved"}, "Password reset"},
+		{"status names", map[string]interface{}{"status": map[string]interface{}{"$nin": []interface{}{"Solved", "Closed"}}}, "Invoice missing, Printer on fire"},
+		{"subject", map[string]interface{}{"subject": "invoice missing"}, "Invoice missing"},
+		{"or", map[string]interface{}{"$or": []interface{}{
+			map[string]interface{}{"customer_id": bob},
+			map[string]interface{}{"subject": "Printer on fire"},
+		}}, "Password reset, Printer on fire"},
+		{"and", map[string]interface{}{"$and": []interface{}{
+			map[string]interface{}{"customer_id": ada},
+			map[string]interface{}{"subject": map[string]interface{}{"$ne": "Printer on fire"}},
+		}}, "Invoice missing"},
+		{"no match", map[string]interface{}{"customer_id": bob, "subject": "Printer on fire"}, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var result utils.ListResult[TicketView]
+			if err := desktest.DecodeResult(desktest.Call(t, c, "list_tickets", map[string]interface{}{"filter": tt.filter}), &result); err != nil {
+				t.Fatal(err)
+			}
+			if got := subjects(result.Items); got != tt.want {
+				t.Errorf("tickets = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestListTicketsInvalidFilter(t *testing.T) {
+	api, c := setup(t)
+	addTickets(api)
+
+	result := desktest.Call(t, c, "list_tickets", map[string]interface{}{"filter": map[string]interface{}{"bogus": 1}})
+	if code, _ := utils.ResultError(result, nil); code != utils.ErrInvalidArguments {
+		t.Errorf("code = %q, want %q: %s", code, utils.ErrInvalidArguments, desktest.ResultText(result))
+	}
+	if n := len(api.Requests()); n != 0 {
+		t.Errorf("%d requests sent to Desk, want none", n)
+	}
+}
+
+func TestListTicketsUnknownStatus(t *testing.T) {
+	api, c := setup(t)
+	addTickets(api)
+
+	result := desktest.Call(t, c, "list_tickets", map[string]interface{}{"filter": map[string]interface{}{"status": "Escalated"}})
+	code, message := utils.ResultError(result, nil)
+	if code != utils.ErrInvalidArguments || !strings.Contains(message, "Escalated") {
+		t.Errorf("code = %q, want %q naming the status: %s", code, utils.ErrInvalidArguments, message)
+	}
+	for _, r := range api.Requests() {
+		if r.Path == "tickets.json" {
+			t.Errorf("tickets were listed with an unknown status: %s", r.Path)
+		}
+	}
+}
+
+func TestCountTickets(t *testing.T) {
+	api, c := setup(t)
+	ada, _ := addTickets(api)
+
+	tests := []struct {
+		filter map[string]interface{}
+		want   string
+	}{
+		{nil, "3"},
+		{map[string]interface{}{"customer_id": ada}, "2"},
+		{map[string]interface{}{"subject": "nothing like it"}, "0"},
+	}
+	for _, tt := range tests {
+		args := map[string]interface{}{}
+		if tt.filter != nil {
+			args["filter"] = tt.filter
+		}
+		result := desktest.Call(t, c, "count_tickets", args)
+		if got := desktest.ResultText(result); result.IsError || got != tt.want {
+			t.Errorf("count with filter %v = %s, want %s", tt.filter, got, tt.want)
+		}
+	}
+}
+
+func TestGetTicket(t *testing.T) {
+	api, c := setup(t)
+	ada, _ := addTickets(api)
+	id := api.Find("tickets", "subject", "Invoice missing")
+
+	var ticket TicketView
+	if err := desktest.DecodeResult(desktest.Call(t, c, "get_ticket", map[string]interface{}{"id": strconv.Itoa(id)}), &ticket); err != nil {
+		t.Fatal(err)
+	}
+	if ticket.ID != id || ticket.Subject != "Invoice missing" {
+		t.Errorf("ticket = %d %q, want %d %q", ticket.ID, ticket.Subject, id, "Invoice missing")
+	}
+	if ticket.Customer == nil || ticket.Customer.ID != ada {
+		t.Errorf("customer = %+v, want %d", ticket.Customer, ada)
+	}
+}
+
+func TestGetTicketCustomerOrganization(t *testing.T) {
+	api, c := setup(t)
+	grace := api.Add("customers", map[string]interface{}{"email": "grace@example.com", "firstName": "Grace", "organization": "Acme"})
+	id := api.Add("tickets", map[string]interface{}{"subject": "Cannot log in", "customer": ref(grace, "customers")})
+
+	var ticket TicketView
+	if err := desktest.DecodeResult(desktest.Call(t, c, "get_ticket", map[string]interface{}{"id": strconv.Itoa(id)}), &ticket); err != nil {
+		t.Fatal(err)
+	}
+	// The organization a customer gave is not a company
+	if ticket.Company != nil || ticket.CustomerOrganization != "Acme" {
+		t.Errorf("company = %+v, customer organization = %q, want no company and Acme", ticket.Company, ticket.CustomerOrganization)
+	}
+}
+
+func TestCreateTicket(t *testing.T) {
+	api, c := setup(t)
+
+	result := desktest.Call(t, c, "create_ticket", map[string]interface{}{
+		"subject":             "Cannot log in",
+		"preview_text":        "The login page says my password is wrong",
+		"customer_email":      "grace@example.com",
+		"customer_first_name": "Grace",
+		"status":              "Active",
+		"type":                "Problem",
+		"priority":            "high",
+		"inbox":               "support@example.com",
+	})
+	if result.IsError {
+		t.Fatal(desktest.ResultText(result))
+	}
+
+	id := api.Find("tickets", "subject", "Cannot log in")
+	if id == 0 {
+		t.Fatal("ticket was not created")
+	}
+	customer := api.Find("customers", "email", "grace@example.com")
+	if customer == 0 {
+		t.Fatal("customer was not created")
+	}
+	stored, _ := api.Get("tickets", id)
+	for field, want := range map[string]int{
+		"customer": customer,
+		"status":   api.Find("ticketstatuses", "name", "Active"),
+		"type":     api.Find("tickettypes", "name", "Problem"),
+		"priority": api.Find("ticketpriorities", "name", "High"),
+		"inbox":    api.Find("inboxes", "name", "Support"),
+	} {
+		got, _ := stored[field].(map[string]interface{})
+		if id, _ := got["id"].(float64); int(id) != want {
+			t.Errorf("%s = %v, want ID %d", field, stored[field], want)
+		}
+	}
+}
+
+func TestCreateTicketUnknownStatus(t *testing.T) {
+	api, c := setup(t)
+
+	result := desktest.Call(t, c, "create_ticket", map[string]interface{}{
+		"subject":      "Cannot log in",
+		"preview_text": "The login page says my password is wrong",
+		"status":       "Escalated",
+	})
+	if !result.IsError || !strings.Contains(desktest.ResultText(result), "Invalid status") {
+		t.Errorf("result = %s, want an invalid status error", desktest.ResultText(result))
+	}
+	if api.Find("tickets", "subject", "Cannot log in") != 0 {
+		t.Error("ticket was created")
+	}
+}
+
+func TestUpdateTicket(t *testing.T) {
+	api, c := setup(t)
+	_, bob := addTickets(api)
+	id := api.Find("tickets", "subject", "Printer on fire")
+	solved := api.Find("ticketstatuses", "name", "Solved")
+
+	result := desktest.Call(t, c, "update_ticket", map[string]interface{}{
+		"id":          strconv.Itoa(id),
+		"subject":     "Printer no longer on fire",
+		"status_id":   strconv.Itoa(solved),
+		"customer_id": strconv.Itoa(bob),
+	})
+	if result.IsError {
+		t.Fatal(desktest.ResultText(result))
+	}
+
+	stored, _ := api.Get("tickets", id)
+	if stored["subject"] != "Printer no longer on fire" {
+		t.Errorf("subject = %v", stored["subject"])
+	}
+	for field, want := range map[string]int{"status": solved, "customer": bob} {
+		got, _ := stored[field].(map[string]interface{})
+		if id, _ := got["id"].(float64); int(id) != want {
+			t.Errorf("%s = %v, want ID %d", field, stored[field], want)
+		}
+	}
+}
+
+func TestUpdateTicketByName(t *testing.T) {
+	api, c := setup(t)
+	addTickets(api)
+	id := api.Find("tickets", "subject", "Printer on fire")
+
+	result := desktest.Call(t, c, "update_ticket", map[string]interface{}{
+		"id":       strconv.Itoa(id),
+		"status":   "solved",
+		"type":     "Problem",
+		"priority": "Urgent",
+		"agent":    "alex@example.com",
+		"inbox":    "Support",
+	})
+	if result.IsError {
+		t.Fatal(desktest.ResultText(result))
+	}
+
+	stored, _ := api.Get("tickets", id)
+	for field, want := range map[string]int{
+		"status":   api.Find("ticketstatuses", "name", "Solved"),
+		"type":     api.Find("tickettypes", "name", "Problem"),
+		"priority": api.Find("ticketpriorities", "name", "Urgent"),
+		"agent":    api.Find("users", "email", "alex@example.com"),
+		"inbox":    api.Find("inboxes", "name", "Support"),
+	} {
+		got, _ := stored[field].(map[string]interface{})
+		if id, _ := got["id"].(float64); int(id) != want {
+			t.Errorf("%s = %v, want ID %d", field, stored[field], want)
+		}
+	}
+}
+
+func TestUpdateTicketInvalidReferences(t *testing.T) {
+	tests := []struct {
+		name string
+		args map[string]interface{}
+		want string
+	}{
+		{"unknown status", map[string]interface{}{"status": "Escalated"}, "Invalid status"},
+		{"unknown agent", map[string]interface{}{"agent": "nobody@example.com"}, "Invalid agent"},
+		{"status and status_id", map[string]interface{}{"status": "Solved", "status_id": "1"}, "status_id: cannot be given together with status"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			api, c := setup(t)
+			addTickets(api)
+			id := api.Find("tickets", "subject", "Printer on fire")
+
+			tt.args["id"] = strconv.Itoa(id)
+			result := desktest.Call(t, c, "update_ticket", tt.args)
+			if !result.IsError || !strings.Contains(desktest.ResultText(result), tt.want) {
+				t.Errorf("result = %s, want an error saying %q", desktest.ResultText(result), tt.want)
+			}
+			for _, r := range api.Requests() {
+				if r.Method == http.MethodPut || r.Method == http.MethodPatch {
+					t.Errorf("ticket was updated: %s %s", r.Method, r.Path)
+				}
+			}
+		})
+	}
+}
+
+func TestUpdateTicketWithoutFields(t *testing.T) {
+	api, c := setup(t)
+	addTickets(api)
+	id := api.Find("tickets", "subject", "Printer on fire")
+
+	result := desktest.Call(t, c, "update_ticket", map[string]interface{}{"id": strconv.Itoa(id)})
+	if !result.IsError {
+		t.Errorf("result = %s, want an error", desktest.ResultText(result))
+	}
+	if n := len(api.Requests()); n != 0 {
+		t.Errorf("%d requests sent to Desk, want none", n)
+	}
+}
+
+func TestDeleteTicket(t *testing.T) {
+	api, c := setup(t)
+	addTickets(api)
+	id := api.Find("tickets", "subject", "Printer on fire")
+
+	result := desktest.Call(t, c, "delete_ticket", map[string]interface{}{"id": strconv.Itoa(id), "confirm": false})
+	if code, _ := utils.ResultError(result, nil); code != utils.ErrInvalidArguments {
+		t.Errorf("code without confirm = %q, want %q", code, utils.ErrInvalidArguments)
+	}
+	if _, ok := api.Get("tickets", id); !ok {
+		t.Fatal("ticket was deleted without confirm")
+	}
+
+	result = desktest.Call(t, c, "delete_ticket", map[string]interface{}{"id": strconv.Itoa(id), "confirm": true})
+	if result.IsError {
+		t.Fatal(desktest.ResultText(result))
+	}
+	if _, ok := api.Get("tickets", id); ok {
+		t.Error("ticket was not deleted")
+	}
+}
+
+func TestTicketErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		tool   string
+		args   map[string]interface{}
+		method string
+		path   string
+		status int
+		want   string
+	}{
+		{"list server error", "list_tickets", nil, http.MethodGet, "tickets.json", http.StatusInternalServerError, desk.ErrServer},
+		{"count rate limited", "count_tickets", nil, http.MethodGet, "tickets.json", http.StatusTooManyRequests, desk.ErrRateLimited},
+		{"get missing", "get_ticket", map[string]interface{}{"id": "999"}, "", "", 0, desk.ErrNotFound},
+		{"get forbidden", "get_ticket", map[string]interface{}{"id": "1"}, http.MethodGet, "tickets/1.json", http.StatusForbidden, desk.ErrForbidden},
+		{"update unauthorized", "update_ticket", map[string]interface{}{"id": "1", "subject": "x"}, "", "tickets", http.StatusUnauthorized, desk.ErrUnauthorized},
+		{"delete missing", "delete_ticket", map[string]interface{}{"id": "999", "confirm": true}, "", "", 0, desk.ErrNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			api, c := setup(t)
+			addTickets(api)
+			if tt.status != 0 {
+				api.Fail(tt.method, tt.path, tt.status, 1)
+			}
+
+			result := desktest.Call(t, c, tt.tool, tt.args)
+			code, message := utils.ResultError(result, nil)
+			if code != tt.want {
+				t.Errorf("code = %q, want %q: %s", code, tt.want, message)
+			}
+		})
+	}
+}
@@ -0,0 +1,193 @@
+package tickets
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/ready4god2513/deskmcp/pkg/include"
+	"github.com/ready4god2513/deskmcp/pkg/utils"
+	"github.com/ready4god2513/desksdkgo/models"
+)
+
+// listIncludes are the relations sideloaded when listing tickets. Only the
+// records the tickets of a page refer to are included. Messages are left out
+// since they make up most of the response.
+const listIncludes = "customers,companies,users,inboxes,tags,ticketstatuses,tickettypes,ticketpriorities,ticketsources"
+
+// ticketRecord is a ticket as returned by the Desk API, including the
+// relations the SDK ticket model has no fields for
+type ticketRecord struct {
+	models.Ticket
+	Priority models.EntityRef   `json:"priority"`
+	Company  models.EntityRef   `json:"company"`
+	Tags     []models.EntityRef `json:"tags"`
+}
+
+type ticketsResponse struct {
+	Tickets    []ticketRecord    `json:"tickets"`
+	Included   include.Data      `json:"included"`
+	Pagination models.Pagination `json:"pagination"`
+}
+
+type ticketResponse struct {
+	Ticket   ticketRecord `json:"ticket"`
+	Included include.Data `json:"included"`
+}
+
+// TicketView is a ticket with its related records resolved to names
+type TicketView struct {
+	ID          int          `json:"id"`
+	Subject     string       `json:"subject"`
+	PreviewText string       `json:"preview_text"`
+	Status      *include.Ref `json:"status,omitempty"`
+	Priority    *include.Ref `json:"priority,omitempty"`
+	Type        *include.Ref `json:"type,omitempty"`
+	Source      *include.Ref `json:"source,omitempty"`
+	Inbox       *include.Ref `json:"inbox,omitempty"`
+	Customer    *include.Ref `json:"customer,omitempty"`
+	Company     *include.Ref `json:"company,omitempty"`
+	Agent       *include.Ref `json:"agent,omitempty"`
+	// CustomerOrganization is the organization the customer gave, which is
+	// not necessarily a company known to Desk
+	CustomerOrganization string        `json:"customer_organization,omitempty"`
+	Tags                 []include.Ref `json:"tags,omitempty"`
+	MessageCount         int           `json:"message_count"`
+	CreatedAt            string        `json:"created_at"`
+	UpdatedAt            string        `json:"updated_at"`
+}
+
+// newTicketView resolves the relations of a ticket from the included records
+func newTicketView(t ticketRecord, ix *include.Index) TicketView {
+	view := TicketView{
+		ID:                   t.ID,
+		Subject:              t.Subject,
+		PreviewText:          t.PreviewText,
+		Status:               ix.Status(t.Status.ID),
+		Priority:             ix.Priority(t.Priority.ID),
+		Type:                 ix.Type(t.Type.ID),
+		Source:               ix.Source(t.Source.ID),
+		Inbox:                ix.Inbox(t.Inbox.ID),
+		Customer:             ix.Customer(t.Customer.ID),
+		Company:              ix.Company(t.Company.ID),
+		Agent:                ix.User(t.Agent.ID),
+		CustomerOrganization: ix.CustomerOrganization(t.Customer.ID),
+		MessageCount:         t.MessageCount,
+		CreatedAt:            t.CreatedAt.Format(time.RFC3339),
+		UpdatedAt:            t.UpdatedAt.Format(time.RFC3339),
+	}
+
+	for _, tag := range t.Tags {
+		if ref := ix.Tag(tag.ID); ref != nil {
+			view.Tags = append(view.Tags, *ref)
+		}
+	}
+	return view
+}
+
+// listTicketViews fetches a page of tickets with their relations resolved.
+// params should request the listIncludes relations.
+func (h *TicketHandler) listTicketViews(ctx context.Context, params url.Values) ([]TicketView, models.Pagination, error) {
+	var resp ticketsResponse
+	if err := h.client(ctx).Do(ctx, http.MethodGet, "tickets.json", params, nil, &resp); err != nil {
+		return nil, models.Pagination{}, err
+	}
+
+	ix := include.New(resp.Included)
+	views := make([]TicketView, 0, len(resp.Tickets))
+	for _, t := range resp.Tickets {
+		views = append(views, newTicketView(t, ix))
+	}
+	return views, resp.Pagination, nil
+}
+
+// FindTickets returns up to maxRecords tickets matching the filter, most
+// recently updated first. The filter takes the same fields and operators as
+// the filter argument of list_tickets, with values as decoded from JSON.
+func (h *TicketHandler) FindTickets(ctx context.Context, where map[string]interface{}, maxRecords int) ([]TicketView, utils.PageInfo, error) {
+	return h.findTickets(ctx, where, "desc", maxRecords)
+}
+
+// FindUpdatedTickets is like FindTickets, but returns the least recently updated
+// tickets first, so that the tickets changed since a time can be fetched in
+// batches of maxRecords
+func (h *TicketHandler) FindUpdatedTickets(ctx context.Context, where map[string]interface{}, maxRecords int) ([]TicketView, utils.PageInfo, error) {
+	return h.findTickets(ctx, where, "asc", maxRecords)
+}
+
+func (h *TicketHandler) findTickets(ctx context.Context, where map[string]interface{}, orderMode string, maxRecords int) ([]TicketView, utils.PageInfo, error) {
+	params := url.Values{}
+	if len(where) > 0 {
+		encoded, err := ticketFilter.Compile(where)
+		if err != nil {
+			return nil, utils.PageInfo{}, err
+		}
+		params.Set("filter", encoded)
+	}
+
+	pageSize := utils.MaxPageSize
+	if maxRecords > 0 && maxRecords < pageSize {
+		pageSize = maxRecords
+	}
+	params.Set("orderBy", "updatedAt")
+	params.Set("orderMode", orderMode)
+	params.Set("page", "1")
+	params.Set("pageSize", strconv.Itoa(pageSize))
+	params.Set("includes", listIncludes)
+
+	opts := utils.ListOptions{FetchAll: true, MaxRecords: maxRecords}
+	result, err := utils.FetchPages(ctx, "tickets", params, opts, h.listTicketViews)
+	if err != nil {
+		return nil, utils.PageInfo{}, err
+	}
+	return result.Items, result.Pagination, nil
+}
+
+// ValidateFilter reports whether the filter is one FindTickets accepts
+func ValidateFilter(where map[string]interface{}) error {
+	_, err := ticketFilter.Compile(where)
+	return err
+}
+
+// fetchTicket fetches a ticket with all of its relations, including the
+// messages of its thread
+func (h *TicketHandler) fetchTicket(ctx context.Context, id int) (ticketResponse, error) {
+	params := url.Values{}
+	params.Set("includes", "all")
+
+	var resp ticketResponse
+	path := fmt.Sprintf("tickets/%d.json", id)
+	err := h.client(ctx).Do(ctx, http.MethodGet, path, params, nil, &resp)
+	return resp, err
+}
+
+// getTicketView fetches a ticket with its relations resolved
+func (h *TicketHandler) getTicketView(ctx context.Context, id int) (TicketView, error) {
+	resp, err := h.fetchTicket(ctx, id)
+	if err != nil {
+		return TicketView{}, err
+	}
+	return newTicketView(resp.Ticket, include.New(resp.Included)), nil
+}
+
+// TicketDocument is a ticket together with its thread
+type TicketDocument struct {
+	Ticket   TicketView      `json:"ticket"`
+	Messages []ThreadMessage `json:"messages"`
+}
+
+// GetTicket fetches a ticket with its relations resolved and its thread
+func (h *TicketHandler) GetTicket(ctx context.Context, id int) (TicketDocument, error) {
+	resp, err := h.fetchTicket(ctx, id)
+	if err != nil {
+		return TicketDocument{}, err
+	}
+	ix := include.New(resp.Included)
+	return TicketDocument{
+		Ticket:   newTicketView(resp.Ticket, ix),
+		Messages: ticketThread(id, resp.Included.Messages, ix),
+	}, nil
+}
@@ -5,15 +5,23 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/url"
-	"strconv"
+	"strings"
 
 	"github.com/mark3labs/mcp-go/mcp"
 	"github.com/mark3labs/mcp-go/server"
 	"github.com/ready4god2513/deskmcp/pkg/desk"
+	"github.com/ready4god2513/deskmcp/pkg/filter"
 	"github.com/ready4god2513/deskmcp/pkg/utils"
 	"github.com/ready4god2513/desksdkgo/models"
 )
 
+// ticketStatusFilter lists the fields ticket statuses can be filtered by
+var ticketStatusFilter = filter.NewSchema("ticket statuses",
+	filter.Text("name"),
+	filter.Time("createdAt", "created_at"),
+	filter.Time("updatedAt", "updated_at"),
+)
+
 type TicketStatusHandler struct {
 	deskClient *desk.Client
 }
@@ -24,15 +32,23 @@ func NewTicketStatusHandler(deskClient *desk.Client) *TicketStatusHandler {
 	}
 }
 
+// client returns the Desk client to use for the request. Over HTTP each
+// session may supply its own credentials, otherwise the handler's client is used.
+func (h *TicketStatusHandler) client(ctx context.Context) *desk.Client {
+	return desk.ClientFromContext(ctx, h.deskClient)
+}
+
 func (h *TicketStatusHandler) RegisterTools(s *server.MCPServer) {
 	// List ticket statuses
-	s.AddTool(mcp.NewTool("list_ticket_statuses",
+	utils.AddTool(s, mcp.NewTool("list_ticket_statuses",
 		mcp.WithDescription("List all ticket statuses"),
 		mcp.WithObject("filter",
 			mcp.Description(`Optional filter for ticket statuses. Available fields:
 - name: Filter by ticket status name
 - created_at: Filter by creation date
-- updated_at: Filter by last update date`),
+- updated_at: Filter by last update date
+
+Supports the $eq, $ne, $lt, $lte, $gt, $gte, $in, $nin, $and and $or operators described on list_tickets.`),
 		),
 		mcp.WithString("orderBy",
 			mcp.Description("Order by field"),
@@ -51,10 +67,11 @@ func (h *TicketStatusHandler) RegisterTools(s *server.MCPServer) {
 			mcp.Min(1),
 			mcp.Max(100),
 		),
+		utils.WithListOptions(),
 	), h.listTicketStatuses)
 
 	// Get ticket status
-	s.AddTool(mcp.NewTool("get_ticket_status",
+	utils.AddTool(s, mcp.NewTool("get_ticket_status",
 		mcp.WithDescription("Get a specific ticket status by ID"),
 		mcp.WithString("id",
 			mcp.Required(),
@@ -63,30 +80,69 @@ func (h *TicketStatusHandler) RegisterTools(s *server.MCPServer) {
 	), h.getTicketStatus)
 
 	// Create ticket status
-	s.AddTool(mcp.NewTool("create_ticket_status",
+	utils.AddTool(s, mcp.NewTool("create_ticket_status",
 		mcp.WithDescription("Create a new ticket status"),
 		mcp.WithString("name",
 			mcp.Required(),
 			mcp.Description("Ticket status name"),
 		),
 	), h.createTicketStatus)
+
+	// Update ticket status
+	utils.AddTool(s, mcp.NewTool("update_ticket_status",
+		mcp.WithDescription("Update an existing ticket status. Only the provided fields are changed."),
+		mcp.WithString("id",
+			mcp.Required(),
+			mcp.Description("Ticket status ID"),
+		),
+		mcp.WithString("name",
+			mcp.Description("Ticket status name"),
+		),
+		mcp.WithString("color",
+			mcp.Description("Ticket status color, e.g. #ff0000"),
+		),
+		mcp.WithNumber("display_order",
+			mcp.Description("Position of the ticket status in lists"),
+		),
+	), h.updateTicketStatus)
+
+	// Delete ticket status
+	utils.AddTool(s, mcp.NewTool("delete_ticket_status",
+		mcp.WithDescription("Delete a ticket status. This cannot be undone."),
+		mcp.WithString("id",
+			mcp.Required(),
+			mcp.Description("Ticket status ID"),
+		),
+		mcp.WithBoolean("confirm",
+			mcp.Required(),
+			mcp.Description("Must be true to confirm the deletion"),
+		),
+	), h.deleteTicketStatus)
+}
+
+// RegisterResources registers the desk://ticketstatuses resource listing every ticket status
+func (h *TicketStatusHandler) RegisterResources(s *server.MCPServer) {
+	s.AddResource(mcp.NewResource("desk://ticketstatuses", "Ticket statuses",
+		mcp.WithResourceDescription("All ticket statuses"),
+		mcp.WithMIMEType("application/json"),
+	), h.readAll)
 }
 
 func (h *TicketStatusHandler) listTicketStatuses(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
 	params := url.Values{}
-	if filterParams, ok := request.Params.Arguments["filter"].(map[string]interface{}); ok {
-		for field, value := range filterParams {
-			params.Add(field, fmt.Sprintf("%v", value))
-		}
+	if err := ticketStatusFilter.AddToParams(params, request); err != nil {
+		return utils.InvalidArgumentResult(ctx, "Failed to list ticket statuses", err), nil
 	}
 
-	utils.AddPaginationToParams(params, request)
+	if err := utils.AddPaginationToParams(params, request); err != nil {
+		return utils.ArgumentErrorResult(err), nil
+	}
 
-	resp, err := h.deskClient.Client.TicketStatuses.List(ctx, params)
+	result, err := utils.ListPages(ctx, request, "ticketstatuses", params, h.listPage)
 	if err != nil {
-		return mcp.NewToolResultError(fmt.Sprintf("Failed to list ticket statuses: %v", err)), nil
+		return utils.ErrorResult(ctx, "Failed to list ticket statuses", err), nil
 	}
-	data, err := json.Marshal(resp.TicketStatuses)
+	data, err := json.Marshal(result)
 	if err != nil {
 		return mcp.NewToolResultError(fmt.Sprintf("Failed to marshal ticket statuses: %v", err)), nil
 	}
@@ -94,13 +150,13 @@ func (h *TicketStatusHandler) listTicketStatuses(ctx context.Context, request mc
 }
 
 func (h *TicketStatusHandler) getTicketStatus(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
-	id, err := strconv.Atoi(request.Params.Arguments["id"].(string))
-	if err != nil {
-		return mcp.NewToolResultError(fmt.Sprintf("Invalid ticket status ID: %v", err)), nil
+	var args utils.IDArguments
+	if err := utils.BindArguments(request, &args); err != nil {
+		return utils.ArgumentErrorResult(err), nil
 	}
-	resp, err := h.deskClient.Client.TicketStatuses.Get(ctx, id)
+	resp, err := h.client(ctx).Client.TicketStatuses.Get(ctx, args.ID)
 	if err != nil {
-		return mcp.NewToolResultError(fmt.Sprintf("Failed to get ticket status: %v", err)), nil
+		return utils.ErrorResult(ctx, "Failed to get ticket status", err), nil
 	}
 	data, err := json.Marshal(resp.TicketStatus)
 	if err != nil {
@@ -110,12 +166,44 @@ func (h *TicketStatusHandler) getTicketStatus(ctx context.Context, request mcp.C
 }
 
 func (h *TicketStatusHandler) createTicketStatus(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	var args struct {
+		Name string `arg:"name"`
+	}
+	if err := utils.BindArguments(request, &args); err != nil {
+		return utils.ArgumentErrorResult(err), nil
+	}
 	ticketStatus := &models.TicketStatus{
-		Name: request.Params.Arguments["name"].(string),
+		Name: args.Name,
 	}
-	resp, err := h.deskClient.Client.TicketStatuses.Create(ctx, ticketStatus)
+	resp, err := h.client(ctx).Client.TicketStatuses.Create(ctx, ticketStatus)
+	if err != nil {
+		return utils.ErrorResult(ctx, "Failed to create ticket status", err), nil
+	}
+	data, err := json.Marshal(resp.TicketStatus)
 	if err != nil {
-		return mcp.NewToolResultError(fmt.Sprintf("Failed to create ticket status: %v", err)), nil
+		return mcp.NewToolResultError(fmt.Sprintf("Failed to marshal ticket status: %v", err)), nil
+	}
+	return mcp.NewToolResultText(string(data)), nil
+}
+
+func (h *TicketStatusHandler) updateTicketStatus(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	var args struct {
+		ID           int     `arg:"id"`
+		Name         *string `arg:"name" field:"name"`
+		Color        *string `arg:"color" field:"color"`
+		DisplayOrder *int    `arg:"display_order" field:"displayOrder"`
+	}
+	if err := utils.BindArguments(request, &args); err != nil {
+		return utils.ArgumentErrorResult(err), nil
+	}
+	fields := utils.ChangedFields(&args)
+	if len(fields) == 0 {
+		return mcp.NewToolResultError("No fields to update were provided"), nil
+	}
+
+	var resp models.TicketStatusResponse
+	if err := h.client(ctx).Update(ctx, "ticketstatuses", "ticketstatus", args.ID, fields, &resp); err != nil {
+		return utils.ErrorResult(ctx, "Failed to update ticket status", err), nil
 	}
 	data, err := json.Marshal(resp.TicketStatus)
 	if err != nil {
@@ -123,3 +211,53 @@ func (h *TicketStatusHandler) createTicketStatus(ctx context.Context, request mc
 	}
 	return mcp.NewToolResultText(string(data)), nil
 }
+
+func (h *TicketStatusHandler) deleteTicketStatus(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	var args utils.DeleteArguments
+	if err := utils.BindArguments(request, &args); err != nil {
+		return utils.ArgumentErrorResult(err), nil
+	}
+	if !args.Confirm {
+		return utils.InvalidArgumentResult(ctx, fmt.Sprintf("Not deleting ticket status %d", args.ID), &utils.ArgumentError{Argument: "confirm", Message: "must be true to delete it"}), nil
+	}
+	if err := h.client(ctx).Delete(ctx, "ticketstatuses", args.ID); err != nil {
+		return utils.ErrorResult(ctx, "Failed to delete ticket status", err), nil
+	}
+	return mcp.NewToolResultText(fmt.Sprintf("Ticket status %d deleted", args.ID)), nil
+}
+
+// ResolveID returns the ID of the ticket status identified by nameOrID, which is
+// either a numeric ID or a ticket status name (matched case-insensitively)
+func (h *TicketStatusHandler) ResolveID(ctx context.Context, nameOrID string) (int, error) {
+	all, err := h.ListAll(ctx)
+	if err != nil {
+		return 0, err
+	}
+	if id, ok := utils.ResolveName(nameOrID, all, func(r models.TicketStatus, name string) bool {
+		return strings.EqualFold(r.Name, name)
+	}, func(r models.TicketStatus) int { return r.ID }); ok {
+		return id, nil
+	}
+	return 0, fmt.Errorf("no ticket status named %q", nameOrID)
+}
+
+// ListAll returns every ticket status
+func (h *TicketStatusHandler) ListAll(ctx context.Context) ([]models.TicketStatus, error) {
+	return utils.FetchAll(ctx, url.Values{}, h.listPage)
+}
+
+func (h *TicketStatusHandler) listPage(ctx context.Context, params url.Values) ([]models.TicketStatus, models.Pagination, error) {
+	resp, err := h.client(ctx).Client.TicketStatuses.List(ctx, params)
+	if err != nil {
+		return nil, models.Pagination{}, err
+	}
+	return resp.TicketStatuses, resp.Pagination, nil
+}
+
+func (h *TicketStatusHandler) readAll(ctx context.Context, request mcp.ReadResourceRequest) ([]mcp.ResourceContents, error) {
+	all, err := h.ListAll(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list ticket statuses: %w", err)
+	}
+	return utils.JSONResource(request.Params.URI, all)
+}
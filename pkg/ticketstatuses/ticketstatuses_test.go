@@ -0,0 +1,171 @@
+package ticketstatuses
+
+import (
+	"context"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/mark3labs/mcp-go/client"
+	"github.com/mark3labs/mcp-go/server"
+	"github.com/ready4god2513/deskmcp/pkg/desk"
+	"github.com/ready4god2513/deskmcp/pkg/desktest"
+	"github.com/ready4god2513/deskmcp/pkg/utils"
+	"github.com/ready4god2513/desksdkgo/models"
+)
+
+// setup starts a fake Desk site with its reference data and returns it with
+// an MCP client of a server with the ticket status tools
+func setup(t *testing.T) (*desktest.Server, *client.Client) {
+	t.Helper()
+	api, c := desktest.Setup(t, func(s *server.MCPServer, deskClient *desk.Client) {
+		NewTicketStatusHandler(deskClient).RegisterTools(s)
+	})
+	api.SeedReferenceData()
+	return api, c
+}
+
+// names returns the names of ticket statuses in alphabetical order
+func names(statuses []models.TicketStatus) string {
+	s := make([]string, len(statuses))
+	for i, status := range statuses {
+		s[i] = status.Name
+	}
+	sort.Strings(s)
+	return strings.Join(s, ", ")
+}
+
+func TestListTicketStatuses(t *testing.T) {
+	_, c := setup(t)
+
+	tests := []struct {
+		name string
+		args map[string]interface{}
+		want string
+	}{
+		{"all", nil, "Active, Closed, On hold, Solved, Waiting on customer"},
+		{"name", map[string]interface{}{"filter": map[string]interface{}{"name": "solved"}}, "Solved"},
+		{"not", map[string]interface{}{"filter": map[string]interface{}{"name": map[string]interface{}{"$ne": "Active"}}}, "Closed, On hold, Solved, Waiting on customer"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var result utils.ListResult[models.TicketStatus]
+			if err := desktest.DecodeResult(desktest.Call(t, c, "list_ticket_statuses", tt.args), &result); err != nil {
+				t.Fatal(err)
+			}
+			if got := names(result.Items); got != tt.want {
+				t.Errorf("ticket statuses = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGetTicketStatus(t *testing.T) {
+	api, c := setup(t)
+	id := api.Find("ticketstatuses", "name", "Solved")
+
+	var status models.TicketStatus
+	if err := desktest.DecodeResult(desktest.Call(t, c, "get_ticket_status", map[string]interface{}{"id": strconv.Itoa(id)}), &status); err != nil {
+		t.Fatal(err)
+	}
+	if status.ID != id || status.Name != "Solved" {
+		t.Errorf("ticket status = %d %q, want %d Solved", status.ID, status.Name, id)
+	}
+}
+
+func TestCreateTicketStatus(t *testing.T) {
+	api, c := setup(t)
+
+	result := desktest.Call(t, c, "create_ticket_status", map[string]interface{}{"name": "Escalated"})
+	if result.IsError {
+		t.Fatal(desktest.ResultText(result))
+	}
+	if api.Find("ticketstatuses", "name", "Escalated") == 0 {
+		t.Error("ticket status was not created")
+	}
+
+	result = desktest.Call(t, c, "create_ticket_status", map[string]interface{}{"name": "Active"})
+	if code, message := utils.ResultError(result, nil); code != desk.ErrValidation {
+		t.Errorf("code of duplicate = %q, want %q: %s", code, desk.ErrValidation, message)
+	}
+}
+
+func TestUpdateTicketStatus(t *testing.T) {
+	api, c := setup(t)
+	id := api.Find("ticketstatuses", "name", "Active")
+
+	result := desktest.Call(t, c, "update_ticket_status", map[string]interface{}{"id": strconv.Itoa(id), "display_order": 3, "color": "#00aa00"})
+	if result.IsError {
+		t.Fatal(desktest.ResultText(result))
+	}
+	stored, _ := api.Get("ticketstatuses", id)
+	if stored["displayOrder"] != float64(3) || stored["color"] != "#00aa00" || stored["name"] != "Active" {
+		t.Errorf("stored ticket status = %v, want the new display order and color and the name unchanged", stored)
+	}
+}
+
+func TestDeleteTicketStatus(t *testing.T) {
+	api, c := setup(t)
+	id := api.Find("ticketstatuses", "name", "Closed")
+
+	result := desktest.Call(t, c, "delete_ticket_status", map[string]interface{}{"id": strconv.Itoa(id), "confirm": false})
+	if code, _ := utils.ResultError(result, nil); code != utils.ErrInvalidArguments {
+		t.Errorf("code without confirm = %q, want %q", code, utils.ErrInvalidArguments)
+	}
+	result = desktest.Call(t, c, "delete_ticket_status", map[string]interface{}{"id": strconv.Itoa(id), "confirm": true})
+	if result.IsError {
+		t.Fatal(desktest.ResultText(result))
+	}
+	if _, ok := api.Get("ticketstatuses", id); ok {
+		t.Error("ticket status was not deleted")
+	}
+}
+
+func TestResolveID(t *testing.T) {
+	api := desktest.NewServer()
+	defer api.Close()
+	api.SeedReferenceData()
+	solved := api.Find("ticketstatuses", "name", "Solved")
+	h := NewTicketStatusHandler(api.Client())
+
+	for _, nameOrID := range []string{"Solved", "solved", strconv.Itoa(solved)} {
+		if id, err := h.ResolveID(context.Background(), nameOrID); err != nil || id != solved {
+			t.Errorf("ResolveID(%q) = %d, %v, want %d", nameOrID, id, err, solved)
+		}
+	}
+	if id, err := h.ResolveID(context.Background(), "Escalated"); err == nil {
+		t.Errorf("ResolveID of an unknown status = %d, want an error", id)
+	}
+}
+
+func TestTicketStatusErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		tool   string
+		args   map[string]interface{}
+		method string
+		path   string
+		status int
+		want   string
+	}{
+		{"list server error", "list_ticket_statuses", nil, http.MethodGet, "ticketstatuses.json", http.StatusInternalServerError, desk.ErrServer},
+		{"get missing", "get_ticket_status", map[string]interface{}{"id": "999"}, "", "", 0, desk.ErrNotFound},
+		{"create unauthorized", "create_ticket_status", map[string]interface{}{"name": "Escalated"}, http.MethodPost, "ticketstatuses.json", http.StatusUnauthorized, desk.ErrUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			api, c := setup(t)
+			if tt.status != 0 {
+				api.Fail(tt.method, tt.path, tt.status, 1)
+			}
+
+			result := desktest.Call(t, c, tt.tool, tt.args)
+			code, message := utils.ResultError(result, nil)
+			if code != tt.want {
+				t.Errorf("code = %q, want %q: %s", code, tt.want, message)
+			}
+		})
+	}
+}
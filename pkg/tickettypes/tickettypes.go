@@ -5,15 +5,23 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/url"
-	"strconv"
+	"strings"
 
 	"github.com/mark3labs/mcp-go/mcp"
 	"github.com/mark3labs/mcp-go/server"
 	"github.com/ready4god2513/deskmcp/pkg/desk"
+	"github.com/ready4god2513/deskmcp/pkg/filter"
 	"github.com/ready4god2513/deskmcp/pkg/utils"
 	"github.com/ready4god2513/desksdkgo/models"
 )
 
+// ticketTypeFilter lists the fields ticket types can be filtered by
+var ticketTypeFilter = filter.NewSchema("ticket types",
+	filter.Text("name"),
+	filter.Time("createdAt", "created_at"),
+	filter.Time("updatedAt", "updated_at"),
+)
+
 type TicketTypeHandler struct {
 	deskClient *desk.Client
 }
@@ -24,15 +32,23 @@ func NewTicketTypeHandler(deskClient *desk.Client) *TicketTypeHandler {
 	}
 }
 
+// client returns the Desk client to use for the request. Over HTTP each
+// session may supply its own credentials, otherwise the handler's client is used.
+func (h *TicketTypeHandler) client(ctx context.Context) *desk.Client {
+	return desk.ClientFromContext(ctx, h.deskClient)
+}
+
 func (h *TicketTypeHandler) RegisterTools(s *server.MCPServer) {
 	// List ticket types
-	s.AddTool(mcp.NewTool("list_ticket_types",
+	utils.AddTool(s, mcp.NewTool("list_ticket_types",
 		mcp.WithDescription("List all ticket types"),
 		mcp.WithObject("filter",
 			mcp.Description(`Optional filter for ticket types. Available fields:
 - name: Filter by ticket type name
 - created_at: Filter by creation date
-- updated_at: Filter by last update date`),
+- updated_at: Filter by last update date
+
+Supports the $eq, $ne, $lt, $lte, $gt, $gte, $in, $nin, $and and $or operators described on list_tickets.`),
 		),
 		mcp.WithString("orderBy",
 			mcp.Description("Order by field"),
@@ -51,10 +67,11 @@ func (h *TicketTypeHandler) RegisterTools(s *server.MCPServer) {
 			mcp.Min(1),
 			mcp.Max(100),
 		),
+		utils.WithListOptions(),
 	), h.listTicketTypes)
 
 	// Get ticket type
-	s.AddTool(mcp.NewTool("get_ticket_type",
+	utils.AddTool(s, mcp.NewTool("get_ticket_type",
 		mcp.WithDescription("Get a specific ticket type by ID"),
 		mcp.WithString("id",
 			mcp.Required(),
@@ -63,30 +80,66 @@ func (h *TicketTypeHandler) RegisterTools(s *server.MCPServer) {
 	), h.getTicketType)
 
 	// Create ticket type
-	s.AddTool(mcp.NewTool("create_ticket_type",
+	utils.AddTool(s, mcp.NewTool("create_ticket_type",
 		mcp.WithDescription("Create a new ticket type"),
 		mcp.WithString("name",
 			mcp.Required(),
 			mcp.Description("Ticket type name"),
 		),
 	), h.createTicketType)
+
+	// Update ticket type
+	utils.AddTool(s, mcp.NewTool("update_ticket_type",
+		mcp.WithDescription("Update an existing ticket type. Only the provided fields are changed."),
+		mcp.WithString("id",
+			mcp.Required(),
+			mcp.Description("Ticket type ID"),
+		),
+		mcp.WithString("name",
+			mcp.Description("Ticket type name"),
+		),
+		mcp.WithNumber("display_order",
+			mcp.Description("Position of the ticket type in lists"),
+		),
+	), h.updateTicketType)
+
+	// Delete ticket type
+	utils.AddTool(s, mcp.NewTool("delete_ticket_type",
+		mcp.WithDescription("Delete a ticket type. This cannot be undone."),
+		mcp.WithString("id",
+			mcp.Required(),
+			mcp.Description("Ticket type ID"),
+		),
+		mcp.WithBoolean("confirm",
+			mcp.Required(),
+			mcp.Description("Must be true to confirm the deletion"),
+		),
+	), h.deleteTicketType)
+}
+
+// RegisterResources registers the desk://tickettypes resource listing every ticket type
+func (h *TicketTypeHandler) RegisterResources(s *server.MCPServer) {
+	s.AddResource(mcp.NewResource("desk://tickettypes", "Ticket types",
+		mcp.WithResourceDescription("All ticket types"),
+		mcp.WithMIMEType("application/json"),
+	), h.readAll)
 }
 
 func (h *TicketTypeHandler) listTicketTypes(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
 	params := url.Values{}
-	if filterParams, ok := request.Params.Arguments["filter"].(map[string]interface{}); ok {
-		for field, value := range filterParams {
-			params.Add(field, fmt.Sprintf("%v", value))
-		}
+	if err := ticketTypeFilter.AddToParams(params, request); err != nil {
+		return utils.InvalidArgumentResult(ctx, "Failed to list ticket types", err), nil
 	}
 
-	utils.AddPaginationToParams(params, request)
+	if err := utils.AddPaginationToParams(params, request); err != nil {
+		return utils.ArgumentErrorResult(err), nil
+	}
 
-	resp, err := h.deskClient.Client.TicketTypes.List(ctx, params)
+	result, err := utils.ListPages(ctx, request, "tickettypes", params, h.listPage)
 	if err != nil {
-		return mcp.NewToolResultError(fmt.Sprintf("Failed to list ticket types: %v", err)), nil
+		return utils.ErrorResult(ctx, "Failed to list ticket types", err), nil
 	}
-	data, err := json.Marshal(resp.TicketTypes)
+	data, err := json.Marshal(result)
 	if err != nil {
 		return mcp.NewToolResultError(fmt.Sprintf("Failed to marshal ticket types: %v", err)), nil
 	}
@@ -94,13 +147,13 @@ func (h *TicketTypeHandler) listTicketTypes(ctx context.Context, request mcp.Cal
 }
 
 func (h *TicketTypeHandler) getTicketType(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
-	id, err := strconv.Atoi(request.Params.Arguments["id"].(string))
-	if err != nil {
-		return mcp.NewToolResultError(fmt.Sprintf("Invalid ticket type ID: %v", err)), nil
+	var args utils.IDArguments
+	if err := utils.BindArguments(request, &args); err != nil {
+		return utils.ArgumentErrorResult(err), nil
 	}
-	resp, err := h.deskClient.Client.TicketTypes.Get(ctx, id)
+	resp, err := h.client(ctx).Client.TicketTypes.Get(ctx, args.ID)
 	if err != nil {
-		return mcp.NewToolResultError(fmt.Sprintf("Failed to get ticket type: %v", err)), nil
+		return utils.ErrorResult(ctx, "Failed to get ticket type", err), nil
 	}
 	data, err := json.Marshal(resp.TicketType)
 	if err != nil {
@@ -110,12 +163,18 @@ func (h *TicketTypeHandler) getTicketType(ctx context.Context, request mcp.CallT
 }
 
 func (h *TicketTypeHandler) createTicketType(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	var args struct {
+		Name string `arg:"name"`
+	}
+	if err := utils.BindArguments(request, &args); err != nil {
+		return utils.ArgumentErrorResult(err), nil
+	}
 	ticketType := &models.TicketType{
-		Name: request.Params.Arguments["name"].(string),
+		Name: args.Name,
 	}
-	resp, err := h.deskClient.Client.TicketTypes.Create(ctx, ticketType)
+	resp, err := h.client(ctx).Client.TicketTypes.Create(ctx, ticketType)
 	if err != nil {
-		return mcp.NewToolResultError(fmt.Sprintf("Failed to create ticket type: %v", err)), nil
+		return utils.ErrorResult(ctx, "Failed to create ticket type", err), nil
 	}
 	data, err := json.Marshal(resp.TicketType)
 	if err != nil {
@@ -123,3 +182,78 @@ func (h *TicketTypeHandler) createTicketType(ctx context.Context, request mcp.Ca
 	}
 	return mcp.NewToolResultText(string(data)), nil
 }
+
+func (h *TicketTypeHandler) updateTicketType(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	var args struct {
+		ID           int     `arg:"id"`
+		Name         *string `arg:"name" field:"name"`
+		DisplayOrder *int    `arg:"display_order" field:"displayOrder"`
+	}
+	if err := utils.BindArguments(request, &args); err != nil {
+		return utils.ArgumentErrorResult(err), nil
+	}
+	fields := utils.ChangedFields(&args)
+	if len(fields) == 0 {
+		return mcp.NewToolResultError("No fields to update were provided"), nil
+	}
+
+	var resp models.TicketTypeResponse
+	if err := h.client(ctx).Update(ctx, "tickettypes", "tickettype", args.ID, fields, &resp); err != nil {
+		return utils.ErrorResult(ctx, "Failed to update ticket type", err), nil
+	}
+	data, err := json.Marshal(resp.TicketType)
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Failed to marshal ticket type: %v", err)), nil
+	}
+	return mcp.NewToolResultText(string(data)), nil
+}
+
+func (h *TicketTypeHandler) deleteTicketType(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	var args utils.DeleteArguments
+	if err := utils.BindArguments(request, &args); err != nil {
+		return utils.ArgumentErrorResult(err), nil
+	}
+	if !args.Confirm {
+		return utils.InvalidArgumentResult(ctx, fmt.Sprintf("Not deleting ticket type %d", args.ID), &utils.ArgumentError{Argument: "confirm", Message: "must be true to delete it"}), nil
+	}
+	if err := h.client(ctx).Delete(ctx, "tickettypes", args.ID); err != nil {
+		return utils.ErrorResult(ctx, "Failed to delete ticket type", err), nil
+	}
+	return mcp.NewToolResultText(fmt.Sprintf("Ticket type %d deleted", args.ID)), nil
+}
+
+// ResolveID returns the ID of the ticket type identified by nameOrID, which is
+// either a numeric ID or a ticket type name (matched case-insensitively)
+func (h *TicketTypeHandler) ResolveID(ctx context.Context, nameOrID string) (int, error) {
+	all, err := h.ListAll(ctx)
+	if err != nil {
+		return 0, err
+	}
+	if id, ok := utils.ResolveName(nameOrID, all, func(r models.TicketStatus, name string) bool {
+		return strings.EqualFold(r.Name, name)
+	}, func(r models.TicketStatus) int { return r.ID }); ok {
+		return id, nil
+	}
+	return 0, fmt.Errorf("no ticket type named %q", nameOrID)
+}
+
+// ListAll returns every ticket type
+func (h *TicketTypeHandler) ListAll(ctx context.Context) ([]models.TicketStatus, error) {
+	return utils.FetchAll(ctx, url.Values{}, h.listPage)
+}
+
+func (h *TicketTypeHandler) listPage(ctx context.Context, params url.Values) ([]models.TicketStatus, models.Pagination, error) {
+	resp, err := h.client(ctx).Client.TicketTypes.List(ctx, params)
+	if err != nil {
+		return nil, models.Pagination{}, err
+	}
+	return resp.TicketTypes, resp.Pagination, nil
+}
+
+func (h *TicketTypeHandler) readAll(ctx context.Context, request mcp.ReadResourceRequest) ([]mcp.ResourceContents, error) {
+	all, err := h.ListAll(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list ticket types: %w", err)
+	}
+	return utils.JSONResource(request.Params.URI, all)
+}
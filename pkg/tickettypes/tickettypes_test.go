@@ -0,0 +1,171 @@
+package tickettypes
+
+import (
+	"context"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/mark3labs/mcp-go/client"
+	"github.com/mark3labs/mcp-go/server"
+	"github.com/ready4god2513/deskmcp/pkg/desk"
+	"github.com/ready4god2513/deskmcp/pkg/desktest"
+	"github.com/ready4god2513/deskmcp/pkg/utils"
+	"github.com/ready4god2513/desksdkgo/models"
+)
+
+// setup starts a fake Desk site with its reference data and returns it with
+// an MCP client of a server with the ticket type tools
+func setup(t *testing.T) (*desktest.Server, *client.Client) {
+	t.Helper()
+	api, c := desktest.Setup(t, func(s *server.MCPServer, deskClient *desk.Client) {
+		NewTicketTypeHandler(deskClient).RegisterTools(s)
+	})
+	api.SeedReferenceData()
+	return api, c
+}
+
+// names returns the names of ticket types in alphabetical order
+func names(types []models.TicketType) string {
+	s := make([]string, len(types))
+	for i, ticketType := range types {
+		s[i] = ticketType.Name
+	}
+	sort.Strings(s)
+	return strings.Join(s, ", ")
+}
+
+func TestListTicketTypes(t *testing.T) {
+	_, c := setup(t)
+
+	tests := []struct {
+		name string
+		args map[string]interface{}
+		want string
+	}{
+		{"all", nil, "Feature request, Problem, Question"},
+		{"name", map[string]interface{}{"filter": map[string]interface{}{"name": "problem"}}, "Problem"},
+		{"not", map[string]interface{}{"filter": map[string]interface{}{"name": map[string]interface{}{"$ne": "Question"}}}, "Feature request, Problem"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var result utils.ListResult[models.TicketType]
+			if err := desktest.DecodeResult(desktest.Call(t, c, "list_ticket_types", tt.args), &result); err != nil {
+				t.Fatal(err)
+			}
+			if got := names(result.Items); got != tt.want {
+				t.Errorf("ticket types = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGetTicketType(t *testing.T) {
+	api, c := setup(t)
+	id := api.Find("tickettypes", "name", "Problem")
+
+	var ticketType models.TicketType
+	if err := desktest.DecodeResult(desktest.Call(t, c, "get_ticket_type", map[string]interface{}{"id": strconv.Itoa(id)}), &ticketType); err != nil {
+		t.Fatal(err)
+	}
+	if ticketType.ID != id || ticketType.Name != "Problem" {
+		t.Errorf("ticket type = %d %q, want %d Problem", ticketType.ID, ticketType.Name, id)
+	}
+}
+
+func TestCreateTicketType(t *testing.T) {
+	api, c := setup(t)
+
+	result := desktest.Call(t, c, "create_ticket_type", map[string]interface{}{"name": "Incident"})
+	if result.IsError {
+		t.Fatal(desktest.ResultText(result))
+	}
+	if api.Find("tickettypes", "name", "Incident") == 0 {
+		t.Error("ticket type was not created")
+	}
+
+	result = desktest.Call(t, c, "create_ticket_type", map[string]interface{}{"name": "Question"})
+	if code, message := utils.ResultError(result, nil); code != desk.ErrValidation {
+		t.Errorf("code of duplicate = %q, want %q: %s", code, desk.ErrValidation, message)
+	}
+}
+
+func TestUpdateTicketType(t *testing.T) {
+	api, c := setup(t)
+	id := api.Find("tickettypes", "name", "Question")
+
+	result := desktest.Call(t, c, "update_ticket_type", map[string]interface{}{"id": strconv.Itoa(id), "display_order": 3})
+	if result.IsError {
+		t.Fatal(desktest.ResultText(result))
+	}
+	stored, _ := api.Get("tickettypes", id)
+	if stored["displayOrder"] != float64(3) || stored["name"] != "Question" {
+		t.Errorf("stored ticket type = %v, want the new display order and the name unchanged", stored)
+	}
+}
+
+func TestDeleteTicketType(t *testing.T) {
+	api, c := setup(t)
+	id := api.Find("tickettypes", "name", "Feature request")
+
+	result := desktest.Call(t, c, "delete_ticket_type", map[string]interface{}{"id": strconv.Itoa(id), "confirm": false})
+	if code, _ := utils.ResultError(result, nil); code != utils.ErrInvalidArguments {
+		t.Errorf("code without confirm = %q, want %q", code, utils.ErrInvalidArguments)
+	}
+	result = desktest.Call(t, c, "delete_ticket_type", map[string]interface{}{"id": strconv.Itoa(id), "confirm": true})
+	if result.IsError {
+		t.Fatal(desktest.ResultText(result))
+	}
+	if _, ok := api.Get("tickettypes", id); ok {
+		t.Error("ticket type was not deleted")
+	}
+}
+
+func TestResolveID(t *testing.T) {
+	api := desktest.NewServer()
+	defer api.Close()
+	api.SeedReferenceData()
+	problem := api.Find("tickettypes", "name", "Problem")
+	h := NewTicketTypeHandler(api.Client())
+
+	for _, nameOrID := range []string{"Problem", "problem", strconv.Itoa(problem)} {
+		if id, err := h.ResolveID(context.Background(), nameOrID); err != nil || id != problem {
+			t.Errorf("ResolveID(%q) = %d, %v, want %d", nameOrID, id, err, problem)
+		}
+	}
+	if id, err := h.ResolveID(context.Background(), "Incident"); err == nil {
+		t.Errorf("ResolveID of an unknown type = %d, want an error", id)
+	}
+}
+
+func TestTicketTypeErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		tool   string
+		args   map[string]interface{}
+		method string
+		path   string
+		status int
+		want   string
+	}{
+		{"list server error", "list_ticket_types", nil, http.MethodGet, "tickettypes.json", http.StatusInternalServerError, desk.ErrServer},
+		{"get missing", "get_ticket_type", map[string]interface{}{"id": "999"}, "", "", 0, desk.ErrNotFound},
+		{"create unauthorized", "create_ticket_type", map[string]interface{}{"name": "Incident"}, http.MethodPost, "tickettypes.json", http.StatusUnauthorized, desk.ErrUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			api, c := setup(t)
+			if tt.status != 0 {
+				api.Fail(tt.method, tt.path, tt.status, 1)
+			}
+
+			result := desktest.Call(t, c, tt.tool, tt.args)
+			code, message := utils.ResultError(result, nil)
+			if code != tt.want {
+				t.Errorf("code = %q, want %q: %s", code, tt.want, message)
+			}
+		})
+	}
+}
@@ -0,0 +1,126 @@
+// Package toolfilter restricts which tools a server exposes.
+package toolfilter
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// readOnlyPrefixes are the name prefixes of tools that never modify data
+var readOnlyPrefixes = []string{"list_", "get_", "count_", "watch_", "unwatch_", "cache_"}
+
+// Filter decides which tools are exposed
+type Filter struct {
+	// ReadOnly only exposes tools that never modify data
+	ReadOnly bool
+	// Enable lists glob patterns of the tools to expose. All tools are
+	// exposed when it is empty.
+	Enable []string
+	// Disable lists glob patterns of the tools to hide. It takes precedence
+	// over Enable.
+	Disable []string
+}
+
+// ParsePatterns splits a comma separated list of glob patterns
+func ParsePatterns(list string) ([]string, error) {
+	var patterns []string
+	for _, p := range strings.Split(list, ",") {
+		p = strings.TrimSpace(p)
+		if p == "" {
+			continue
+		}
+		if _, err := path.Match(p, ""); err != nil {
+			return nil, fmt.Errorf("invalid tool pattern %q: %w", p, err)
+		}
+		patterns = append(patterns, p)
+	}
+	return patterns, nil
+}
+
+// IsReadOnly reports whether the named tool never modifies data
+func IsReadOnly(name string) bool {
+	for _, prefix := range readOnlyPrefixes {
+		if strings.HasPrefix(name, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// Allows reports whether the named tool is exposed
+func (f Filter) Allows(name string) bool {
+	if f.ReadOnly && !IsReadOnly(name) {
+		return false
+	}
+	if len(f.Enable) > 0 && !matchAny(f.Enable, name) {
+		return false
+	}
+	return !matchAny(f.Disable, name)
+}
+
+// Apply removes the tools the filter does not allow from the server and
+// returns their names
+func (f Filter) Apply(s *server.MCPServer) ([]string, error) {
+	names, err := toolNames(s)
+	if err != nil {
+		return nil, err
+	}
+
+	var removed []string
+	for _, name := range names {
+		if !f.Allows(name) {
+			removed = append(removed, name)
+		}
+	}
+	if len(removed) > 0 {
+		s.DeleteTools(removed...)
+	}
+	return removed, nil
+}
+
+// toolNames returns the names of the tools registered on the server. The
+// server does not expose its tools directly, so they are listed through the
+// protocol.
+func toolNames(s *server.MCPServer) ([]string, error) {
+	request := mcp.JSONRPCRequest{
+		JSONRPC: mcp.JSONRPC_VERSION,
+		ID:      1,
+		Request: mcp.Request{Method: string(mcp.MethodToolsList)},
+	}
+	message, err := json.Marshal(request)
+	if err != nil {
+		return nil, err
+	}
+
+	response, ok := s.HandleMessage(context.Background(), message).(mcp.JSONRPCResponse)
+	if !ok {
+		return nil, fmt.Errorf("failed to list tools")
+	}
+	result, ok := response.Result.(mcp.ListToolsResult)
+	if !ok {
+		return nil, fmt.Errorf("unexpected tools/list result %T", response.Result)
+	}
+
+	names := make([]string, 0, len(result.Tools))
+	for _, tool := range result.Tools {
+		names = append(names, tool.Name)
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+func matchAny(patterns []string, name string) bool {
+	for _, p := range patterns {
+		if ok, _ := path.Match(p, name); ok {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,118 @@
+// Package tracing traces tool calls and the Desk API requests they make
+// with OpenTelemetry.
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"github.com/ready4god2513/deskmcp/pkg/utils"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// tracerName identifies the spans of tool calls
+const tracerName = "github.com/ready4god2513/deskmcp/pkg/tracing"
+
+// Span attributes of tool calls
+const (
+	methodKey  = attribute.Key("mcp.method.name")
+	toolKey    = attribute.Key("gen_ai.tool.name")
+	sessionKey = attribute.Key("mcp.session.id")
+	siteKey    = attribute.Key("desk.site")
+)
+
+// Setup installs the global tracer provider and propagator. exporter is
+// "otlp" to send spans to an OTLP/HTTP collector configured with the
+// standard OTEL_EXPORTER_OTLP_* environment variables, "console" to write
+// them to w, or "none" to drop them. The returned function flushes the
+// spans that were not exported yet and stops the exporter.
+func Setup(ctx context.Context, exporter, version string, w io.Writer) (func(context.Context) error, error) {
+	var spanExporter sdktrace.SpanExporter
+	var err error
+	switch exporter {
+	case "", "none":
+		return func(context.Context) error { return nil }, nil
+	case "otlp":
+		spanExporter, err = otlptracehttp.New(ctx)
+	case "console":
+		spanExporter, err = stdouttrace.New(stdouttrace.WithWriter(w))
+	default:
+		return nil, fmt.Errorf("unknown trace exporter %q, expected none, console or otlp", exporter)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// OTEL_SERVICE_NAME and OTEL_RESOURCE_ATTRIBUTES override the defaults
+	res, err := resource.New(ctx,
+		resource.WithAttributes(semconv.ServiceName("deskmcp"), semconv.ServiceVersion(version)),
+		resource.WithTelemetrySDK(),
+		resource.WithFromEnv(),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(spanExporter),
+		sdktrace.WithResource(res),
+	)
+	otel.SetTracerProvider(provider)
+	otel.SetErrorHandler(otel.ErrorHandlerFunc(func(err error) {
+		slog.Warn("failed to export traces", "error", err)
+	}))
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+	return provider.Shutdown, nil
+}
+
+// ContextFunc continues the trace of the traceparent header of an HTTP
+// request, so that the spans of its tool calls join the trace of the client
+func ContextFunc(ctx context.Context, r *http.Request) context.Context {
+	return otel.GetTextMapPropagator().Extract(ctx, propagation.HeaderCarrier(r.Header))
+}
+
+// ToolCalls returns a middleware that makes a span of every tool call. The
+// Desk requests of the call are its children.
+func ToolCalls() server.ToolHandlerMiddleware {
+	return func(next server.ToolHandlerFunc) server.ToolHandlerFunc {
+		return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+			name := request.Params.Name
+			attrs := []attribute.KeyValue{
+				methodKey.String(string(mcp.MethodToolsCall)),
+				toolKey.String(name),
+			}
+			if site, _ := request.Params.Arguments["site"].(string); site != "" {
+				attrs = append(attrs, siteKey.String(site))
+			}
+			if cs := server.ClientSessionFromContext(ctx); cs != nil && cs.SessionID() != "" {
+				attrs = append(attrs, sessionKey.String(cs.SessionID()))
+			}
+			ctx, span := otel.Tracer(tracerName).Start(ctx, string(mcp.MethodToolsCall)+" "+name,
+				trace.WithSpanKind(trace.SpanKindServer),
+				trace.WithAttributes(attrs...),
+			)
+			defer span.End()
+
+			result, err := next(ctx, request)
+			if code, message := utils.ResultError(result, err); code != "" {
+				span.SetAttributes(semconv.ErrorTypeKey.String(code))
+				span.SetStatus(codes.Error, message)
+			}
+			return result, err
+		}
+	}
+}
@@ -0,0 +1,150 @@
+package tracing
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/ready4god2513/deskmcp/pkg/desk"
+	"github.com/ready4god2513/deskmcp/pkg/utils"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// recordSpans installs a tracer provider and propagator that record the
+// spans ended during a test
+func recordSpans(t *testing.T) *tracetest.SpanRecorder {
+	recorder := tracetest.NewSpanRecorder()
+	provider, propagator := otel.GetTracerProvider(), otel.GetTextMapPropagator()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	t.Cleanup(func() {
+		otel.SetTracerProvider(provider)
+		otel.SetTextMapPropagator(propagator)
+	})
+	return recorder
+}
+
+func callTool(ctx context.Context, name string, args map[string]interface{}, handler func(context.Context) (*mcp.CallToolResult, error)) (*mcp.CallToolResult, error) {
+	var request mcp.CallToolRequest
+	request.Params.Name = name
+	request.Params.Arguments = args
+	return ToolCalls()(func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		return handler(ctx)
+	})(ctx, request)
+}
+
+func TestToolCalls(t *testing.T) {
+	recorder := recordSpans(t)
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{}`))
+	}))
+	defer api.Close()
+	client := desk.NewClient(api.URL, "token", desk.WithRetry(desk.RetryConfig{}))
+
+	// A tool call continues the trace of the traceparent header of its HTTP
+	// request
+	const traceID = "4bf92f3577b34da6a3ce929d0e0e4736"
+	r := httptest.NewRequest(http.MethodPost, "/mcp", nil)
+	r.Header.Set("traceparent", "00-"+traceID+"-00f067aa0ba902b7-01")
+	ctx := ContextFunc(context.Background(), r)
+
+	_, err := callTool(ctx, "get_ticket", map[string]interface{}{"id": "42", "site": "sandbox"}, func(ctx context.Context) (*mcp.CallToolResult, error) {
+		if err := client.Do(ctx, http.MethodGet, "tickets/42.json", nil, nil, nil); err != nil {
+			return nil, err
+		}
+		return mcp.NewToolResultText("{}"), nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	spans := recorder.Ended()
+	if len(spans) != 2 {
+		t.Fatalf("%d spans, want the tool call and its Desk request", len(spans))
+	}
+	request, call := spans[0], spans[1]
+	if call.Name() != "tools/call get_ticket" || call.SpanKind() != trace.SpanKindServer || call.Status().Code != codes.Unset {
+		t.Errorf("tool call span = %s of kind %s with status %v", call.Name(), call.SpanKind(), call.Status())
+	}
+	if call.SpanContext().TraceID().String() != traceID || call.Parent().SpanID().String() != "00f067aa0ba902b7" {
+		t.Errorf("tool call span is in trace %s, want the trace of the traceparent header", call.SpanContext().TraceID())
+	}
+	attrs := make(map[string]string)
+	for _, kv := range call.Attributes() {
+		attrs[string(kv.Key)] = kv.Value.Emit()
+	}
+	if attrs["mcp.method.name"] != "tools/call" || attrs["gen_ai.tool.name"] != "get_ticket" || attrs["desk.site"] != "sandbox" {
+		t.Errorf("attributes = %v, want the method, tool and site", attrs)
+	}
+	if request.Name() != "GET tickets/{id}.json" || request.Parent().SpanID() != call.SpanContext().SpanID() {
+		t.Errorf("request span %s is not a child of the tool call", request.Name())
+	}
+}
+
+func TestToolCallsError(t *testing.T) {
+	recorder := recordSpans(t)
+
+	result, _ := callTool(context.Background(), "delete_ticket", nil, func(ctx context.Context) (*mcp.CallToolResult, error) {
+		return utils.ErrorResult(ctx, "Failed to delete ticket", &desk.APIError{StatusCode: http.StatusNotFound}), nil
+	})
+	if !result.IsError {
+		t.Fatal("result is not an error")
+	}
+
+	spans := recorder.Ended()
+	if len(spans) != 1 {
+		t.Fatalf("%d spans, want 1", len(spans))
+	}
+	span := spans[0]
+	var errorType string
+	for _, kv := range span.Attributes() {
+		if kv.Key == "error.type" {
+			errorType = kv.Value.Emit()
+		}
+	}
+	if span.Status().Code != codes.Error || errorType != desk.ErrNotFound {
+		t.Errorf("status = %v, error type = %q, want an error of type %s", span.Status(), errorType, desk.ErrNotFound)
+	}
+}
+
+func TestSetup(t *testing.T) {
+	provider, propagator := otel.GetTracerProvider(), otel.GetTextMapPropagator()
+	t.Cleanup(func() {
+		otel.SetTracerProvider(provider)
+		otel.SetTextMapPropagator(propagator)
+	})
+
+	if _, err := Setup(context.Background(), "jaeger", "test", nil); err == nil || !strings.Contains(err.Error(), "unknown trace exporter") {
+		t.Errorf("error = %v, want an unknown exporter", err)
+	}
+	shutdown, err := Setup(context.Background(), "none", "test", nil)
+	if err != nil || shutdown(context.Background()) != nil {
+		t.Errorf("Setup(none) = %v, want a no-op", err)
+	}
+
+	var out bytes.Buffer
+	shutdown, err = Setup(context.Background(), "console", "1.2.3", &out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, span := otel.Tracer("test").Start(context.Background(), "tools/call list_tickets")
+	span.End()
+	// The batched span is written when the exporter is shut down
+	if err := shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"tools/call list_tickets", "deskmcp", "1.2.3"} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("console output does not contain %q:\n%s", want, out.String())
+		}
+	}
+}
@@ -0,0 +1,120 @@
+package transport
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"sync"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// StdioSessionID is the ID of the single session of the stdio transport
+const StdioSessionID = "stdio"
+
+// MessageFilter handles messages the MCP server has no handler for, such as
+// resources/subscribe. It reports whether it handled the message; the
+// messages it does not handle are passed on to the MCP server.
+type MessageFilter func(ctx context.Context, sessionID string, message json.RawMessage) (mcp.JSONRPCMessage, bool)
+
+// ServeStdio serves the MCP server over stdin and stdout, handing every
+// message to filter before the server sees it
+func ServeStdio(ctx context.Context, s *server.MCPServer, stdin io.Reader, stdout io.Writer, filter MessageFilter) error {
+	out := &lockedWriter{w: stdout}
+	in, pw := io.Pipe()
+	defer in.Close()
+
+	go func() {
+		reader := bufio.NewReader(stdin)
+		for {
+			line, err := reader.ReadBytes('\n')
+			if message := bytes.TrimSpace(line); len(message) > 0 {
+				if response, ok := filter(ctx, StdioSessionID, message); ok {
+					writeLine(out, response)
+				} else if _, werr := pw.Write(line); werr != nil {
+					return
+				}
+			}
+			if err != nil {
+				pw.CloseWithError(err)
+				return
+			}
+		}
+	}()
+
+	return server.NewStdioServer(s).Listen(ctx, in, out)
+}
+
+// FilterSSEMessages hands the messages posted to the message endpoint of the
+// SSE server to filter. Like every other response of the SSE transport, the
+// responses of handled messages are sent over the session's event stream.
+func FilterSSEMessages(s *server.MCPServer, sse *server.SSEServer, filter MessageFilter, contextFunc HTTPContextFunc) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		sessionID := r.URL.Query().Get("sessionId")
+		if r.Method != http.MethodPost || r.URL.Path != sse.CompleteMessagePath() || sessionID == "" {
+			sse.ServeHTTP(w, r)
+			return
+		}
+
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			writeJSONRPCError(w, http.StatusBadRequest, mcp.PARSE_ERROR, "Failed to read request body")
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+
+		ctx := s.WithContext(r.Context(), sessionRef(sessionID))
+		if contextFunc != nil {
+			ctx = contextFunc(ctx, r)
+		}
+		response, ok := filter(ctx, sessionID, json.RawMessage(bytes.TrimSpace(body)))
+		if !ok {
+			sse.ServeHTTP(w, r)
+			return
+		}
+		if err := sse.SendEventToSession(sessionID, response); err != nil {
+			writeJSONRPCError(w, http.StatusBadRequest, mcp.INVALID_PARAMS, "Invalid session ID")
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusAccepted)
+		_ = json.NewEncoder(w).Encode(response)
+	})
+}
+
+// sessionRef identifies the session of a filtered SSE message in its
+// context. The SSE server keeps its sessions to itself, so the reference only
+// carries the session ID.
+type sessionRef string
+
+func (r sessionRef) SessionID() string { return string(r) }
+
+func (r sessionRef) NotificationChannel() chan<- mcp.JSONRPCNotification { return nil }
+
+func (r sessionRef) Initialize() {}
+
+func (r sessionRef) Initialized() bool { return true }
+
+// lockedWriter serializes the writes of the stdio server and the filter
+type lockedWriter struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+func (l *lockedWriter) Write(p []byte) (int, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.w.Write(p)
+}
+
+func writeLine(w io.Writer, message mcp.JSONRPCMessage) {
+	data, err := json.Marshal(message)
+	if err != nil {
+		return
+	}
+	_, _ = w.Write(append(data, '\n'))
+}
@@ -0,0 +1,65 @@
+package transport
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"time"
+)
+
+// ShutdownTimeout is how long in-flight requests are given to complete when
+// the server shuts down
+const ShutdownTimeout = 10 * time.Second
+
+// ListenAndServe serves handler on addr until ctx is cancelled and then shuts
+// the server down gracefully. Long-lived GET event streams are closed when
+// the shutdown starts so that they do not hold it up, while in-flight POST
+// requests are allowed to complete.
+func ListenAndServe(ctx context.Context, addr string, handler http.Handler) error {
+	streams, closeStreams := context.WithCancel(context.Background())
+	defer closeStreams()
+
+	srv := &http.Server{
+		Addr:              addr,
+		Handler:           closeStreamsOnShutdown(streams, handler),
+		ReadHeaderTimeout: 10 * time.Second,
+		BaseContext:       func(net.Listener) context.Context { return context.WithoutCancel(ctx) },
+	}
+	srv.RegisterOnShutdown(closeStreams)
+
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- srv.ListenAndServe()
+	}()
+
+	select {
+	case err := <-errCh:
+		return err
+	case <-ctx.Done():
+	}
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), ShutdownTimeout)
+	defer cancel()
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		return err
+	}
+	if err := <-errCh; !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+func closeStreamsOnShutdown(streams context.Context, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			next.ServeHTTP(w, r)
+			return
+		}
+		ctx, cancel := context.WithCancel(r.Context())
+		defer cancel()
+		stop := context.AfterFunc(streams, cancel)
+		defer stop()
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
@@ -0,0 +1,346 @@
+// Package transport serves an MCP server over stdio and HTTP based transports.
+package transport
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// SessionIDHeader carries the session ID of the streamable HTTP transport
+const SessionIDHeader = "Mcp-Session-Id"
+
+// HTTPContextFunc adds request specific values to the context a message is
+// handled with
+type HTTPContextFunc func(ctx context.Context, r *http.Request) context.Context
+
+// StreamableHTTPServer serves an MCPServer over the streamable HTTP transport.
+// Clients POST JSON-RPC messages and receive the responses in the HTTP
+// response, and may open a GET event stream to receive server notifications.
+type StreamableHTTPServer struct {
+	server      *server.MCPServer
+	contextFunc HTTPContextFunc
+	filter      MessageFilter
+	idleTimeout time.Duration
+
+	sessions sync.Map
+	done     chan struct{}
+	once     sync.Once
+}
+
+// StreamableHTTPOption configures a StreamableHTTPServer
+type StreamableHTTPOption func(*StreamableHTTPServer)
+
+// WithHTTPContextFunc sets a function that is applied to the context of
+// every handled message
+func WithHTTPContextFunc(fn HTTPContextFunc) StreamableHTTPOption {
+	return func(s *StreamableHTTPServer) {
+		s.contextFunc = fn
+	}
+}
+
+// WithMessageFilter sets a filter that is handed every message before the
+// MCP server
+func WithMessageFilter(filter MessageFilter) StreamableHTTPOption {
+	return func(s *StreamableHTTPServer) {
+		s.filter = filter
+	}
+}
+
+// WithSessionIdleTimeout sets how long a session without requests or an open
+// event stream is kept before it is discarded
+func WithSessionIdleTimeout(timeout time.Duration) StreamableHTTPOption {
+	return func(s *StreamableHTTPServer) {
+		s.idleTimeout = timeout
+	}
+}
+
+// NewStreamableHTTPServer returns a streamable HTTP transport for the server
+func NewStreamableHTTPServer(s *server.MCPServer, opts ...StreamableHTTPOption) *StreamableHTTPServer {
+	h := &StreamableHTTPServer{
+		server:      s,
+		idleTimeout: 30 * time.Minute,
+		done:        make(chan struct{}),
+	}
+	for _, opt := range opts {
+		opt(h)
+	}
+	go h.expireSessions()
+	return h
+}
+
+// Close ends all sessions and their event streams
+func (h *StreamableHTTPServer) Close() {
+	h.once.Do(func() {
+		close(h.done)
+		h.sessions.Range(func(key, _ interface{}) bool {
+			h.closeSession(key.(string))
+			return true
+		})
+	})
+}
+
+// ServeHTTP implements http.Handler
+func (h *StreamableHTTPServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodPost:
+		h.handlePost(w, r)
+	case http.MethodGet:
+		h.handleStream(w, r)
+	case http.MethodDelete:
+		session, ok := h.lookupSession(w, r)
+		if !ok {
+			return
+		}
+		h.closeSession(session.id)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.Header().Set("Allow", "GET, POST, DELETE")
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func (h *StreamableHTTPServer) handlePost(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		writeJSONRPCError(w, http.StatusBadRequest, mcp.PARSE_ERROR, "Failed to read request body")
+		return
+	}
+
+	messages, batch, err := splitBatch(body)
+	if err != nil {
+		writeJSONRPCError(w, http.StatusBadRequest, mcp.PARSE_ERROR, "Parse error")
+		return
+	}
+
+	var session *httpSession
+	if containsInitialize(messages) {
+		session, err = h.newSession(r.Context())
+		if err != nil {
+			writeJSONRPCError(w, http.StatusInternalServerError, mcp.INTERNAL_ERROR, err.Error())
+			return
+		}
+		w.Header().Set(SessionIDHeader, session.id)
+	} else {
+		var ok bool
+		if session, ok = h.lookupSession(w, r); !ok {
+			return
+		}
+	}
+	session.touch()
+
+	ctx := h.server.WithContext(r.Context(), session)
+	if h.contextFunc != nil {
+		ctx = h.contextFunc(ctx, r)
+	}
+
+	responses := make([]mcp.JSONRPCMessage, 0, len(messages))
+	for _, message := range messages {
+		if h.filter != nil {
+			if response, ok := h.filter(ctx, session.id, message); ok {
+				responses = append(responses, response)
+				continue
+			}
+		}
+		if response := h.server.HandleMessage(ctx, message); response != nil {
+			responses = append(responses, response)
+		}
+	}
+
+	if len(responses) == 0 {
+		w.WriteHeader(http.StatusAccepted)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if batch {
+		_ = json.NewEncoder(w).Encode(responses)
+		return
+	}
+	_ = json.NewEncoder(w).Encode(responses[0])
+}
+
+func (h *StreamableHTTPServer) handleStream(w http.ResponseWriter, r *http.Request) {
+	session, ok := h.lookupSession(w, r)
+	if !ok {
+		return
+	}
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "Streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+
+	if !session.streaming.CompareAndSwap(false, true) {
+		http.Error(w, "An event stream is already open for this session", http.StatusConflict)
+		return
+	}
+	defer func() {
+		session.streaming.Store(false)
+		session.touch()
+	}()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	for {
+		select {
+		case notification := <-session.notifications:
+			data, err := json.Marshal(notification)
+			if err != nil {
+				continue
+			}
+			fmt.Fprintf(w, "event: message\ndata: %s\n\n", data)
+			flusher.Flush()
+		case <-session.closed:
+			return
+		case <-r.Context().Done():
+			return
+		case <-h.done:
+			return
+		}
+	}
+}
+
+func (h *StreamableHTTPServer) newSession(ctx context.Context) (*httpSession, error) {
+	id, err := newSessionID()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create session: %w", err)
+	}
+	session := &httpSession{
+		id:            id,
+		notifications: make(chan mcp.JSONRPCNotification, 100),
+		closed:        make(chan struct{}),
+	}
+	if err := h.server.RegisterSession(ctx, session); err != nil {
+		return nil, err
+	}
+	h.sessions.Store(id, session)
+	return session, nil
+}
+
+func (h *StreamableHTTPServer) lookupSession(w http.ResponseWriter, r *http.Request) (*httpSession, bool) {
+	id := r.Header.Get(SessionIDHeader)
+	if id == "" {
+		writeJSONRPCError(w, http.StatusBadRequest, mcp.INVALID_REQUEST, "Missing "+SessionIDHeader+" header")
+		return nil, false
+	}
+	session, ok := h.sessions.Load(id)
+	if !ok {
+		writeJSONRPCError(w, http.StatusNotFound, mcp.INVALID_REQUEST, "Unknown session")
+		return nil, false
+	}
+	return session.(*httpSession), true
+}
+
+func (h *StreamableHTTPServer) closeSession(id string) {
+	session, ok := h.sessions.LoadAndDelete(id)
+	if !ok {
+		return
+	}
+	close(session.(*httpSession).closed)
+	h.server.UnregisterSession(context.Background(), id)
+}
+
+// expireSessions discards sessions that have been idle for longer than the
+// idle timeout. Clients are not required to end their sessions explicitly.
+func (h *StreamableHTTPServer) expireSessions() {
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			h.sessions.Range(func(key, value interface{}) bool {
+				if value.(*httpSession).idleFor() > h.idleTimeout {
+					h.closeSession(key.(string))
+				}
+				return true
+			})
+		case <-h.done:
+			return
+		}
+	}
+}
+
+// httpSession is a client session of the streamable HTTP transport
+type httpSession struct {
+	id            string
+	notifications chan mcp.JSONRPCNotification
+	closed        chan struct{}
+	initialized   atomic.Bool
+	streaming     atomic.Bool
+	lastSeen      atomic.Int64
+}
+
+func (s *httpSession) SessionID() string { return s.id }
+
+func (s *httpSession) NotificationChannel() chan<- mcp.JSONRPCNotification {
+	return s.notifications
+}
+
+func (s *httpSession) Initialize() { s.initialized.Store(true) }
+
+func (s *httpSession) Initialized() bool { return s.initialized.Load() }
+
+func (s *httpSession) touch() { s.lastSeen.Store(time.Now().UnixNano()) }
+
+func (s *httpSession) idleFor() time.Duration {
+	if s.streaming.Load() {
+		return 0
+	}
+	return time.Since(time.Unix(0, s.lastSeen.Load()))
+}
+
+func newSessionID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// splitBatch splits a request body into its JSON-RPC messages and reports
+// whether it was a batch
+func splitBatch(body []byte) ([]json.RawMessage, bool, error) {
+	var batch []json.RawMessage
+	if err := json.Unmarshal(body, &batch); err == nil {
+		return batch, true, nil
+	}
+	var message json.RawMessage
+	if err := json.Unmarshal(body, &message); err != nil {
+		return nil, false, err
+	}
+	return []json.RawMessage{message}, false, nil
+}
+
+func containsInitialize(messages []json.RawMessage) bool {
+	for _, message := range messages {
+		var base struct {
+			Method mcp.MCPMethod `json:"method"`
+		}
+		if json.Unmarshal(message, &base) == nil && base.Method == mcp.MethodInitialize {
+			return true
+		}
+	}
+	return false
+}
+
+func writeJSONRPCError(w http.ResponseWriter, status, code int, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(mcp.NewJSONRPCError(nil, code, message, nil))
+}
@@ -6,14 +6,26 @@ import (
 	"fmt"
 	"net/url"
 	"strconv"
+	"strings"
 
 	"github.com/mark3labs/mcp-go/mcp"
 	"github.com/mark3labs/mcp-go/server"
 	"github.com/ready4god2513/deskmcp/pkg/desk"
+	"github.com/ready4god2513/deskmcp/pkg/filter"
 	"github.com/ready4god2513/deskmcp/pkg/utils"
 	"github.com/ready4god2513/desksdkgo/models"
 )
 
+// userFilter lists the fields users can be filtered by
+var userFilter = filter.NewSchema("users",
+	filter.Text("email"),
+	filter.Text("firstName", "first_name"),
+	filter.Text("lastName", "last_name"),
+	filter.Text("role"),
+	filter.Time("createdAt", "created_at"),
+	filter.Time("updatedAt", "updated_at"),
+)
+
 type UserHandler struct {
 	deskClient *desk.Client
 }
@@ -24,9 +36,15 @@ func NewUserHandler(deskClient *desk.Client) *UserHandler {
 	}
 }
 
+// client returns the Desk client to use for the request. Over HTTP each
+// session may supply its own credentials, otherwise the handler's client is used.
+func (h *UserHandler) client(ctx context.Context) *desk.Client {
+	return desk.ClientFromContext(ctx, h.deskClient)
+}
+
 func (h *UserHandler) RegisterTools(s *server.MCPServer) {
 	// List users
-	s.AddTool(mcp.NewTool("list_users",
+	utils.AddTool(s, mcp.NewTool("list_users",
 		mcp.WithDescription("List all users"),
 		mcp.WithObject("filter",
 			mcp.Description(`Optional filter for users. Available fields:
@@ -35,7 +53,9 @@ func (h *UserHandler) RegisterTools(s *server.MCPServer) {
 - last_name: Filter by last name
 - role: Filter by user role
 - created_at: Filter by creation date
-- updated_at: Filter by last update date`),
+- updated_at: Filter by last update date
+
+Supports the $eq, $ne, $lt, $lte, $gt, $gte, $in, $nin, $and and $or operators described on list_tickets.`),
 		),
 		mcp.WithString("orderBy",
 			mcp.Description("Order by field"),
@@ -54,10 +74,27 @@ func (h *UserHandler) RegisterTools(s *server.MCPServer) {
 			mcp.Min(1),
 			mcp.Max(100),
 		),
+		utils.WithListOptions(),
 	), h.listUsers)
 
+	// Count users
+	utils.AddTool(s, mcp.NewTool("count_users",
+		mcp.WithDescription("Count all filtered users"),
+		mcp.WithObject("filter",
+			mcp.Description(`Optional filter for users. Available fields:
+- email: Filter by email address
+- first_name: Filter by first name
+- last_name: Filter by last name
+- role: Filter by user role
+- created_at: Filter by creation date
+- updated_at: Filter by last update date
+
+Supports the $eq, $ne, $lt, $lte, $gt, $gte, $in, $nin, $and and $or operators described on list_tickets.`),
+		),
+	), h.countUsers)
+
 	// Get user
-	s.AddTool(mcp.NewTool("get_user",
+	utils.AddTool(s, mcp.NewTool("get_user",
 		mcp.WithDescription("Get a specific user by ID"),
 		mcp.WithString("id",
 			mcp.Required(),
@@ -66,7 +103,7 @@ func (h *UserHandler) RegisterTools(s *server.MCPServer) {
 	), h.getUser)
 
 	// Create user
-	s.AddTool(mcp.NewTool("create_user",
+	utils.AddTool(s, mcp.NewTool("create_user",
 		mcp.WithDescription("Create a new user"),
 		mcp.WithString("first_name",
 			mcp.Required(),
@@ -81,37 +118,96 @@ func (h *UserHandler) RegisterTools(s *server.MCPServer) {
 			mcp.Description("User's email address"),
 		),
 	), h.createUser)
+
+	// Update user
+	utils.AddTool(s, mcp.NewTool("update_user",
+		mcp.WithDescription("Update an existing user. Only the provided fields are changed."),
+		mcp.WithString("id",
+			mcp.Required(),
+			mcp.Description("User ID"),
+		),
+		mcp.WithString("first_name",
+			mcp.Description("User's first name"),
+		),
+		mcp.WithString("last_name",
+			mcp.Description("User's last name"),
+		),
+		mcp.WithString("email",
+			mcp.Description("User's email address"),
+		),
+		mcp.WithString("role",
+			mcp.Description("User's role"),
+		),
+	), h.updateUser)
+
+	// Delete user
+	utils.AddTool(s, mcp.NewTool("delete_user",
+		mcp.WithDescription("Delete a user. This cannot be undone."),
+		mcp.WithString("id",
+			mcp.Required(),
+			mcp.Description("User ID"),
+		),
+		mcp.WithBoolean("confirm",
+			mcp.Required(),
+			mcp.Description("Must be true to confirm the deletion"),
+		),
+	), h.deleteUser)
+}
+
+// RegisterResources registers the desk://users/{id} resource template
+func (h *UserHandler) RegisterResources(s *server.MCPServer) {
+	s.AddResourceTemplate(mcp.NewResourceTemplate("desk://users/{id}", "User",
+		mcp.WithTemplateDescription("A user by ID"),
+		mcp.WithTemplateMIMEType("application/json"),
+	), h.readUser)
 }
 
 func (h *UserHandler) listUsers(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
 	params := url.Values{}
-	if filterParams, ok := request.Params.Arguments["filter"].(map[string]interface{}); ok {
-		for field, value := range filterParams {
-			params.Add(field, fmt.Sprintf("%v", value))
-		}
+	if err := userFilter.AddToParams(params, request); err != nil {
+		return utils.InvalidArgumentResult(ctx, "Failed to list users", err), nil
 	}
 
-	utils.AddPaginationToParams(params, request)
+	if err := utils.AddPaginationToParams(params, request); err != nil {
+		return utils.ArgumentErrorResult(err), nil
+	}
 
-	resp, err := h.deskClient.Client.Users.List(ctx, params)
+	result, err := utils.ListPages(ctx, request, "users", params, h.listPage)
 	if err != nil {
-		return mcp.NewToolResultError(fmt.Sprintf("Failed to list users: %v", err)), nil
+		return utils.ErrorResult(ctx, "Failed to list users", err), nil
 	}
-	data, err := json.Marshal(resp.Users)
+	data, err := json.Marshal(result)
 	if err != nil {
 		return mcp.NewToolResultError(fmt.Sprintf("Failed to marshal users: %v", err)), nil
 	}
 	return mcp.NewToolResultText(string(data)), nil
 }
 
-func (h *UserHandler) getUser(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
-	id, err := strconv.Atoi(request.Params.Arguments["id"].(string))
+// Count users
+func (h *UserHandler) countUsers(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	params := url.Values{}
+	if err := userFilter.AddToParams(params, request); err != nil {
+		return utils.InvalidArgumentResult(ctx, "Failed to count users", err), nil
+	}
+
+	utils.AddCountParams(params)
+
+	resp, err := h.client(ctx).Client.Users.List(ctx, params)
 	if err != nil {
-		return mcp.NewToolResultError(fmt.Sprintf("Invalid user ID: %v", err)), nil
+		return utils.ErrorResult(ctx, "Failed to count users", err), nil
 	}
-	resp, err := h.deskClient.Client.Users.Get(ctx, id)
+
+	return mcp.NewToolResultText(strconv.Itoa(resp.Pagination.Records)), nil
+}
+
+func (h *UserHandler) getUser(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	var args utils.IDArguments
+	if err := utils.BindArguments(request, &args); err != nil {
+		return utils.ArgumentErrorResult(err), nil
+	}
+	resp, err := h.client(ctx).Client.Users.Get(ctx, args.ID)
 	if err != nil {
-		return mcp.NewToolResultError(fmt.Sprintf("Failed to get user: %v", err)), nil
+		return utils.ErrorResult(ctx, "Failed to get user", err), nil
 	}
 	data, err := json.Marshal(resp.User)
 	if err != nil {
@@ -121,14 +217,49 @@ func (h *UserHandler) getUser(ctx context.Context, request mcp.CallToolRequest)
 }
 
 func (h *UserHandler) createUser(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	var args struct {
+		FirstName string `arg:"first_name"`
+		LastName  string `arg:"last_name"`
+		Email     string `arg:"email"`
+	}
+	if err := utils.BindArguments(request, &args); err != nil {
+		return utils.ArgumentErrorResult(err), nil
+	}
 	user := &models.User{
-		FirstName: request.Params.Arguments["first_name"].(string),
-		LastName:  request.Params.Arguments["last_name"].(string),
-		Email:     request.Params.Arguments["email"].(string),
+		FirstName: args.FirstName,
+		LastName:  args.LastName,
+		Email:     args.Email,
+	}
+	resp, err := h.client(ctx).Client.Users.Create(ctx, user)
+	if err != nil {
+		return utils.ErrorResult(ctx, "Failed to create user", err), nil
 	}
-	resp, err := h.deskClient.Client.Users.Create(ctx, user)
+	data, err := json.Marshal(resp.User)
 	if err != nil {
-		return mcp.NewToolResultError(fmt.Sprintf("Failed to create user: %v", err)), nil
+		return mcp.NewToolResultError(fmt.Sprintf("Failed to marshal user: %v", err)), nil
+	}
+	return mcp.NewToolResultText(string(data)), nil
+}
+
+func (h *UserHandler) updateUser(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	var args struct {
+		ID        int     `arg:"id"`
+		FirstName *string `arg:"first_name" field:"firstName"`
+		LastName  *string `arg:"last_name" field:"lastName"`
+		Email     *string `arg:"email" field:"email"`
+		Role      *string `arg:"role" field:"role"`
+	}
+	if err := utils.BindArguments(request, &args); err != nil {
+		return utils.ArgumentErrorResult(err), nil
+	}
+	fields := utils.ChangedFields(&args)
+	if len(fields) == 0 {
+		return mcp.NewToolResultError("No fields to update were provided"), nil
+	}
+
+	var resp models.UserResponse
+	if err := h.client(ctx).Update(ctx, "users", "user", args.ID, fields, &resp); err != nil {
+		return utils.ErrorResult(ctx, "Failed to update user", err), nil
 	}
 	data, err := json.Marshal(resp.User)
 	if err != nil {
@@ -136,3 +267,58 @@ func (h *UserHandler) createUser(ctx context.Context, request mcp.CallToolReques
 	}
 	return mcp.NewToolResultText(string(data)), nil
 }
+
+func (h *UserHandler) deleteUser(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	var args utils.DeleteArguments
+	if err := utils.BindArguments(request, &args); err != nil {
+		return utils.ArgumentErrorResult(err), nil
+	}
+	if !args.Confirm {
+		return utils.InvalidArgumentResult(ctx, fmt.Sprintf("Not deleting user %d", args.ID), &utils.ArgumentError{Argument: "confirm", Message: "must be true to delete it"}), nil
+	}
+	if err := h.client(ctx).Delete(ctx, "users", args.ID); err != nil {
+		return utils.ErrorResult(ctx, "Failed to delete user", err), nil
+	}
+	return mcp.NewToolResultText(fmt.Sprintf("User %d deleted", args.ID)), nil
+}
+
+// ResolveID returns the ID of the user identified by nameOrID, which is either
+// a numeric ID, an email address or a full name (matched case-insensitively)
+func (h *UserHandler) ResolveID(ctx context.Context, nameOrID string) (int, error) {
+	all, err := h.ListAll(ctx)
+	if err != nil {
+		return 0, err
+	}
+	if id, ok := utils.ResolveName(nameOrID, all, func(u models.User, name string) bool {
+		return strings.EqualFold(u.Email, name) ||
+			strings.EqualFold(strings.TrimSpace(u.FirstName+" "+u.LastName), name)
+	}, func(u models.User) int { return u.ID }); ok {
+		return id, nil
+	}
+	return 0, fmt.Errorf("no user with email or name %q", nameOrID)
+}
+
+// ListAll returns every user
+func (h *UserHandler) ListAll(ctx context.Context) ([]models.User, error) {
+	return utils.FetchAll(ctx, url.Values{}, h.listPage)
+}
+
+func (h *UserHandler) listPage(ctx context.Context, params url.Values) ([]models.User, models.Pagination, error) {
+	resp, err := h.client(ctx).Client.Users.List(ctx, params)
+	if err != nil {
+		return nil, models.Pagination{}, err
+	}
+	return resp.Users, resp.Pagination, nil
+}
+
+func (h *UserHandler) readUser(ctx context.Context, request mcp.ReadResourceRequest) ([]mcp.ResourceContents, error) {
+	id, err := utils.ResourceID(request)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := h.client(ctx).Client.Users.Get(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user %d: %w", id, err)
+	}
+	return utils.JSONResource(request.Params.URI, resp.User)
+}
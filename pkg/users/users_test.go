@@ -0,0 +1,211 @@
+package users
+
+import (
+	"context"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/mark3labs/mcp-go/client"
+	"github.com/mark3labs/mcp-go/server"
+	"github.com/ready4god2513/deskmcp/pkg/desk"
+	"github.com/ready4god2513/deskmcp/pkg/desktest"
+	"github.com/ready4god2513/deskmcp/pkg/utils"
+	"github.com/ready4god2513/desksdkgo/models"
+)
+
+// setup starts a fake Desk site and returns it with an MCP client of a
+// server with the user tools
+func setup(t *testing.T) (*desktest.Server, *client.Client) {
+	t.Helper()
+	return desktest.Setup(t, func(s *server.MCPServer, deskClient *desk.Client) {
+		NewUserHandler(deskClient).RegisterTools(s)
+	})
+}
+
+// addUsers stores an admin and two agents
+func addUsers(api *desktest.Server) {
+	api.Add("users", map[string]interface{}{"email": "alex@example.com", "firstName": "Alex", "lastName": "Admin", "role": "admin"})
+	api.Add("users", map[string]interface{}{"email": "sam@example.com", "firstName": "Sam", "lastName": "Agent", "role": "agent"})
+	api.Add("users", map[string]interface{}{"email": "kim@example.com", "firstName": "Kim", "lastName": "Agent", "role": "agent"})
+}
+
+// emails returns the email addresses of users in alphabetical order
+func emails(users []models.User) string {
+	s := make([]string, len(users))
+	for i, user := range users {
+		s[i] = user.Email
+	}
+	sort.Strings(s)
+	return strings.Join(s, ", ")
+}
+
+func TestListUsers(t *testing.T) {
+	api, c := setup(t)
+	addUsers(api)
+
+	var result utils.ListResult[models.User]
+	if err := desktest.DecodeResult(desktest.Call(t, c, "list_users", nil), &result); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := emails(result.Items), "alex@example.com, kim@example.com, sam@example.com"; got != want {
+		t.Errorf("users = %s, want %s", got, want)
+	}
+}
+
+func TestListUsersFilter(t *testing.T) {
+	api, c := setup(t)
+	addUsers(api)
+
+	tests := []struct {
+		name   string
+		filter map[string]interface{}
+		want   string
+	}{
+		{"role", map[string]interface{}{"role": "agent"}, "kim@example.com, sam@example.com"},
+		{"email", map[string]interface{}{"email": "Alex@Example.com"}, "alex@example.com"},
+		{"names", map[string]interface{}{"last_name": "Agent", "first_name": map[string]interface{}{"$ne": "Sam"}}, "kim@example.com"},
+		{"no match", map[string]interface{}{"role": "owner"}, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var result utils.ListResult[models.User]
+			if err := desktest.DecodeResult(desktest.Call(t, c, "list_users", map[string]interface{}{"filter": tt.filter}), &result); err != nil {
+				t.Fatal(err)
+			}
+			if got := emails(result.Items); got != tt.want {
+				t.Errorf("users = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCountUsers(t *testing.T) {
+	api, c := setup(t)
+	addUsers(api)
+
+	result := desktest.Call(t, c, "count_users", map[string]interface{}{"filter": map[string]interface{}{"role": "agent"}})
+	if got := desktest.ResultText(result); result.IsError || got != "2" {
+		t.Errorf("count = %s, want 2", got)
+	}
+}
+
+func TestGetUser(t *testing.T) {
+	api, c := setup(t)
+	addUsers(api)
+	id := api.Find("users", "email", "sam@example.com")
+
+	var user models.User
+	if err := desktest.DecodeResult(desktest.Call(t, c, "get_user", map[string]interface{}{"id": strconv.Itoa(id)}), &user); err != nil {
+		t.Fatal(err)
+	}
+	if user.ID != id || user.FirstName != "Sam" {
+		t.Errorf("user = %d %s, want %d Sam", user.ID, user.FirstName, id)
+	}
+}
+
+func TestCreateUser(t *testing.T) {
+	api, c := setup(t)
+
+	var user models.User
+	args := map[string]interface{}{"first_name": "Sam", "last_name": "Agent", "email": "sam@example.com"}
+	if err := desktest.DecodeResult(desktest.Call(t, c, "create_user", args), &user); err != nil {
+		t.Fatal(err)
+	}
+	if user.ID == 0 || user.Email != "sam@example.com" {
+		t.Errorf("user = %+v, want the created user", user)
+	}
+
+	// The email address is taken now
+	result := desktest.Call(t, c, "create_user", args)
+	if code, message := utils.ResultError(result, nil); code != desk.ErrValidation {
+		t.Errorf("code of duplicate = %q, want %q: %s", code, desk.ErrValidation, message)
+	}
+	if n := len(api.Records("users")); n != 1 {
+		t.Errorf("%d users stored, want 1", n)
+	}
+}
+
+func TestUpdateUser(t *testing.T) {
+	api, c := setup(t)
+	addUsers(api)
+	id := api.Find("users", "email", "sam@example.com")
+
+	var user models.User
+	if err := desktest.DecodeResult(desktest.Call(t, c, "update_user", map[string]interface{}{"id": strconv.Itoa(id), "role": "admin"}), &user); err != nil {
+		t.Fatal(err)
+	}
+	stored, _ := api.Get("users", id)
+	if stored["role"] != "admin" || stored["firstName"] != "Sam" || stored["email"] != "sam@example.com" {
+		t.Errorf("stored user = %v, want the new role and the other fields unchanged", stored)
+	}
+}
+
+func TestDeleteUser(t *testing.T) {
+	api, c := setup(t)
+	addUsers(api)
+	id := api.Find("users", "email", "kim@example.com")
+
+	result := desktest.Call(t, c, "delete_user", map[string]interface{}{"id": strconv.Itoa(id), "confirm": false})
+	if code, _ := utils.ResultError(result, nil); code != utils.ErrInvalidArguments {
+		t.Errorf("code without confirm = %q, want %q", code, utils.ErrInvalidArguments)
+	}
+	result = desktest.Call(t, c, "delete_user", map[string]interface{}{"id": strconv.Itoa(id), "confirm": true})
+	if result.IsError {
+		t.Fatal(desktest.ResultText(result))
+	}
+	if _, ok := api.Get("users", id); ok {
+		t.Error("user was not deleted")
+	}
+}
+
+func TestResolveID(t *testing.T) {
+	api := desktest.NewServer()
+	defer api.Close()
+	addUsers(api)
+	sam := api.Find("users", "email", "sam@example.com")
+	h := NewUserHandler(api.Client())
+
+	for _, nameOrID := range []string{"sam@example.com", "SAM@example.com", "Sam Agent", "sam agent", strconv.Itoa(sam)} {
+		if id, err := h.ResolveID(context.Background(), nameOrID); err != nil || id != sam {
+			t.Errorf("ResolveID(%q) = %d, %v, want %d", nameOrID, id, err, sam)
+		}
+	}
+	if id, err := h.ResolveID(context.Background(), "Sam"); err == nil {
+		t.Errorf("ResolveID of a first name = %d, want an error", id)
+	}
+}
+
+func TestUserErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		tool   string
+		args   map[string]interface{}
+		method string
+		path   string
+		status int
+		want   string
+	}{
+		{"list rate limited", "list_users", nil, http.MethodGet, "users.json", http.StatusTooManyRequests, desk.ErrRateLimited},
+		{"invalid filter", "count_users", map[string]interface{}{"filter": map[string]interface{}{"phone": "1"}}, "", "", 0, utils.ErrInvalidArguments},
+		{"get missing", "get_user", map[string]interface{}{"id": "999"}, "", "", 0, desk.ErrNotFound},
+		{"update unauthorized", "update_user", map[string]interface{}{"id": "1", "role": "admin"}, "", "users", http.StatusUnauthorized, desk.ErrUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			api, c := setup(t)
+			addUsers(api)
+			if tt.status != 0 {
+				api.Fail(tt.method, tt.path, tt.status, 1)
+			}
+
+			result := desktest.Call(t, c, tt.tool, tt.args)
+			code, message := utils.ResultError(result, nil)
+			if code != tt.want {
+				t.Errorf("code = %q, want %q: %s", code, tt.want, message)
+			}
+		})
+	}
+}
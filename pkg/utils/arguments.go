@@ -0,0 +1,436 @@
+package utils
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"github.com/ready4god2513/deskmcp/pkg/desk"
+)
+
+// IDArguments are the arguments of tools that operate on a single record
+type IDArguments struct {
+	ID int `arg:"id"`
+}
+
+// DeleteArguments are the arguments of tools that delete a record
+type DeleteArguments struct {
+	ID      int  `arg:"id"`
+	Confirm bool `arg:"confirm"`
+}
+
+// ArgumentError describes an invalid tool argument
+type ArgumentError struct {
+	Argument string
+	Message  string
+}
+
+func (e *ArgumentError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Argument, e.Message)
+}
+
+// ArgumentErrors collects every invalid argument of a tool call
+type ArgumentErrors []*ArgumentError
+
+func (e ArgumentErrors) Error() string {
+	messages := make([]string, 0, len(e))
+	for _, err := range e {
+		messages = append(messages, err.Error())
+	}
+	return strings.Join(messages, "; ")
+}
+
+// argumentErrorPrefix starts the message of tool results reporting invalid
+// arguments
+const argumentErrorPrefix = "Invalid arguments"
+
+// ArgumentErrorResult returns the tool result reporting invalid arguments
+func ArgumentErrorResult(err error) *mcp.CallToolResult {
+	return mcp.NewToolResultError(fmt.Sprintf("%s: %v", argumentErrorPrefix, err))
+}
+
+// AddTool registers a tool whose arguments are validated against its input
+// schema before the handler is called
+func AddTool(s *server.MCPServer, tool mcp.Tool, handler server.ToolHandlerFunc) {
+	s.AddTool(tool, ValidateArguments(tool, handler))
+}
+
+// SiteRouter routes tool calls to the Desk site named by their site argument.
+// The argument is added to every tool but the excluded ones, whatever the order
+// the tools are registered in. It has no effect with fewer than two sites.
+type SiteRouter struct {
+	sites   *desk.Sites
+	exclude map[string]bool
+}
+
+// NewSiteRouter returns a router to the given sites. The excluded tools take no
+// site argument.
+func NewSiteRouter(sites *desk.Sites, exclude ...string) *SiteRouter {
+	r := &SiteRouter{
+		sites:   sites,
+		exclude: make(map[string]bool, len(exclude)),
+	}
+	for _, name := range exclude {
+		r.exclude[name] = true
+	}
+	return r
+}
+
+func (r *SiteRouter) enabled(tool string) bool {
+	return r.sites != nil && r.sites.Len() > 1 && !r.exclude[tool]
+}
+
+// Middleware runs tool calls with a site argument with the client of that
+// site. The argument is removed before the tool's handler is called.
+func (r *SiteRouter) Middleware() server.ToolHandlerMiddleware {
+	return func(next server.ToolHandlerFunc) server.ToolHandlerFunc {
+		return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+			if !r.enabled(request.Params.Name) {
+				return next(ctx, request)
+			}
+			return RouteToSite(r.sites, next)(ctx, request)
+		}
+	}
+}
+
+// AddHooks adds the site argument to the input schema of the listed tools
+func (r *SiteRouter) AddHooks(hooks *server.Hooks) {
+	hooks.AddAfterListTools(func(ctx context.Context, id any, message *mcp.ListToolsRequest, result *mcp.ListToolsResult) {
+		for i, tool := range result.Tools {
+			if r.enabled(tool.Name) {
+				result.Tools[i] = r.withSite(tool)
+			}
+		}
+	})
+}
+
+// withSite returns a copy of tool with the site argument. The properties are
+// copied since the listed tools share them with the registered ones.
+func (r *SiteRouter) withSite(tool mcp.Tool) mcp.Tool {
+	properties := make(map[string]interface{}, len(tool.InputSchema.Properties)+1)
+	for k, v := range tool.InputSchema.Properties {
+		properties[k] = v
+	}
+	tool.InputSchema.Properties = properties
+	description := "Desk site to run the tool against. Use list_sites to list the sites."
+	if _, err := r.sites.Client(""); err == nil {
+		description = fmt.Sprintf("Desk site to run the tool against (default %s). Use list_sites to list the sites.", r.sites.Default())
+	}
+	mcp.WithString("site",
+		mcp.Description(description),
+		mcp.Enum(r.sites.Names()...),
+	)(&tool)
+	return tool
+}
+
+// RouteToSite wraps a tool handler so that calls with a site argument use the
+// client of that site. The argument is removed before the handler is called.
+// Calls of sessions that send their own credentials cannot name a site, since
+// the site's token is not theirs to use.
+func RouteToSite(sites *desk.Sites, handler server.ToolHandlerFunc) server.ToolHandlerFunc {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		raw, ok := request.Params.Arguments["site"]
+		if !ok || raw == nil {
+			return handler(ctx, request)
+		}
+		if desk.HasSessionCredentials(ctx) {
+			return ArgumentErrorResult(&ArgumentError{Argument: "site", Message: "cannot be used by sessions that send their own Desk credentials"}), nil
+		}
+		name, ok := raw.(string)
+		if !ok {
+			return ArgumentErrorResult(&ArgumentError{Argument: "site", Message: fmt.Sprintf("must be a string, got %T", raw)}), nil
+		}
+		client, err := sites.Client(name)
+		if err != nil {
+			return ArgumentErrorResult(&ArgumentError{Argument: "site", Message: err.Error()}), nil
+		}
+
+		args := make(map[string]interface{}, len(request.Params.Arguments))
+		for k, v := range request.Params.Arguments {
+			if k != "site" {
+				args[k] = v
+			}
+		}
+		request.Params.Arguments = args
+		return handler(desk.WithClient(ctx, client), request)
+	}
+}
+
+// ValidateArguments wraps a tool handler so that calls with arguments that do
+// not match the tool's input schema are rejected with a tool error.
+func ValidateArguments(tool mcp.Tool, handler server.ToolHandlerFunc) server.ToolHandlerFunc {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		if err := CheckArguments(tool, request.Params.Arguments); err != nil {
+			return ArgumentErrorResult(err), nil
+		}
+		return handler(ctx, request)
+	}
+}
+
+// CheckArguments checks arguments against the required fields, types, enums
+// and bounds declared in the tool's input schema. Strings holding numbers are
+// accepted for numeric arguments and numbers for string arguments, since
+// clients are not consistent about how they send IDs.
+func CheckArguments(tool mcp.Tool, args map[string]interface{}) error {
+	var errs ArgumentErrors
+	for _, name := range tool.InputSchema.Required {
+		if args[name] == nil {
+			errs = append(errs, &ArgumentError{Argument: name, Message: "is required"})
+		}
+	}
+
+	names := make([]string, 0, len(args))
+	for name := range args {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		value := args[name]
+		if value == nil {
+			continue
+		}
+		schema, ok := tool.InputSchema.Properties[name].(map[string]interface{})
+		if !ok {
+			errs = append(errs, &ArgumentError{Argument: name, Message: "is not a known argument of " + tool.Name})
+			continue
+		}
+		if msg := checkValue(schema, value); msg != "" {
+			errs = append(errs, &ArgumentError{Argument: name, Message: msg})
+		}
+	}
+
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+func checkValue(schema map[string]interface{}, value interface{}) string {
+	switch schema["type"] {
+	case "string":
+		switch value.(type) {
+		case string, float64:
+		default:
+			return fmt.Sprintf("expected a string, got %s", describe(value))
+		}
+
+	case "number", "integer":
+		n, ok := toFloat(value)
+		if !ok {
+			return fmt.Sprintf("expected a number, got %s", describe(value))
+		}
+		if schema["type"] == "integer" && !isInteger(n) {
+			return fmt.Sprintf("expected an integer, got %v", value)
+		}
+		if min, ok := schema["minimum"].(float64); ok && n < min {
+			return fmt.Sprintf("must be at least %v, got %v", min, n)
+		}
+		if max, ok := schema["maximum"].(float64); ok && n > max {
+			return fmt.Sprintf("must be at most %v, got %v", max, n)
+		}
+
+	case "boolean":
+		if _, ok := toBool(value); !ok {
+			return fmt.Sprintf("expected a boolean, got %s", describe(value))
+		}
+
+	case "object":
+		if _, ok := value.(map[string]interface{}); !ok {
+			return fmt.Sprintf("expected an object, got %s", describe(value))
+		}
+
+	case "array":
+		items, ok := value.([]interface{})
+		if !ok {
+			return fmt.Sprintf("expected an array, got %s", describe(value))
+		}
+		if itemSchema, ok := schema["items"].(map[string]interface{}); ok {
+			for i, item := range items {
+				if msg := checkValue(itemSchema, item); msg != "" {
+					return fmt.Sprintf("item %d: %s", i, msg)
+				}
+			}
+		}
+	}
+
+	if enum, ok := schema["enum"].([]string); ok {
+		s := fmt.Sprintf("%v", value)
+		for _, allowed := range enum {
+			if s == allowed {
+				return ""
+			}
+		}
+		return fmt.Sprintf("must be one of %s, got %q", strings.Join(enum, ", "), s)
+	}
+	return ""
+}
+
+// BindArguments copies the request arguments into the struct pointed to by
+// dst. Fields are matched by their `arg` tag. Arguments that are missing or
+// null leave the field unchanged, so optional arguments can be bound to
+// pointer fields or to fields pre-filled with defaults. IDs and numbers are
+// accepted both as JSON numbers and as strings.
+func BindArguments(request mcp.CallToolRequest, dst interface{}) error {
+	v := reflect.ValueOf(dst)
+	if v.Kind() != reflect.Ptr || v.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("BindArguments expects a pointer to a struct, got %T", dst)
+	}
+	v = v.Elem()
+	t := v.Type()
+
+	var errs ArgumentErrors
+	for i := 0; i < t.NumField(); i++ {
+		name := t.Field(i).Tag.Get("arg")
+		if name == "" || name == "-" {
+			continue
+		}
+		raw, ok := request.Params.Arguments[name]
+		if !ok || raw == nil {
+			continue
+		}
+		if err := setValue(v.Field(i), raw); err != nil {
+			errs = append(errs, &ArgumentError{Argument: name, Message: err.Error()})
+		}
+	}
+
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// ChangedFields returns the fields of an update from the struct pointed to
+// by args after BindArguments. Pointer fields with a `field` tag that were
+// set are returned under the name of the tag, e.g.
+//
+//	FirstName *string `arg:"first_name" field:"firstName"`
+func ChangedFields(args interface{}) map[string]interface{} {
+	v := reflect.ValueOf(args).Elem()
+	t := v.Type()
+	fields := make(map[string]interface{})
+	for i := 0; i < t.NumField(); i++ {
+		name := t.Field(i).Tag.Get("field")
+		if name == "" || v.Field(i).Kind() != reflect.Ptr || v.Field(i).IsNil() {
+			continue
+		}
+		fields[name] = v.Field(i).Elem().Interface()
+	}
+	return fields
+}
+
+func setValue(field reflect.Value, raw interface{}) error {
+	switch field.Kind() {
+	case reflect.Ptr:
+		value := reflect.New(field.Type().Elem())
+		if err := setValue(value.Elem(), raw); err != nil {
+			return err
+		}
+		field.Set(value)
+
+	case reflect.String:
+		switch v := raw.(type) {
+		case string:
+			field.SetString(v)
+		case float64:
+			field.SetString(strconv.FormatFloat(v, 'f', -1, 64))
+		default:
+			return fmt.Errorf("expected a string, got %s", describe(raw))
+		}
+
+	case reflect.Int, reflect.Int64:
+		n, ok := toFloat(raw)
+		if !ok || !isInteger(n) || field.OverflowInt(int64(n)) {
+			return fmt.Errorf("expected an integer, got %v", raw)
+		}
+		field.SetInt(int64(n))
+
+	case reflect.Float64:
+		n, ok := toFloat(raw)
+		if !ok {
+			return fmt.Errorf("expected a number, got %v", raw)
+		}
+		field.SetFloat(n)
+
+	case reflect.Bool:
+		b, ok := toBool(raw)
+		if !ok {
+			return fmt.Errorf("expected a boolean, got %v", raw)
+		}
+		field.SetBool(b)
+
+	case reflect.Slice:
+		items, ok := raw.([]interface{})
+		if !ok {
+			return fmt.Errorf("expected an array, got %s", describe(raw))
+		}
+		slice := reflect.MakeSlice(field.Type(), len(items), len(items))
+		for i, item := range items {
+			if err := setValue(slice.Index(i), item); err != nil {
+				return fmt.Errorf("item %d: %w", i, err)
+			}
+		}
+		field.Set(slice)
+
+	case reflect.Map, reflect.Interface:
+		value := reflect.ValueOf(raw)
+		if !value.Type().AssignableTo(field.Type()) {
+			return fmt.Errorf("expected %s, got %s", field.Type(), describe(raw))
+		}
+		field.Set(value)
+
+	default:
+		return fmt.Errorf("unsupported argument type %s", field.Type())
+	}
+	return nil
+}
+
+func toFloat(value interface{}) (float64, bool) {
+	switch v := value.(type) {
+	case float64:
+		return v, true
+	case string:
+		n, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+		return n, err == nil
+	}
+	return 0, false
+}
+
+// isInteger reports whether n is a whole number that fits in an int64
+func isInteger(n float64) bool {
+	return n == math.Trunc(n) && n >= math.MinInt64 && n < math.MaxInt64
+}
+
+func toBool(value interface{}) (bool, bool) {
+	switch v := value.(type) {
+	case bool:
+		return v, true
+	case string:
+		b, err := strconv.ParseBool(v)
+		return b, err == nil
+	}
+	return false, false
+}
+
+func describe(v interface{}) string {
+	switch v.(type) {
+	case string:
+		return "a string"
+	case float64:
+		return "a number"
+	case bool:
+		return "a boolean"
+	case []interface{}:
+		return "an array"
+	case map[string]interface{}:
+		return "an object"
+	default:
+		return fmt.Sprintf("%T", v)
+	}
+}
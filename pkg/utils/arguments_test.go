@@ -0,0 +1,188 @@
+package utils
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// testTool declares one argument of every kind CheckArguments checks
+func testTool() mcp.Tool {
+	tool := mcp.NewTool("test_tool",
+		mcp.WithString("id", mcp.Required()),
+		mcp.WithNumber("pageSize", mcp.Min(1), mcp.Max(100)),
+		mcp.WithString("orderMode", mcp.Enum("asc", "desc")),
+		mcp.WithBoolean("confirm"),
+		mcp.WithObject("filter"),
+		mcp.WithArray("tags", mcp.Items(map[string]interface{}{"type": "string"})),
+	)
+	tool.InputSchema.Properties["count"] = map[string]interface{}{"type": "integer", "minimum": float64(0)}
+	return tool
+}
+
+// arguments decodes tool arguments the way they arrive from a client
+func arguments(t *testing.T, s string) map[string]interface{} {
+	t.Helper()
+	var args map[string]interface{}
+	if err := json.Unmarshal([]byte(s), &args); err != nil {
+		t.Fatalf("%s: %v", s, err)
+	}
+	return args
+}
+
+func TestCheckArguments(t *testing.T) {
+	tests := []struct {
+		name string
+		args string
+		want string
+	}{
+		{"valid", `{"id": "1", "pageSize": 10, "orderMode": "asc", "confirm": true, "filter": {}, "tags": ["a"], "count": 3}`, ""},
+		{"numeric id", `{"id": 1}`, ""},
+		{"numeric strings", `{"id": "1", "pageSize": "10", "count": "3", "confirm": "true"}`, ""},
+		{"null optional", `{"id": "1", "pageSize": null}`, ""},
+		{"missing required", `{}`, "id: is required"},
+		{"null required", `{"id": null}`, "id: is required"},
+		{"unknown", `{"id": "1", "colour": "red"}`, "colour: is not a known argument of test_tool"},
+		{"string of an object", `{"id": {}}`, "id: expected a string, got an object"},
+		{"number of a word", `{"id": "1", "pageSize": "ten"}`, "pageSize: expected a number, got a string"},
+		{"below minimum", `{"id": "1", "pageSize": 0}`, "pageSize: must be at least 1, got 0"},
+		{"above maximum", `{"id": "1", "pageSize": 500}`, "pageSize: must be at most 100, got 500"},
+		{"fractional integer", `{"id": "1", "count": 1.5}`, "count: expected an integer, got 1.5"},
+		{"fractional integer string", `{"id": "1", "count": "2.5"}`, "count: expected an integer, got 2.5"},
+		{"not in enum", `{"id": "1", "orderMode": "up"}`, `orderMode: must be one of asc, desc, got "up"`},
+		{"bool of a word", `{"id": "1", "confirm": "yes please"}`, "confirm: expected a boolean, got a string"},
+		{"object of a string", `{"id": "1", "filter": "x"}`, "filter: expected an object, got a string"},
+		{"array of a string", `{"id": "1", "tags": "a"}`, "tags: expected an array, got a string"},
+		{"array item", `{"id": "1", "tags": ["a", {}]}`, "tags: item 1: expected a string, got an object"},
+		{"several", `{"pageSize": 0, "orderMode": "up"}`, `id: is required; orderMode: must be one of asc, desc, got "up"; pageSize: must be at least 1, got 0`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := CheckArguments(testTool(), arguments(t, tt.args))
+			got := ""
+			if err != nil {
+				got = err.Error()
+			}
+			if got != tt.want {
+				t.Errorf("error = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateArguments(t *testing.T) {
+	called := false
+	handler := ValidateArguments(testTool(), func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		called = true
+		return mcp.NewToolResultText("ok"), nil
+	})
+
+	var request mcp.CallToolRequest
+	request.Params.Arguments = arguments(t, `{"pageSize": 500}`)
+	result, err := handler(context.Background(), request)
+	if code, message := ResultError(result, err); code != ErrInvalidArguments || !strings.Contains(message, "id: is required") {
+		t.Errorf("code = %q, want %q: %s", code, ErrInvalidArguments, message)
+	}
+	if called {
+		t.Error("handler was called with invalid arguments")
+	}
+
+	request.Params.Arguments = arguments(t, `{"id": 7}`)
+	if result, err := handler(context.Background(), request); err != nil || result.IsError || !called {
+		t.Errorf("valid call = %v, %v, want the handler's result", result, err)
+	}
+}
+
+type bindArgs struct {
+	ID       int      `arg:"id"`
+	Name     *string  `arg:"name" field:"name"`
+	Order    *int     `arg:"order" field:"displayOrder"`
+	Score    float64  `arg:"score"`
+	Confirm  bool     `arg:"confirm"`
+	Tags     []string `arg:"tags"`
+	IDs      []int    `arg:"ids"`
+	PageSize int      `arg:"pageSize"`
+	Ignored  string
+}
+
+func TestBindArguments(t *testing.T) {
+	var request mcp.CallToolRequest
+	request.Params.Arguments = arguments(t, `{"id": "42", "name": "Acme", "order": 3, "score": "1.5", "confirm": "true", "tags": ["a", 7], "ids": [1, "2"], "pageSize": null}`)
+
+	args := bindArgs{PageSize: 10, Ignored: "kept"}
+	if err := BindArguments(request, &args); err != nil {
+		t.Fatal(err)
+	}
+	if args.ID != 42 || args.Name == nil || *args.Name != "Acme" || args.Order == nil || *args.Order != 3 {
+		t.Errorf("args = %+v, want the ID, name and order bound", args)
+	}
+	if args.Score != 1.5 || !args.Confirm {
+		t.Errorf("score = %v, confirm = %v, want 1.5 and true", args.Score, args.Confirm)
+	}
+	if strings.Join(args.Tags, ",") != "a,7" || len(args.IDs) != 2 || args.IDs[0] != 1 || args.IDs[1] != 2 {
+		t.Errorf("tags = %v, ids = %v, want [a 7] and [1 2]", args.Tags, args.IDs)
+	}
+	// Missing and null arguments keep the defaults
+	if args.PageSize != 10 || args.Ignored != "kept" {
+		t.Errorf("pageSize = %d, ignored = %q, want the defaults", args.PageSize, args.Ignored)
+	}
+}
+
+func TestBindArgumentsErrors(t *testing.T) {
+	tests := []struct {
+		args string
+		want string
+	}{
+		{`{"id": 1.5}`, "id: expected an integer, got 1.5"},
+		{`{"id": "1.5"}`, "id: expected an integer, got 1.5"},
+		{`{"id": "abc"}`, "id: expected an integer, got abc"},
+		{`{"id": "Inf"}`, "id: expected an integer, got Inf"},
+		{`{"id": 1e30}`, "id: expected an integer, got 1e+30"},
+		{`{"order": 2.5}`, "order: expected an integer, got 2.5"},
+		{`{"ids": [1, 1.5]}`, "ids: item 1: expected an integer, got 1.5"},
+		{`{"name": {}}`, "name: expected a string, got an object"},
+		{`{"confirm": "maybe"}`, "confirm: expected a boolean, got maybe"},
+		{`{"tags": "a"}`, "tags: expected an array, got a string"},
+		{`{"id": "x", "score": "y"}`, "id: expected an integer, got x; score: expected a number, got y"},
+	}
+	for _, tt := range tests {
+		var request mcp.CallToolRequest
+		request.Params.Arguments = arguments(t, tt.args)
+		var args bindArgs
+		err := BindArguments(request, &args)
+		var argErrs ArgumentErrors
+		if !errors.As(err, &argErrs) || err.Error() != tt.want {
+			t.Errorf("BindArguments(%s) = %v, want %q", tt.args, err, tt.want)
+		}
+	}
+
+	var request mcp.CallToolRequest
+	if err := BindArguments(request, bindArgs{}); err == nil {
+		t.Error("BindArguments of a struct value succeeded, want an error")
+	}
+}
+
+func TestChangedFields(t *testing.T) {
+	var request mcp.CallToolRequest
+	request.Params.Arguments = arguments(t, `{"id": 1, "order": 0, "score": 2}`)
+	var args bindArgs
+	if err := BindArguments(request, &args); err != nil {
+		t.Fatal(err)
+	}
+
+	fields := ChangedFields(&args)
+	// Only the pointer fields that were given are changed, including zero
+	// values, under the name of their field tag
+	if len(fields) != 1 || fields["displayOrder"] != 0 {
+		t.Errorf("fields = %v, want only displayOrder 0", fields)
+	}
+
+	empty := bindArgs{}
+	if fields := ChangedFields(&empty); len(fields) != 0 {
+		t.Errorf("fields without arguments = %v, want none", fields)
+	}
+}
@@ -0,0 +1,194 @@
+package utils
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/ready4god2513/deskmcp/pkg/desk"
+)
+
+// Codes of errors that are not Desk API error responses
+const (
+	ErrTimeout          = "timeout"
+	ErrNetwork          = "network"
+	ErrInvalidArguments = "invalid_arguments"
+	ErrOther            = "error"
+)
+
+// ToolError describes a failed tool call in a form an agent can act on
+type ToolError struct {
+	Message           string            `json:"message"`
+	Code              string            `json:"code"`
+	Status            int               `json:"status,omitempty"`
+	RequestID         string            `json:"request_id,omitempty"`
+	Fields            []desk.FieldError `json:"fields,omitempty"`
+	RetryAfterSeconds int               `json:"retry_after_seconds,omitempty"`
+	Hint              string            `json:"hint,omitempty"`
+}
+
+// ErrorResult returns the tool result reporting that action, e.g. "Failed to
+// create customer", failed with err. The result is a JSON object holding a
+// ToolError under "error".
+func ErrorResult(ctx context.Context, action string, err error) *mcp.CallToolResult {
+	toolErr := TranslateError(ctx, err)
+	toolErr.Message = action + ": " + toolErr.Message
+
+	data, marshalErr := json.Marshal(map[string]ToolError{"error": toolErr})
+	if marshalErr != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("%s: %v", action, err))
+	}
+	return mcp.NewToolResultError(string(data))
+}
+
+// InvalidArgumentResult returns the tool result reporting that action failed
+// because of the arguments of the call, e.g. a filter with an unknown field.
+// The error has the invalid_arguments code.
+func InvalidArgumentResult(ctx context.Context, action string, err error) *mcp.CallToolResult {
+	return ErrorResult(ctx, action, invalidArgumentsError{err})
+}
+
+// invalidArgumentsError marks an error as caused by the arguments of a call
+type invalidArgumentsError struct {
+	error
+}
+
+func (e invalidArgumentsError) Unwrap() error {
+	return e.error
+}
+
+// ResultError returns the error code and message of a tool call that failed
+// with result or err, or empty strings if it succeeded
+func ResultError(result *mcp.CallToolResult, err error) (code, message string) {
+	if err != nil {
+		return ErrOther, err.Error()
+	}
+	if result == nil || !result.IsError {
+		return "", ""
+	}
+
+	var text string
+	for _, content := range result.Content {
+		if t, ok := content.(mcp.TextContent); ok {
+			text = t.Text
+			break
+		}
+	}
+	var payload struct {
+		Error ToolError `json:"error"`
+	}
+	if json.Unmarshal([]byte(text), &payload) == nil && payload.Error.Code != "" {
+		return payload.Error.Code, payload.Error.Message
+	}
+	if strings.HasPrefix(text, argumentErrorPrefix) {
+		return ErrInvalidArguments, text
+	}
+	return ErrOther, text
+}
+
+// TranslateError classifies err and adds a hint on how to recover from it
+func TranslateError(ctx context.Context, err error) ToolError {
+	apiErr, ok := desk.TranslateError(err)
+	if !ok {
+		var (
+			netErr     net.Error
+			argErr     *ArgumentError
+			argErrs    ArgumentErrors
+			invalidErr invalidArgumentsError
+		)
+		switch {
+		case errors.As(err, &argErr) || errors.As(err, &argErrs) || errors.As(err, &invalidErr):
+			return ToolError{Message: err.Error(), Code: ErrInvalidArguments}
+		case errors.Is(err, context.DeadlineExceeded) || (errors.As(err, &netErr) && netErr.Timeout()):
+			return ToolError{Message: err.Error(), Code: ErrTimeout,
+				Hint: "Desk did not respond in time. Retry, or narrow the request with a filter or a lower max_records."}
+		case errors.As(err, &netErr):
+			return ToolError{Message: err.Error(), Code: ErrNetwork,
+				Hint: "Desk could not be reached. Check DESK_API_URL and the network connection."}
+		}
+		return ToolError{Message: err.Error(), Code: ErrOther}
+	}
+
+	toolErr := ToolError{
+		Message:   apiErr.Message,
+		Code:      apiErr.Kind(),
+		Status:    apiErr.StatusCode,
+		RequestID: apiErr.RequestID,
+		Fields:    apiErr.Fields,
+		Hint:      hint(apiErr),
+	}
+	if toolErr.Message == "" {
+		toolErr.Message = apiErr.Error()
+	}
+	if apiErr.RetryAfter > 0 {
+		toolErr.RetryAfterSeconds = int((apiErr.RetryAfter + time.Second - 1) / time.Second)
+	}
+	return toolErr
+}
+
+// resourceTools names the tools that find the records of a resource
+var resourceTools = map[string]struct{ singular, list, get string }{
+	"tickets":        {"ticket", "list_tickets", "get_ticket"},
+	"customers":      {"customer", "list_customers", "get_customer"},
+	"companies":      {"company", "list_companies", "get_company"},
+	"users":          {"user", "list_users", "get_user"},
+	"tags":           {"tag", "list_tags", "get_tag"},
+	"ticketstatuses": {"ticket status", "list_ticket_statuses", "get_ticket_status"},
+	"tickettypes":    {"ticket type", "list_ticket_types", "get_ticket_type"},
+}
+
+// hint suggests what the agent can do about an API error
+func hint(err *desk.APIError) string {
+	tools, known := resourceTools[err.Resource()]
+	switch err.Kind() {
+	case desk.ErrNotFound:
+		if known {
+			return fmt.Sprintf("No such %s exists, or it was deleted. Use %s to find the right ID.", tools.singular, tools.list)
+		}
+		return "The record does not exist, or it was deleted. Check the IDs passed to the tool."
+	case desk.ErrValidation, desk.ErrConflict:
+		if field, ok := duplicateField(err.Fields); ok && known {
+			return fmt.Sprintf("A %s with this %s already exists. Use %s with a filter on %s to find it and %s to read it instead of creating another.",
+				tools.singular, field, tools.list, field, tools.get)
+		}
+		if len(err.Fields) > 0 {
+			return "Correct the fields listed in fields and call the tool again."
+		}
+		if err.Kind() == desk.ErrConflict && known {
+			return fmt.Sprintf("The %s was changed by someone else or already exists. Read it again with %s before retrying.", tools.singular, tools.get)
+		}
+		return "Desk rejected the request. Check the arguments against the tool description and call the tool again."
+	case desk.ErrUnauthorized:
+		return "Desk rejected the API token. Check DESK_API_TOKEN, or the X-Desk-Token header of this session, and that the token has not been revoked."
+	case desk.ErrForbidden:
+		return "The Desk user of the API token is not allowed to do this. Ask a Desk administrator for the permission, or use the token of a user who has it."
+	case desk.ErrRateLimited:
+		wait := "a minute"
+		if err.RetryAfter > 0 {
+			wait = err.RetryAfter.Round(time.Second).String()
+		}
+		return fmt.Sprintf("The Desk rate limit is used up. Wait %s before retrying, and use get_rate_limit to pace further requests.", wait)
+	case desk.ErrServer:
+		return "Desk failed to handle the request. Retry later, and if it keeps failing report the request_id to Teamwork support."
+	}
+	return ""
+}
+
+// duplicateField returns the field a validation error reports a duplicate
+// value for
+func duplicateField(fields []desk.FieldError) (string, bool) {
+	for _, f := range fields {
+		msg := strings.ToLower(f.Message)
+		for _, word := range []string{"taken", "exist", "unique", "duplicate", "in use"} {
+			if strings.Contains(msg, word) {
+				return f.Field, true
+			}
+		}
+	}
+	return "", false
+}
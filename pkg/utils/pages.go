@@ -0,0 +1,356 @@
+package utils
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"math"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/ready4god2513/desksdkgo/models"
+)
+
+const (
+	// DefaultMaxRecords is the number of records fetch_all returns when
+	// max_records is not set
+	DefaultMaxRecords = 500
+	// MaxRecordsLimit is the largest number of records fetch_all returns
+	MaxRecordsLimit = 5000
+	// MaxPageSize is the largest page size the Desk API accepts
+	MaxPageSize = 100
+
+	// pageWorkers bounds the number of pages fetched concurrently
+	pageWorkers = 4
+)
+
+// ListOptions are the arguments that control how many pages a list tool returns
+type ListOptions struct {
+	Cursor     string `arg:"cursor"`
+	FetchAll   bool   `arg:"fetch_all"`
+	MaxRecords int    `arg:"max_records"`
+
+	// offset is the number of records of the first page that are skipped,
+	// as set by a cursor
+	offset int
+}
+
+// PageInfo describes the part of a listing that was returned
+type PageInfo struct {
+	Page       int    `json:"page"`
+	PageSize   int    `json:"pageSize"`
+	Pages      int    `json:"pages"`
+	Records    int    `json:"records"`
+	Returned   int    `json:"returned"`
+	HasMore    bool   `json:"hasMore"`
+	NextCursor string `json:"nextCursor,omitempty"`
+}
+
+// ListResult is the result of a list tool
+type ListResult[T any] struct {
+	Items      []T      `json:"items"`
+	Pagination PageInfo `json:"pagination"`
+}
+
+// PageFunc fetches the page of a listing described by params
+type PageFunc[T any] func(ctx context.Context, params url.Values) ([]T, models.Pagination, error)
+
+// WithListOptions adds the cursor, fetch_all and max_records arguments to a
+// list tool
+func WithListOptions() mcp.ToolOption {
+	return func(t *mcp.Tool) {
+		mcp.WithString("cursor",
+			mcp.Description("nextCursor of a previous call to continue the listing where it stopped. Pass the same filter and sort order as that call."),
+		)(t)
+		mcp.WithBoolean("fetch_all",
+			mcp.Description(fmt.Sprintf("Fetch every page of the listing, up to max_records (default %d)", DefaultMaxRecords)),
+		)(t)
+		mcp.WithNumber("max_records",
+			mcp.Description("Maximum number of records returned when fetch_all is set"),
+			mcp.Min(1),
+			mcp.Max(MaxRecordsLimit),
+		)(t)
+	}
+}
+
+// ListPages fetches the pages of a listing requested by the list options of
+// the request. params holds the filter and pagination of the first page. A
+// cursor only sets the position of the listing, and is rejected unless the
+// request has the filter and sort order of the listing that returned it.
+func ListPages[T any](ctx context.Context, request mcp.CallToolRequest, resource string, params url.Values, fetch PageFunc[T]) (ListResult[T], error) {
+	opts := ListOptions{MaxRecords: DefaultMaxRecords}
+	if err := BindArguments(request, &opts); err != nil {
+		return ListResult[T]{}, err
+	}
+
+	if opts.Cursor != "" {
+		c, err := decodeCursor(resource, opts.Cursor, params)
+		if err != nil {
+			return ListResult[T]{}, err
+		}
+		params = cloneValues(params)
+		params.Set("page", strconv.Itoa(c.page))
+		params.Set("pageSize", strconv.Itoa(c.pageSize))
+		opts.offset = c.offset
+	} else if _, ok := request.Params.Arguments["pageSize"]; opts.FetchAll && !ok {
+		params.Set("pageSize", strconv.Itoa(MaxPageSize))
+	}
+	return FetchPages(ctx, resource, params, opts, fetch)
+}
+
+// FetchPages fetches the page of a listing described by params and, with
+// FetchAll, the following pages up to MaxRecords records. The following
+// pages are fetched concurrently by a bounded number of workers. The cursor
+// of the options is ignored.
+func FetchPages[T any](ctx context.Context, resource string, params url.Values, opts ListOptions, fetch PageFunc[T]) (ListResult[T], error) {
+	if opts.MaxRecords <= 0 {
+		opts.MaxRecords = DefaultMaxRecords
+	}
+
+	first := pageNumber(params.Get("page"), 1)
+	pageSize := pageNumber(params.Get("pageSize"), DefaultPageSize)
+
+	items, pagination, err := fetch(ctx, params)
+	if err != nil {
+		return ListResult[T]{}, err
+	}
+	last := first
+	if opts.FetchAll {
+		lastPage := pagination.Pages
+		if lastPage == 0 && pagination.Records > 0 {
+			lastPage = (pagination.Records + pageSize - 1) / pageSize
+		}
+		if limit := first + (opts.offset+opts.MaxRecords+pageSize-1)/pageSize - 1; lastPage > limit {
+			lastPage = limit
+		}
+		if lastPage > first {
+			rest, err := fetchPages(ctx, params, first+1, lastPage, fetch)
+			if err != nil {
+				return ListResult[T]{}, err
+			}
+			items = append(items, rest...)
+			last = lastPage
+		}
+	}
+
+	// Records of the first page before the cursor's offset were returned by
+	// the previous call
+	if opts.offset > 0 {
+		items = items[min(opts.offset, len(items)):]
+	}
+
+	hasMore := pagination.HasMorePages
+	if pagination.Pages > 0 {
+		hasMore = last < pagination.Pages
+	}
+	trimmed := opts.FetchAll && len(items) > opts.MaxRecords
+	if trimmed {
+		items = items[:opts.MaxRecords]
+		hasMore = true
+	}
+
+	info := PageInfo{
+		Page:     first,
+		PageSize: pageSize,
+		Pages:    pagination.Pages,
+		Records:  pagination.Records,
+		Returned: len(items),
+		HasMore:  hasMore,
+	}
+	if hasMore {
+		// The listing continues after the last returned record, which is
+		// within the last fetched page when the items were trimmed
+		next := cursor{page: last + 1, pageSize: pageSize}
+		if trimmed {
+			position := (first-1)*pageSize + opts.offset + len(items)
+			next.page = position/pageSize + 1
+			next.offset = position % pageSize
+		}
+		info.NextCursor = encodeCursor(resource, next, params)
+	}
+	return ListResult[T]{Items: items, Pagination: info}, nil
+}
+
+// fetchPages fetches the pages from first to last with a bounded worker pool
+// and returns their items in page order
+func fetchPages[T any](ctx context.Context, params url.Values, first, last int, fetch PageFunc[T]) ([]T, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	results := make([][]T, last-first+1)
+	pages := make(chan int)
+	var (
+		wg       sync.WaitGroup
+		once     sync.Once
+		firstErr error
+	)
+	for i := 0; i < pageWorkers && i < len(results); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for page := range pages {
+				pageParams := cloneValues(params)
+				pageParams.Set("page", strconv.Itoa(page))
+				items, _, err := fetch(ctx, pageParams)
+				if err != nil {
+					once.Do(func() {
+						firstErr = fmt.Errorf("page %d: %w", page, err)
+						cancel()
+					})
+					continue
+				}
+				results[page-first] = items
+			}
+		}()
+	}
+
+	for page := first; page <= last; page++ {
+		select {
+		case pages <- page:
+		case <-ctx.Done():
+		}
+	}
+	close(pages)
+	wg.Wait()
+
+	if firstErr != nil {
+		return nil, firstErr
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	var items []T
+	for _, page := range results {
+		items = append(items, page...)
+	}
+	return items, nil
+}
+
+// cursor is the position at which a listing continues: the record at offset
+// within page
+type cursor struct {
+	page     int
+	pageSize int
+	offset   int
+}
+
+// encodeCursor returns an opaque token for continuing a listing of resource
+// described by params at c. The token holds a digest of the filter and sort
+// order instead of the parameters themselves.
+func encodeCursor(resource string, c cursor, params url.Values) string {
+	values := url.Values{}
+	values.Set("page", strconv.Itoa(c.page))
+	values.Set("pageSize", strconv.Itoa(c.pageSize))
+	values.Set("offset", strconv.Itoa(c.offset))
+	values.Set("query", queryDigest(params))
+	return base64.RawURLEncoding.EncodeToString([]byte(resource + "?" + values.Encode()))
+}
+
+// decodeCursor returns the position held by a cursor of a listing of resource.
+// params describes the listing of the call the cursor is passed to, whose
+// filter and sort order must be the ones the cursor was returned for.
+func decodeCursor(resource, token string, params url.Values) (cursor, error) {
+	invalid := &ArgumentError{Argument: "cursor", Message: "is not a cursor returned by a listing of " + resource}
+	raw, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return cursor{}, invalid
+	}
+	prefix, query, ok := strings.Cut(string(raw), "?")
+	if !ok || prefix != resource {
+		return cursor{}, invalid
+	}
+	values, err := url.ParseQuery(query)
+	if err != nil || len(values) != 4 {
+		return cursor{}, invalid
+	}
+
+	var c cursor
+	for _, field := range []struct {
+		name     string
+		value    *int
+		min, max int
+	}{
+		{"page", &c.page, 1, math.MaxInt32},
+		{"pageSize", &c.pageSize, 1, MaxPageSize},
+		{"offset", &c.offset, 0, MaxPageSize - 1},
+	} {
+		n, err := strconv.Atoi(values.Get(field.name))
+		if err != nil || n < field.min || n > field.max {
+			return cursor{}, invalid
+		}
+		*field.value = n
+	}
+	if c.offset >= c.pageSize {
+		return cursor{}, invalid
+	}
+	if values.Get("query") != queryDigest(params) {
+		return cursor{}, &ArgumentError{Argument: "cursor", Message: "was returned for another filter or sort order; pass the filter and sort order of the call that returned it"}
+	}
+	return c, nil
+}
+
+// queryDigest identifies the filter and sort order of a listing, i.e. its
+// parameters but the page and page size
+func queryDigest(params url.Values) string {
+	query := cloneValues(params)
+	query.Del("page")
+	query.Del("pageSize")
+	sum := sha256.Sum256([]byte(query.Encode()))
+	return hex.EncodeToString(sum[:8])
+}
+
+func pageNumber(value string, def int) int {
+	if n, err := strconv.Atoi(value); err == nil && n > 0 {
+		return n
+	}
+	return def
+}
+
+func cloneValues(values url.Values) url.Values {
+	clone := make(url.Values, len(values))
+	for k, v := range values {
+		clone[k] = append([]string(nil), v...)
+	}
+	return clone
+}
+
+// FetchAll fetches every page of a listing, MaxPageSize records at a time.
+// It is meant for small reference collections such as tags and statuses.
+func FetchAll[T any](ctx context.Context, params url.Values, fetch PageFunc[T]) ([]T, error) {
+	params = cloneValues(params)
+	params.Set("pageSize", strconv.Itoa(MaxPageSize))
+
+	var all []T
+	for page := 1; ; page++ {
+		params.Set("page", strconv.Itoa(page))
+		items, pagination, err := fetch(ctx, params)
+		if err != nil {
+			return nil, err
+		}
+		all = append(all, items...)
+		if !pagination.HasMorePages || len(items) == 0 {
+			return all, nil
+		}
+	}
+}
+
+// ResolveName returns the ID of the record of records that matches nameOrID.
+// A numeric nameOrID is taken as an ID only if no record matches it, since
+// names may be made of digits too.
+func ResolveName[T any](nameOrID string, records []T, matches func(T, string) bool, id func(T) int) (int, bool) {
+	for _, r := range records {
+		if matches(r, nameOrID) {
+			return id(r), true
+		}
+	}
+	if n, err := strconv.Atoi(nameOrID); err == nil {
+		return n, true
+	}
+	return 0, false
+}
@@ -0,0 +1,240 @@
+package utils
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/ready4god2513/desksdkgo/models"
+)
+
+// fakeListing serves the records 1 to n page by page and counts the pages
+// fetched
+type fakeListing struct {
+	n       int
+	failing int
+
+	mu      sync.Mutex
+	fetched int
+}
+
+func (l *fakeListing) fetch(ctx context.Context, params url.Values) ([]int, models.Pagination, error) {
+	page, _ := strconv.Atoi(params.Get("page"))
+	pageSize, _ := strconv.Atoi(params.Get("pageSize"))
+	l.mu.Lock()
+	l.fetched++
+	l.mu.Unlock()
+	if page == l.failing {
+		return nil, models.Pagination{}, errors.New("unavailable")
+	}
+
+	var items []int
+	for id := (page-1)*pageSize + 1; id <= page*pageSize && id <= l.n; id++ {
+		items = append(items, id)
+	}
+	pages := (l.n + pageSize - 1) / pageSize
+	return items, models.Pagination{Records: l.n, PageSize: pageSize, Pages: pages, Page: page, HasMorePages: page < pages}, nil
+}
+
+// list calls ListPages the way a list tool does
+func list(t *testing.T, l *fakeListing, args map[string]interface{}, filter string) (ListResult[int], error) {
+	t.Helper()
+	var request mcp.CallToolRequest
+	request.Params.Arguments = args
+	params := url.Values{}
+	if filter != "" {
+		params.Set("filter", filter)
+	}
+	if err := AddPaginationToParams(params, request); err != nil {
+		t.Fatal(err)
+	}
+	return ListPages(context.Background(), request, "tickets", params, l.fetch)
+}
+
+func ids(items []int) string {
+	s := make([]string, len(items))
+	for i, id := range items {
+		s[i] = strconv.Itoa(id)
+	}
+	return strings.Join(s, ",")
+}
+
+func TestListPagesCursor(t *testing.T) {
+	l := &fakeListing{n: 25}
+
+	var got []int
+	args := map[string]interface{}{"pageSize": float64(10)}
+	for calls := 0; ; calls++ {
+		if calls == 5 {
+			t.Fatal("the listing does not end")
+		}
+		result, err := list(t, l, args, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, result.Items...)
+		if !result.Pagination.HasMore {
+			if result.Pagination.NextCursor != "" {
+				t.Errorf("last page has a cursor: %+v", result.Pagination)
+			}
+			break
+		}
+		args = map[string]interface{}{"pageSize": float64(10), "cursor": result.Pagination.NextCursor}
+	}
+	if want := ids(seq(1, 25)); ids(got) != want {
+		t.Errorf("records = %s, want %s", ids(got), want)
+	}
+}
+
+func TestListPagesTrimmed(t *testing.T) {
+	l := &fakeListing{n: 25}
+
+	// max_records ends in the middle of the second page
+	args := map[string]interface{}{"pageSize": float64(10), "fetch_all": true, "max_records": float64(15)}
+	first, err := list(t, l, args, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ids(first.Items) != ids(seq(1, 15)) || !first.Pagination.HasMore || first.Pagination.Returned != 15 {
+		t.Fatalf("first call = %s, %+v, want 1 to 15 and more", ids(first.Items), first.Pagination)
+	}
+
+	// The cursor continues with the rest of the second page
+	next, err := list(t, l, map[string]interface{}{"pageSize": float64(10), "cursor": first.Pagination.NextCursor}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ids(next.Items) != ids(seq(16, 20)) || !next.Pagination.HasMore {
+		t.Errorf("page after the cursor = %s, %+v, want 16 to 20 and more", ids(next.Items), next.Pagination)
+	}
+
+	// and with fetch_all with everything that is left
+	rest, err := list(t, l, map[string]interface{}{"fetch_all": true, "cursor": first.Pagination.NextCursor}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ids(rest.Items) != ids(seq(16, 25)) || rest.Pagination.HasMore {
+		t.Errorf("rest = %s, %+v, want 16 to 25 and no more", ids(rest.Items), rest.Pagination)
+	}
+}
+
+func TestListPagesFetchAll(t *testing.T) {
+	l := &fakeListing{n: 250}
+
+	result, err := list(t, l, map[string]interface{}{"fetch_all": true}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Items) != 250 || ids(result.Items[:3]) != "1,2,3" || result.Items[249] != 250 || result.Pagination.HasMore {
+		t.Errorf("got %d records, %+v, want all 250 in order", len(result.Items), result.Pagination)
+	}
+	// Without pageSize, fetch_all fetches the largest pages
+	if l.fetched != 3 {
+		t.Errorf("%d pages fetched, want 3", l.fetched)
+	}
+
+	l = &fakeListing{n: 250, failing: 2}
+	if _, err := list(t, l, map[string]interface{}{"fetch_all": true}, ""); err == nil || !strings.Contains(err.Error(), "page 2: unavailable") {
+		t.Errorf("error = %v, want the failure of page 2", err)
+	}
+}
+
+func TestListPagesInvalidCursor(t *testing.T) {
+	l := &fakeListing{n: 25}
+	params := url.Values{"orderBy": {"createdAt"}, "orderMode": {"desc"}}
+	valid := encodeCursor("tickets", cursor{page: 2, pageSize: 10}, params)
+	raw, _ := base64.RawURLEncoding.DecodeString(valid)
+	tamper := func(from, to string) string {
+		return base64.RawURLEncoding.EncodeToString([]byte(strings.Replace(string(raw), from, to, 1)))
+	}
+
+	tests := []struct {
+		name   string
+		cursor string
+		filter string
+		want   string
+	}{
+		{"not base64", "not a cursor!", "", "is not a cursor"},
+		{"other resource", encodeCursor("customers", cursor{page: 2, pageSize: 10}, params), "", "is not a cursor"},
+		{"offset beyond the page", tamper("offset=0", "offset=10"), "", "is not a cursor"},
+		{"page size too large", tamper("pageSize=10", "pageSize=500"), "", "is not a cursor"},
+		{"page zero", tamper("page=2", "page=0"), "", "is not a cursor"},
+		{"not a number", tamper("page=2", "page=x"), "", "is not a cursor"},
+		{"extra field", base64.RawURLEncoding.EncodeToString(append(raw, "&extra=1"...)), "", "is not a cursor"},
+		{"digest changed", tamper("query=", "query=0"), "", "another filter"},
+		{"other filter", valid, `{"id":{"$eq":1}}`, "another filter"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := list(t, l, map[string]interface{}{"cursor": tt.cursor}, tt.filter)
+			var argErr *ArgumentError
+			if !errors.As(err, &argErr) || argErr.Argument != "cursor" || !strings.Contains(argErr.Message, tt.want) {
+				t.Errorf("error = %v, want a cursor error saying %q", err, tt.want)
+			}
+		})
+	}
+	if l.fetched != 0 {
+		t.Errorf("%d pages fetched with invalid cursors, want none", l.fetched)
+	}
+
+	// The untampered cursor is accepted
+	result, err := list(t, l, map[string]interface{}{"cursor": valid}, "")
+	if err != nil || ids(result.Items) != ids(seq(11, 20)) {
+		t.Errorf("valid cursor = %s, %v, want 11 to 20", ids(result.Items), err)
+	}
+}
+
+func TestFetchAll(t *testing.T) {
+	l := &fakeListing{n: 205}
+	all, err := FetchAll(context.Background(), url.Values{}, l.fetch)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(all) != 205 || l.fetched != 3 {
+		t.Errorf("got %d records in %d pages, want 205 in 3", len(all), l.fetched)
+	}
+}
+
+func TestResolveName(t *testing.T) {
+	names := []string{"Support", "2024", "Sales"}
+	match := func(name, nameOrID string) bool { return strings.EqualFold(name, nameOrID) }
+	id := func(name string) int {
+		for i, n := range names {
+			if n == name {
+				return i + 100
+			}
+		}
+		return 0
+	}
+
+	tests := []struct {
+		nameOrID string
+		want     int
+		ok       bool
+	}{
+		{"sales", 102, true},
+		{"2024", 101, true}, // a name made of digits wins over the ID
+		{"7", 7, true},
+		{"Billing", 0, false},
+	}
+	for _, tt := range tests {
+		got, ok := ResolveName(tt.nameOrID, names, match, id)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("ResolveName(%q) = %d, %v, want %d, %v", tt.nameOrID, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func seq(from, to int) []int {
+	var s []int
+	for i := from; i <= to; i++ {
+		s = append(s, i)
+	}
+	return s
+}
@@ -7,12 +7,15 @@ import (
 	"github.com/mark3labs/mcp-go/mcp"
 )
 
+// DefaultPageSize is the page size of list tools called without pageSize
+var DefaultPageSize = 10
+
 // PaginationParams represents the pagination and sorting parameters
 type PaginationParams struct {
-	OrderBy   string
-	OrderMode string
-	Page      int
-	PageSize  int
+	OrderBy   string `arg:"orderBy"`
+	OrderMode string `arg:"orderMode"`
+	Page      int    `arg:"page"`
+	PageSize  int    `arg:"pageSize"`
 }
 
 // DefaultPaginationParams returns the default pagination parameters
@@ -21,33 +24,30 @@ func DefaultPaginationParams() PaginationParams {
 		OrderBy:   "createdAt",
 		OrderMode: "desc",
 		Page:      1,
-		PageSize:  10,
+		PageSize:  DefaultPageSize,
 	}
 }
 
-// AddPaginationToParams adds pagination and sorting parameters to the URL values
-func AddPaginationToParams(params url.Values, request mcp.CallToolRequest) {
-	// Get pagination parameters from request
-	orderBy, ok := request.Params.Arguments["orderBy"].(string)
-	if !ok {
-		orderBy = "createdAt"
-	}
-	orderMode, ok := request.Params.Arguments["orderMode"].(string)
-	if !ok {
-		orderMode = "desc"
-	}
-	page, ok := request.Params.Arguments["page"].(float64)
-	if !ok {
-		page = 1
-	}
-	pageSize, ok := request.Params.Arguments["pageSize"].(float64)
-	if !ok {
-		pageSize = 10
+// AddPaginationToParams adds pagination and sorting parameters to the URL values.
+// Arguments that are not given use the defaults; arguments that cannot be
+// decoded are reported as an error.
+func AddPaginationToParams(params url.Values, request mcp.CallToolRequest) error {
+	p := DefaultPaginationParams()
+	if err := BindArguments(request, &p); err != nil {
+		return err
 	}
 
 	// Add the parameters to the URL values
-	params.Add("orderBy", orderBy)
-	params.Add("orderMode", orderMode)
-	params.Add("page", strconv.Itoa(int(page)))
-	params.Add("pageSize", strconv.Itoa(int(pageSize)))
+	params.Add("orderBy", p.OrderBy)
+	params.Add("orderMode", p.OrderMode)
+	params.Add("page", strconv.Itoa(p.Page))
+	params.Add("pageSize", strconv.Itoa(p.PageSize))
+	return nil
+}
+
+// AddCountParams requests a single record so that only the pagination totals
+// are transferred when counting resources
+func AddCountParams(params url.Values) {
+	params.Set("page", "1")
+	params.Set("pageSize", "1")
 }
@@ -0,0 +1,43 @@
+package utils
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// ResourceID returns the {id} variable of a resource read through a URI
+// template such as desk://tickets/{id}
+func ResourceID(request mcp.ReadResourceRequest) (int, error) {
+	var raw string
+	switch v := request.Params.Arguments["id"].(type) {
+	case string:
+		raw = v
+	case []string:
+		if len(v) > 0 {
+			raw = v[0]
+		}
+	}
+	id, err := strconv.Atoi(raw)
+	if err != nil || id <= 0 {
+		return 0, fmt.Errorf("invalid ID in resource URI %s", request.Params.URI)
+	}
+	return id, nil
+}
+
+// JSONResource returns v as the JSON contents of the resource with the given URI
+func JSONResource(uri string, v interface{}) ([]mcp.ResourceContents, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal %s: %w", uri, err)
+	}
+	return []mcp.ResourceContents{
+		mcp.TextResourceContents{
+			URI:      uri,
+			MIMEType: "application/json",
+			Text:     string(data),
+		},
+	}, nil
+}
@@ -0,0 +1,218 @@
+package watch
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/ready4god2513/deskmcp/pkg/desk"
+)
+
+// pollTimeout bounds a single poll
+const pollTimeout = time.Minute
+
+// poller polls one kind of record for changes on behalf of every session
+// that polls with the same credentials, filter and interval
+type poller struct {
+	w        *Watcher
+	key      string
+	site     string
+	kind     string
+	where    map[string]interface{}
+	interval time.Duration
+	// subscriptions restricts the polled records to the ones the listening
+	// sessions subscribed to
+	subscriptions bool
+	// listeners are notified of the changes, by watch ID for watches and by
+	// session ID for subscriptions. They are guarded by the watcher's mutex.
+	listeners map[string]listener
+
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	// since is the updatedAt of the most recent change seen so far and seen
+	// the updatedAt of the records the last poll returned, so that records
+	// updated in the same second as since are reported once
+	since time.Time
+	seen  map[int]string
+}
+
+// listener is a session notified of the changes a poller finds
+type listener struct {
+	sessionID string
+	// match reports whether the session wants to hear about a changed
+	// record. All changes are reported when it is nil.
+	match func(uri string) bool
+}
+
+// change is a record as far as change detection is concerned
+type change struct {
+	id        int
+	updatedAt string
+}
+
+func (w *Watcher) newPoller(key, kind string, client *desk.Client, where map[string]interface{}, interval time.Duration) *poller {
+	ctx, cancel := context.WithCancel(desk.WithClient(context.Background(), client))
+	return &poller{
+		w:         w,
+		key:       key,
+		site:      client.Site(),
+		kind:      kind,
+		where:     where,
+		interval:  interval,
+		listeners: make(map[string]listener),
+		ctx:       ctx,
+		cancel:    cancel,
+		since:     time.Now().UTC().Truncate(time.Second),
+		seen:      map[int]string{},
+	}
+}
+
+// pollerKey identifies the pollers that can be shared: the ones of the same
+// Desk user polling the same records at the same interval
+func pollerKey(client *desk.Client, kind string, interval time.Duration, records string) string {
+	return strings.Join([]string{client.Identity(), kind, interval.String(), records}, "\x00")
+}
+
+func (p *poller) stop() {
+	p.cancel()
+}
+
+// run polls until the poller is stopped. Failed polls are retried with an
+// exponential backoff of up to the watcher's maximum backoff.
+func (p *poller) run() {
+	failures := 0
+	timer := time.NewTimer(p.interval)
+	defer timer.Stop()
+	for {
+		select {
+		case <-p.ctx.Done():
+			return
+		case <-timer.C:
+		}
+
+		delay := p.interval
+		if err := p.poll(); err != nil {
+			if p.ctx.Err() != nil {
+				return
+			}
+			failures++
+			delay = backoff(p.interval, p.w.maxBackoff, failures)
+			slog.Warn("polling failed", "kind", p.kind, "retry_in", delay, "error", err)
+		} else {
+			failures = 0
+		}
+		timer.Reset(delay)
+	}
+}
+
+// backoff doubles the interval for every consecutive failure, up to maxDelay.
+// A failing poll is never delayed by less than the interval.
+func backoff(interval, maxDelay time.Duration, failures int) time.Duration {
+	if maxDelay < interval {
+		maxDelay = interval
+	}
+	delay := interval
+	for i := 0; i < failures && delay < maxDelay; i++ {
+		delay *= 2
+	}
+	if delay > maxDelay {
+		delay = maxDelay
+	}
+	return delay
+}
+
+// poll fetches the records changed since the last poll and notifies the
+// listening sessions of them. A poll fetches at most maxChanges records, the
+// least recently updated first, so that since only moves past the changes
+// that were reported and the next poll picks up the rest.
+func (p *poller) poll() error {
+	p.w.mu.Lock()
+	listeners := make([]listener, 0, len(p.listeners))
+	for _, l := range p.listeners {
+		listeners = append(listeners, l)
+	}
+	var ids []interface{}
+	if p.subscriptions {
+		ids = p.w.subscribedIDs(p)
+	}
+	p.w.mu.Unlock()
+	if p.subscriptions && len(ids) == 0 {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(p.ctx, pollTimeout)
+	defer cancel()
+
+	conditions := []interface{}{map[string]interface{}{
+		"updated_at": map[string]interface{}{"$gte": p.since.Format(time.RFC3339)},
+	}}
+	if len(p.where) > 0 {
+		conditions = append(conditions, p.where)
+	}
+	if p.subscriptions {
+		conditions = append(conditions, map[string]interface{}{
+			"id": map[string]interface{}{"$in": ids},
+		})
+	}
+	where := conditions[0].(map[string]interface{})
+	if len(conditions) > 1 {
+		where = map[string]interface{}{"$and": conditions}
+	}
+	changes, err := p.fetch(ctx, where)
+	if err != nil {
+		return err
+	}
+	if len(changes) >= maxChanges && changes[len(changes)-1].updatedAt == p.since.Format(time.RFC3339) {
+		slog.Warn("more records changed in the same second than a poll fetches, some changes are not reported",
+			"kind", p.kind, "updated_at", p.since, "max_changes", maxChanges)
+	}
+
+	seen := make(map[int]string, len(changes))
+	for _, c := range changes {
+		seen[c.id] = c.updatedAt
+		if p.seen[c.id] == c.updatedAt {
+			continue
+		}
+		if t, err := time.Parse(time.RFC3339, c.updatedAt); err == nil && t.After(p.since) {
+			p.since = t
+		}
+		uri := fmt.Sprintf("desk://%s/%d", p.kind, c.id)
+		notified := make(map[string]bool, len(listeners))
+		for _, l := range listeners {
+			if !notified[l.sessionID] && (l.match == nil || l.match(uri)) {
+				notified[l.sessionID] = true
+				p.w.notify(l.sessionID, uri)
+			}
+		}
+	}
+	p.seen = seen
+	return nil
+}
+
+func (p *poller) fetch(ctx context.Context, where map[string]interface{}) ([]change, error) {
+	var changes []change
+	switch p.kind {
+	case "tickets":
+		views, _, err := p.w.tickets.FindUpdatedTickets(ctx, where, maxChanges)
+		if err != nil {
+			return nil, err
+		}
+		for _, t := range views {
+			changes = append(changes, change{id: t.ID, updatedAt: t.UpdatedAt})
+		}
+	case "customers":
+		found, _, err := p.w.customers.FindUpdatedCustomers(ctx, where, maxChanges)
+		if err != nil {
+			return nil, err
+		}
+		for _, c := range found {
+			changes = append(changes, change{id: c.ID, updatedAt: c.UpdatedAt.UTC().Format(time.RFC3339)})
+		}
+	default:
+		return nil, fmt.Errorf("cannot poll %s", p.kind)
+	}
+	return changes, nil
+}
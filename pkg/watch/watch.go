@@ -0,0 +1,546 @@
+// Package watch notifies MCP sessions of changes to Desk records. The Desk
+// API has no change feed, so records are polled for changes to their
+// updatedAt timestamp.
+package watch
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"github.com/ready4god2513/deskmcp/pkg/customers"
+	"github.com/ready4god2513/deskmcp/pkg/desk"
+	"github.com/ready4god2513/deskmcp/pkg/tickets"
+	"github.com/ready4god2513/deskmcp/pkg/utils"
+)
+
+const (
+	// DefaultInterval is how often records are polled by default
+	DefaultInterval = 30 * time.Second
+	// DefaultMaxBackoff is the longest a failing poll is delayed by default
+	DefaultMaxBackoff = 10 * time.Minute
+	// MinInterval is the shortest poll interval a watch may ask for
+	MinInterval = 10 * time.Second
+	// MaxInterval is the longest poll interval a watch may ask for
+	MaxInterval = time.Hour
+
+	// MaxWatches is the number of watches a session may have at a time
+	MaxWatches = 10
+	// MaxSubscriptions is the number of resources a session may subscribe to
+	MaxSubscriptions = 100
+
+	// maxChanges is the number of changed records a single poll fetches. The
+	// changes beyond it are fetched by the next polls.
+	maxChanges = 500
+)
+
+// Watcher tracks the resource subscriptions and ticket watches of every
+// session and polls Desk for the records they cover. Sessions of the same Desk
+// user share the pollers of the same records.
+type Watcher struct {
+	deskClient *desk.Client
+	tickets    *tickets.TicketHandler
+	customers  *customers.CustomerHandler
+	interval   time.Duration
+	maxBackoff time.Duration
+
+	mu       sync.Mutex
+	sessions map[string]*sessionState
+	pollers  map[string]*poller
+	nextID   int
+}
+
+// sessionState holds what a session watches
+type sessionState struct {
+	session server.ClientSession
+	// subscriptions maps the subscribed URIs to the site they were
+	// subscribed to on
+	subscriptions map[string]string
+	// pollers of the subscribed resources, by kind
+	subscribed map[string]*poller
+	// pollers of the watches, by watch ID
+	watches map[string]*poller
+}
+
+// Option configures a Watcher
+type Option func(*Watcher)
+
+// WithInterval sets how often subscribed records are polled. With an
+// interval of zero they are not polled, and only change when NotifyUpdated is
+// called.
+func WithInterval(interval time.Duration) Option {
+	return func(w *Watcher) {
+		w.interval = interval
+	}
+}
+
+// WithMaxBackoff sets the longest a poll is delayed after repeated failures
+func WithMaxBackoff(maxBackoff time.Duration) Option {
+	return func(w *Watcher) {
+		w.maxBackoff = maxBackoff
+	}
+}
+
+func NewWatcher(deskClient *desk.Client, opts ...Option) *Watcher {
+	w := &Watcher{
+		deskClient: deskClient,
+		tickets:    tickets.NewTicketHandler(deskClient),
+		customers:  customers.NewCustomerHandler(deskClient),
+		interval:   DefaultInterval,
+		maxBackoff: DefaultMaxBackoff,
+		sessions:   make(map[string]*sessionState),
+		pollers:    make(map[string]*poller),
+	}
+	for _, opt := range opts {
+		opt(w)
+	}
+	return w
+}
+
+// AddHooks adds the hooks that track the sessions of the server to hooks
+func (w *Watcher) AddHooks(hooks *server.Hooks) {
+	hooks.AddOnRegisterSession(func(_ context.Context, session server.ClientSession) {
+		w.mu.Lock()
+		defer w.mu.Unlock()
+		w.sessions[session.SessionID()] = &sessionState{
+			session:       session,
+			subscriptions: make(map[string]string),
+			subscribed:    make(map[string]*poller),
+			watches:       make(map[string]*poller),
+		}
+	})
+	hooks.AddOnUnregisterSession(func(_ context.Context, session server.ClientSession) {
+		w.mu.Lock()
+		defer w.mu.Unlock()
+		if state, ok := w.sessions[session.SessionID()]; ok {
+			w.release(session.SessionID(), state)
+			delete(w.sessions, session.SessionID())
+		}
+	})
+}
+
+// Close stops polling for every session
+func (w *Watcher) Close() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	for key, p := range w.pollers {
+		p.stop()
+		delete(w.pollers, key)
+	}
+	for id := range w.sessions {
+		delete(w.sessions, id)
+	}
+}
+
+// attach adds a listener to the poller of key. The poller is created with
+// newPoller and started if no session polls with it yet. It is called with
+// w.mu held.
+func (w *Watcher) attach(key, listenerID string, l listener, newPoller func() *poller) *poller {
+	p, ok := w.pollers[key]
+	if !ok {
+		p = newPoller()
+		w.pollers[key] = p
+		go p.run()
+	}
+	p.listeners[listenerID] = l
+	return p
+}
+
+// detach removes a listener from p, and stops p once no session listens to
+// it. It is called with w.mu held.
+func (w *Watcher) detach(p *poller, listenerID string) {
+	delete(p.listeners, listenerID)
+	if len(p.listeners) == 0 {
+		p.stop()
+		delete(w.pollers, p.key)
+	}
+}
+
+// release detaches the session from every poller. It is called with w.mu
+// held.
+func (w *Watcher) release(sessionID string, state *sessionState) {
+	for _, p := range state.subscribed {
+		w.detach(p, sessionID)
+	}
+	for id, p := range state.watches {
+		w.detach(p, id)
+	}
+}
+
+// subscribedIDs returns the IDs of the records of p's kind the listening
+// sessions subscribed to. It is called with w.mu held.
+func (w *Watcher) subscribedIDs(p *poller) []interface{} {
+	set := make(map[int]bool)
+	for _, l := range p.listeners {
+		state, ok := w.sessions[l.sessionID]
+		if !ok {
+			continue
+		}
+		for uri, site := range state.subscriptions {
+			if site != p.site {
+				continue
+			}
+			rawID, ok := strings.CutPrefix(uri, "desk://"+p.kind+"/")
+			if id, err := strconv.Atoi(rawID); ok && err == nil {
+				set[id] = true
+			}
+		}
+	}
+	ids := make([]int, 0, len(set))
+	for id := range set {
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+	values := make([]interface{}, len(ids))
+	for i, id := range ids {
+		values[i] = strconv.Itoa(id)
+	}
+	return values
+}
+
+// FilterMessage handles the resources/subscribe and resources/unsubscribe
+// requests, which the MCP server has no handlers for. It is meant to be used
+// as the message filter of the transports.
+func (w *Watcher) FilterMessage(ctx context.Context, sessionID string, message json.RawMessage) (mcp.JSONRPCMessage, bool) {
+	var request struct {
+		ID     interface{} `json:"id"`
+		Method string      `json:"method"`
+		Params struct {
+			URI string `json:"uri"`
+		} `json:"params"`
+	}
+	if err := json.Unmarshal(message, &request); err != nil || request.ID == nil {
+		return nil, false
+	}
+
+	var err error
+	switch request.Method {
+	case "resources/subscribe":
+		err = w.Subscribe(ctx, sessionID, request.Params.URI)
+	case "resources/unsubscribe":
+		err = w.Unsubscribe(sessionID, request.Params.URI)
+	default:
+		return nil, false
+	}
+	if err != nil {
+		return mcp.NewJSONRPCError(request.ID, mcp.INVALID_PARAMS, err.Error(), nil), true
+	}
+	return mcp.JSONRPCResponse{
+		JSONRPC: mcp.JSONRPC_VERSION,
+		ID:      request.ID,
+		Result:  mcp.EmptyResult{},
+	}, true
+}
+
+// Subscribe notifies the session whenever the record identified by uri
+// changes. Tickets and customers can be subscribed to, up to
+// MaxSubscriptions records per session.
+func (w *Watcher) Subscribe(ctx context.Context, sessionID, uri string) error {
+	kind, err := parseURI(uri)
+	if err != nil {
+		return err
+	}
+	client := desk.ClientFromContext(ctx, w.deskClient)
+	if client == nil {
+		return fmt.Errorf("no Desk credentials to poll %s with", uri)
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	state, ok := w.sessions[sessionID]
+	if !ok {
+		return fmt.Errorf("unknown session %s", sessionID)
+	}
+	if _, ok := state.subscriptions[uri]; !ok && len(state.subscriptions) >= MaxSubscriptions {
+		return fmt.Errorf("cannot subscribe to %s: the session already subscribes to %d resources", uri, MaxSubscriptions)
+	}
+	state.subscriptions[uri] = client.Site()
+	if _, ok := state.subscribed[kind]; !ok && w.interval > 0 {
+		key := pollerKey(client, kind, w.interval, "subscriptions")
+		site := client.Site()
+		l := listener{sessionID: sessionID, match: func(uri string) bool { return w.subscribed(sessionID, site, uri) }}
+		state.subscribed[kind] = w.attach(key, sessionID, l, func() *poller {
+			p := w.newPoller(key, kind, client, nil, w.interval)
+			p.subscriptions = true
+			return p
+		})
+	}
+	return nil
+}
+
+// Unsubscribe stops the notifications for uri. Polling for a kind of record
+// stops once the session no longer subscribes to any record of that kind.
+func (w *Watcher) Unsubscribe(sessionID, uri string) error {
+	kind, err := parseURI(uri)
+	if err != nil {
+		return err
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	state, ok := w.sessions[sessionID]
+	if !ok {
+		return fmt.Errorf("unknown session %s", sessionID)
+	}
+	delete(state.subscriptions, uri)
+	for subscribed := range state.subscriptions {
+		if strings.HasPrefix(subscribed, "desk://"+kind+"/") {
+			return nil
+		}
+	}
+	if p, ok := state.subscribed[kind]; ok {
+		w.detach(p, sessionID)
+		delete(state.subscribed, kind)
+	}
+	return nil
+}
+
+func (w *Watcher) subscribed(sessionID, site, uri string) bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	state, ok := w.sessions[sessionID]
+	return ok && state.subscribedTo(site, uri)
+}
+
+func (s *sessionState) subscribedTo(site, uri string) bool {
+	subscribedSite, ok := s.subscriptions[uri]
+	return ok && subscribedSite == site
+}
+
+// parseURI returns the kind of record identified by a URI that can be
+// subscribed to
+func parseURI(uri string) (string, error) {
+	rest, ok := strings.CutPrefix(uri, "desk://")
+	if ok {
+		kind, rawID, ok := strings.Cut(rest, "/")
+		if id, err := strconv.Atoi(rawID); ok && err == nil && id > 0 && (kind == "tickets" || kind == "customers") {
+			return kind, nil
+		}
+	}
+	return "", fmt.Errorf("cannot subscribe to %s: only desk://tickets/{id} and desk://customers/{id} can be subscribed to", uri)
+}
+
+// NotifyUpdated notifies every session subscribed to uri on site that the
+// resource changed. It is used for changes learned of without polling.
+func (w *Watcher) NotifyUpdated(site, uri string) {
+	w.mu.Lock()
+	var sessionIDs []string
+	for id, state := range w.sessions {
+		if state.subscribedTo(site, uri) {
+			sessionIDs = append(sessionIDs, id)
+		}
+	}
+	w.mu.Unlock()
+	for _, id := range sessionIDs {
+		w.notify(id, uri)
+	}
+}
+
+// NotifyInterested sends a notification to the sessions that subscribed to
+// the record identified by uri on site, or watch every ticket of site. Watches
+// with a filter are left out, since the filter cannot be matched without
+// fetching the record.
+func (w *Watcher) NotifyInterested(site, uri, method string, params map[string]interface{}) {
+	kind, err := parseURI(uri)
+	if err != nil {
+		return
+	}
+
+	w.mu.Lock()
+	var sessionIDs []string
+	for id, state := range w.sessions {
+		if state.subscribedTo(site, uri) || state.watchesAll(site, kind) {
+			sessionIDs = append(sessionIDs, id)
+		}
+	}
+	w.mu.Unlock()
+	for _, id := range sessionIDs {
+		w.send(id, mcp.JSONRPCNotification{
+			JSONRPC: mcp.JSONRPC_VERSION,
+			Notification: mcp.Notification{
+				Method: method,
+				Params: mcp.NotificationParams{AdditionalFields: params},
+			},
+		})
+	}
+}
+
+// watchesAll reports whether the session watches every record of kind on site
+func (s *sessionState) watchesAll(site, kind string) bool {
+	for _, p := range s.watches {
+		if p.site == site && p.kind == kind && len(p.where) == 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// notify sends a resources/updated notification to the session
+func (w *Watcher) notify(sessionID, uri string) {
+	w.send(sessionID, mcp.JSONRPCNotification{
+		JSONRPC: mcp.JSONRPC_VERSION,
+		Notification: mcp.Notification{
+			Method: mcp.MethodNotificationResourceUpdated,
+			Params: mcp.NotificationParams{
+				AdditionalFields: map[string]interface{}{"uri": uri},
+			},
+		},
+	})
+}
+
+// send sends a notification to the session unless its queue is full
+func (w *Watcher) send(sessionID string, notification mcp.JSONRPCNotification) {
+	w.mu.Lock()
+	state, ok := w.sessions[sessionID]
+	w.mu.Unlock()
+	if !ok || !state.session.Initialized() {
+		return
+	}
+	select {
+	case state.session.NotificationChannel() <- notification:
+	default:
+		slog.Warn("dropped a notification: the notification queue of the session is full", "method", notification.Method, "session", sessionID)
+	}
+}
+
+func (w *Watcher) RegisterTools(s *server.MCPServer) {
+	// Watch tickets
+	utils.AddTool(s, mcp.NewTool("watch_tickets",
+		mcp.WithDescription(fmt.Sprintf(`Watch the tickets matching a filter for changes. Whenever a matching ticket is created or updated, a resources/updated notification for its desk://tickets/{id} resource is sent to this session.
+
+Changes are found by polling, so they are reported up to interval_seconds late. Polling slows down while the Desk API fails and recovers once it succeeds again. A session can have up to %d watches.`, MaxWatches)),
+		mcp.WithObject("filter",
+			mcp.Description("Filter of the tickets to watch, with the fields and operators of the filter of list_tickets. All tickets are watched when it is omitted."),
+		),
+		mcp.WithNumber("interval_seconds",
+			mcp.Description(fmt.Sprintf("How often to poll for changes, in seconds (default %d)", int(DefaultInterval.Seconds()))),
+			mcp.Min(MinInterval.Seconds()),
+			mcp.Max(MaxInterval.Seconds()),
+		),
+	), w.watchTickets)
+
+	// Stop watching tickets
+	utils.AddTool(s, mcp.NewTool("unwatch_tickets",
+		mcp.WithDescription("Stop a watch created by watch_tickets"),
+		mcp.WithString("watch_id",
+			mcp.Required(),
+			mcp.Description("ID of the watch returned by watch_tickets"),
+		),
+	), w.unwatchTickets)
+}
+
+// watchInfo describes a watch created by watch_tickets
+type watchInfo struct {
+	WatchID         string                 `json:"watch_id"`
+	Filter          map[string]interface{} `json:"filter,omitempty"`
+	IntervalSeconds int                    `json:"interval_seconds"`
+	Watches         []string               `json:"watches"`
+}
+
+func (w *Watcher) watchTickets(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	var args struct {
+		Filter          map[string]interface{} `arg:"filter"`
+		IntervalSeconds *int                   `arg:"interval_seconds"`
+	}
+	if err := utils.BindArguments(request, &args); err != nil {
+		return utils.ArgumentErrorResult(err), nil
+	}
+	if err := tickets.ValidateFilter(args.Filter); err != nil {
+		return utils.InvalidArgumentResult(ctx, "Failed to watch tickets", err), nil
+	}
+	interval := w.interval
+	if interval <= 0 {
+		interval = DefaultInterval
+	}
+	if args.IntervalSeconds != nil {
+		interval = time.Duration(*args.IntervalSeconds) * time.Second
+	}
+
+	session := server.ClientSessionFromContext(ctx)
+	if session == nil {
+		return mcp.NewToolResultError("Failed to watch tickets: the request has no session to notify"), nil
+	}
+	client := desk.ClientFromContext(ctx, w.deskClient)
+	if client == nil {
+		return mcp.NewToolResultError("Failed to watch tickets: no Desk credentials to poll with"), nil
+	}
+
+	filter, err := json.Marshal(args.Filter)
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Failed to marshal filter: %v", err)), nil
+	}
+	key := pollerKey(client, "tickets", interval, string(filter))
+
+	w.mu.Lock()
+	state, ok := w.sessions[session.SessionID()]
+	if !ok {
+		w.mu.Unlock()
+		return mcp.NewToolResultError(fmt.Sprintf("Failed to watch tickets: unknown session %s", session.SessionID())), nil
+	}
+	if len(state.watches) >= MaxWatches {
+		w.mu.Unlock()
+		return mcp.NewToolResultError(fmt.Sprintf("Failed to watch tickets: the session already has %d watches; stop one with unwatch_tickets first", MaxWatches)), nil
+	}
+	w.nextID++
+	id := fmt.Sprintf("watch-%d", w.nextID)
+	state.watches[id] = w.attach(key, id, listener{sessionID: session.SessionID()}, func() *poller {
+		return w.newPoller(key, "tickets", client, args.Filter, interval)
+	})
+	watches := state.watchIDs()
+	w.mu.Unlock()
+
+	data, err := json.Marshal(watchInfo{
+		WatchID:         id,
+		Filter:          args.Filter,
+		IntervalSeconds: int(interval.Seconds()),
+		Watches:         watches,
+	})
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Failed to marshal watch: %v", err)), nil
+	}
+	return mcp.NewToolResultText(string(data)), nil
+}
+
+func (w *Watcher) unwatchTickets(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	var args struct {
+		WatchID string `arg:"watch_id"`
+	}
+	if err := utils.BindArguments(request, &args); err != nil {
+		return utils.ArgumentErrorResult(err), nil
+	}
+	session := server.ClientSessionFromContext(ctx)
+	if session == nil {
+		return mcp.NewToolResultError("Failed to stop watch: the request has no session"), nil
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	state, ok := w.sessions[session.SessionID()]
+	if !ok {
+		return mcp.NewToolResultError(fmt.Sprintf("Failed to stop watch: unknown session %s", session.SessionID())), nil
+	}
+	p, ok := state.watches[args.WatchID]
+	if !ok {
+		return mcp.NewToolResultError(fmt.Sprintf("No watch %q in this session", args.WatchID)), nil
+	}
+	w.detach(p, args.WatchID)
+	delete(state.watches, args.WatchID)
+	return mcp.NewToolResultText(fmt.Sprintf("Watch %s stopped", args.WatchID)), nil
+}
+
+func (s *sessionState) watchIDs() []string {
+	ids := make([]string, 0, len(s.watches))
+	for id := range s.watches {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
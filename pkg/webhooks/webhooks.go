@@ -0,0 +1,414 @@
+// Package webhooks receives Teamwork Desk webhooks and hands the events they
+// carry to the rest of the server, so that changes reach MCP sessions without
+// polling.
+package webhooks
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"github.com/ready4god2513/deskmcp/pkg/desk"
+	"github.com/ready4god2513/deskmcp/pkg/utils"
+)
+
+// Headers of a webhook delivery, as described in the Webhooks section of the
+// Teamwork Desk API documentation (apidocs.teamwork.com)
+const (
+	SignatureHeader = "X-Desk-Signature"
+	EventHeader     = "X-Desk-Event"
+	DeliveryHeader  = "X-Desk-Delivery"
+)
+
+// Path is the path webhooks are received on
+const Path = "/webhooks"
+
+// Event types sent by Desk for the events the server handles. Other event
+// types are accepted and passed on as they are.
+const (
+	TicketCreated       = "ticket.created"
+	TicketReply         = "ticket.reply"
+	TicketStatusChanged = "ticket.status_changed"
+)
+
+const (
+	// maxBodySize bounds the size of a webhook payload
+	maxBodySize = 1 << 20
+	// maxPreview bounds the length of the message preview of an event
+	maxPreview = 200
+	// defaultHistory is the number of recent events kept by default
+	defaultHistory = 100
+	// maxSeen is the number of deliveries remembered to ignore replays
+	maxSeen = 1000
+)
+
+// Event is a webhook delivery reduced to what sessions need to know
+type Event struct {
+	Delivery       string          `json:"delivery"`
+	Site           string          `json:"site"`
+	Type           string          `json:"type"`
+	TicketID       int             `json:"ticket_id,omitempty"`
+	Subject        string          `json:"subject,omitempty"`
+	Status         string          `json:"status,omitempty"`
+	PreviousStatus string          `json:"previous_status,omitempty"`
+	Customer       string          `json:"customer,omitempty"`
+	Message        string          `json:"message,omitempty"`
+	ReceivedAt     time.Time       `json:"received_at"`
+	Payload        json.RawMessage `json:"payload,omitempty"`
+}
+
+// Resource returns the site and URI of the resource the event changed. The
+// URI is empty if the event changed no resource. The same URI names different
+// records on different sites.
+func (e Event) Resource() (site, uri string) {
+	if e.TicketID == 0 {
+		return e.Site, ""
+	}
+	return e.Site, fmt.Sprintf("desk://tickets/%d", e.TicketID)
+}
+
+// payload is the part of a Desk webhook payload the server reads
+type payload struct {
+	Event  string     `json:"event"`
+	Ticket *ticketRef `json:"ticket"`
+	// Payloads without a ticket object describe the ticket at the top level
+	ticketRef
+	Message *struct {
+		Body string `json:"body"`
+	} `json:"message"`
+	PreviousStatus *namedRef `json:"previousStatus"`
+}
+
+type ticketRef struct {
+	ID       int       `json:"id"`
+	Subject  string    `json:"subject"`
+	Status   *namedRef `json:"status"`
+	Customer *struct {
+		Email string `json:"email"`
+	} `json:"customer"`
+}
+
+type namedRef struct {
+	ID   int    `json:"id"`
+	Name string `json:"name"`
+}
+
+// Receiver is an http.Handler accepting signed Desk webhooks of one site. It
+// keeps the most recent events and hands every event to its listeners.
+type Receiver struct {
+	site    string
+	secret  []byte
+	history int
+
+	mu        sync.Mutex
+	events    []Event
+	listeners []func(Event)
+	sequence  int
+	// seen holds the IDs, or the signatures if they have none, of the most
+	// recent deliveries in the order they were received, so that replays
+	// are ignored
+	seen      map[string]bool
+	seenOrder []string
+}
+
+// Option configures a Receiver
+type Option func(*Receiver)
+
+// WithHistory sets how many recent events are kept
+func WithHistory(n int) Option {
+	return func(r *Receiver) {
+		r.history = n
+	}
+}
+
+// NewReceiver returns a receiver of the webhooks of the Desk site with the API
+// URL siteURL. Deliveries are verified with the shared secret configured for
+// the webhook in Desk.
+func NewReceiver(siteURL, secret string, opts ...Option) *Receiver {
+	r := &Receiver{
+		site:    desk.SiteOf(siteURL),
+		secret:  []byte(secret),
+		history: defaultHistory,
+		seen:    make(map[string]bool),
+	}
+	for _, opt := range opts {
+		opt(r)
+	}
+	return r
+}
+
+// OnEvent adds a listener that is called with every accepted event. Listeners
+// are called in the order they were added, one event at a time.
+func (r *Receiver) OnEvent(listener func(Event)) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.listeners = append(r.listeners, listener)
+}
+
+// ServeHTTP implements http.Handler
+func (r *Receiver) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodPost {
+		w.Header().Set("Allow", "POST")
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	body, err := io.ReadAll(http.MaxBytesReader(w, req.Body, maxBodySize))
+	if err != nil {
+		http.Error(w, "Failed to read payload", http.StatusBadRequest)
+		return
+	}
+	signature := strings.TrimSpace(req.Header.Get(SignatureHeader))
+	if !r.verify(body, signature) {
+		http.Error(w, "Invalid signature", http.StatusUnauthorized)
+		return
+	}
+
+	event, err := parseEvent(body, req.Header.Get(EventHeader))
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Invalid payload: %v", err), http.StatusBadRequest)
+		return
+	}
+	event.Delivery = req.Header.Get(DeliveryHeader)
+	event.Site = r.site
+	delivery := event.Delivery
+	if delivery == "" {
+		delivery = "signature:" + strings.ToLower(signature)
+	}
+	if !r.firstDelivery(delivery) {
+		// A replay of a delivery that was handled already
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+	r.dispatch(event)
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// verify checks the hex encoded HMAC-SHA256 signature of the body
+func (r *Receiver) verify(body []byte, signature string) bool {
+	got, err := hex.DecodeString(signature)
+	if err != nil || len(got) == 0 {
+		return false
+	}
+	return hmac.Equal(got, Sign(r.secret, body))
+}
+
+// firstDelivery records a delivery and reports whether it was not seen
+// before. Only the most recent deliveries are remembered.
+func (r *Receiver) firstDelivery(delivery string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.seen[delivery] {
+		return false
+	}
+	r.seen[delivery] = true
+	r.seenOrder = append(r.seenOrder, delivery)
+	if len(r.seenOrder) > maxSeen {
+		delete(r.seen, r.seenOrder[0])
+		r.seenOrder = r.seenOrder[1:]
+	}
+	return true
+}
+
+// Sign returns the signature Desk sends with a payload in the SignatureHeader,
+// hex encoded: the HMAC-SHA256 of the raw request body, keyed with the secret
+// of the webhook. See the Webhooks section of the Teamwork Desk API
+// documentation (apidocs.teamwork.com).
+func Sign(secret []byte, body []byte) []byte {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write(body)
+	return mac.Sum(nil)
+}
+
+func parseEvent(body []byte, eventType string) (Event, error) {
+	var p payload
+	if err := json.Unmarshal(body, &p); err != nil {
+		return Event{}, err
+	}
+	if eventType == "" {
+		eventType = p.Event
+	}
+	if eventType == "" {
+		return Event{}, fmt.Errorf("no event type in the %s header or the event field", EventHeader)
+	}
+
+	ticket := p.ticketRef
+	if p.Ticket != nil {
+		ticket = *p.Ticket
+	}
+	event := Event{
+		Type:       eventType,
+		TicketID:   ticket.ID,
+		Subject:    ticket.Subject,
+		ReceivedAt: time.Now().UTC(),
+		Payload:    json.RawMessage(body),
+	}
+	if ticket.Status != nil {
+		event.Status = ticket.Status.Name
+	}
+	if ticket.Customer != nil {
+		event.Customer = ticket.Customer.Email
+	}
+	if p.PreviousStatus != nil {
+		event.PreviousStatus = p.PreviousStatus.Name
+	}
+	if p.Message != nil {
+		event.Message = preview(p.Message.Body)
+	}
+	return event, nil
+}
+
+func preview(body string) string {
+	body = strings.Join(strings.Fields(body), " ")
+	if len(body) <= maxPreview {
+		return body
+	}
+	cut := maxPreview
+	for cut > 0 && !utf8.RuneStart(body[cut]) {
+		cut--
+	}
+	return body[:cut] + "…"
+}
+
+// dispatch records the event and hands it to the listeners
+func (r *Receiver) dispatch(event Event) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.sequence++
+	if event.Delivery == "" {
+		event.Delivery = strconv.Itoa(r.sequence)
+	}
+	r.events = append(r.events, event)
+	if len(r.events) > r.history {
+		r.events = r.events[len(r.events)-r.history:]
+	}
+	for _, listener := range r.listeners {
+		listener(event)
+	}
+}
+
+// Events returns the recent events, most recent first
+func (r *Receiver) Events() []Event {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	events := make([]Event, len(r.events))
+	for i, e := range r.events {
+		events[len(r.events)-1-i] = e
+	}
+	return events
+}
+
+// LastTicketEvent returns the most recent event of a ticket that is still
+// among the recent events
+func (r *Receiver) LastTicketEvent(id int) (Event, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for i := len(r.events) - 1; i >= 0; i-- {
+		if r.events[i].TicketID == id {
+			return r.events[i], true
+		}
+	}
+	return Event{}, false
+}
+
+// RegisterResources registers the desk://webhooks/events resource listing the
+// recent events and the desk://webhooks/tickets/{id} template returning the
+// last event of a ticket
+func (r *Receiver) RegisterResources(s *server.MCPServer) {
+	s.AddResource(mcp.NewResource("desk://webhooks/events", "Webhook events",
+		mcp.WithResourceDescription("The most recent events received from Desk webhooks, most recent first"),
+		mcp.WithMIMEType("application/json"),
+	), r.readEvents)
+
+	s.AddResourceTemplate(mcp.NewResourceTemplate("desk://webhooks/tickets/{id}", "Last ticket event",
+		mcp.WithTemplateDescription("The most recent webhook event of a ticket"),
+		mcp.WithTemplateMIMEType("application/json"),
+	), r.readTicketEvent)
+}
+
+// visible reports whether the session of ctx may read the events, which it
+// may if it uses the site the webhooks come from
+func (r *Receiver) visible(ctx context.Context) bool {
+	client := desk.ClientFromContext(ctx, nil)
+	return client == nil || client.Site() == r.site
+}
+
+func (r *Receiver) readEvents(ctx context.Context, request mcp.ReadResourceRequest) ([]mcp.ResourceContents, error) {
+	events := []Event{}
+	if r.visible(ctx) {
+		events = r.Events()
+	}
+	return utils.JSONResource(request.Params.URI, events)
+}
+
+func (r *Receiver) readTicketEvent(ctx context.Context, request mcp.ReadResourceRequest) ([]mcp.ResourceContents, error) {
+	id, err := utils.ResourceID(request)
+	if err != nil {
+		return nil, err
+	}
+	event, ok := r.LastTicketEvent(id)
+	ok = ok && r.visible(ctx)
+	if !ok {
+		return nil, fmt.Errorf("no webhook event received for ticket %d", id)
+	}
+	return utils.JSONResource(request.Params.URI, event)
+}
+
+// staleResources lists the cached resources the events of a type may have
+// made stale. Tickets are not cached, but a status change can name a status
+// added in Desk since the statuses were cached, and a new ticket can carry new
+// tags.
+var staleResources = map[string][]string{
+	TicketCreated:       {"tags"},
+	TicketStatusChanged: {"ticketstatuses"},
+}
+
+// CacheInvalidator returns a listener that drops the cached responses an
+// event may have made stale from the cache of client, the client of the
+// webhook's site
+func CacheInvalidator(client *desk.Client) func(Event) {
+	return func(event Event) {
+		client.InvalidateCache(staleResources[event.Type]...)
+	}
+}
+
+// Notifier sends a notification to the sessions that subscribed to or watch
+// the resource identified by uri on site
+type Notifier func(site, uri, method string, params map[string]interface{})
+
+// LogNotifier returns a listener that sends every event of a ticket as an MCP
+// logging notification to the sessions notify finds interested in the ticket.
+// Events of no ticket are only kept with the recent events.
+func LogNotifier(notify Notifier) func(Event) {
+	return func(event Event) {
+		site, uri := event.Resource()
+		if uri == "" {
+			return
+		}
+		notify(site, uri, "notifications/message", map[string]interface{}{
+			"level":  "info",
+			"logger": "desk.webhooks",
+			"data":   event.summary(),
+		})
+	}
+}
+
+// summary is the event without its payload, which is available from the
+// webhook resources
+func (e Event) summary() Event {
+	e.Payload = nil
+	return e
+}